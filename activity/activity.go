@@ -37,6 +37,15 @@ type (
 
 	// RegisterOptions consists of options for registering an activity
 	RegisterOptions = internal.RegisterActivityOptions
+
+	// DynamicFunc is the function type of a dynamic activity, see RegisterDynamic.
+	DynamicFunc = internal.DynamicActivityFunc
+
+	// Interceptor intercepts the activity executions of a worker, see worker.Options.ActivityInterceptors.
+	Interceptor = internal.ActivityInterceptor
+
+	// Handler executes an activity with its decoded arguments, see Interceptor.
+	Handler = internal.ActivityHandler
 )
 
 // ErrResultPending is returned from activity's implementation to indicate the activity is not completed when
@@ -67,11 +76,23 @@ func Register(activityFunc interface{}) {
 // Serialization of all primitive types, structures is supported ... except channels, functions, unsafe pointer.
 // If function implementation returns activity.ErrResultPending then activity is not completed from the
 // calling workflow point of view. See documentation of activity.ErrResultPending for more info.
+// activityFunc can also be a pointer to a struct, in which case each of its exported methods that complies with the
+// expected format is registered as an activity named "<struct name>.<method name>", or "<opts.Name><method name>" if
+// opts.Name is set. workflow.ExecuteActivity accepts the method values of the registered struct. A struct type can be
+// registered only once.
 // This method calls panic if activityFunc doesn't comply with the expected format.
 func RegisterWithOptions(activityFunc interface{}, opts RegisterOptions) {
 	internal.RegisterActivityWithOptions(activityFunc, opts)
 }
 
+// RegisterDynamic registers a function that executes every activity type without an exact registration,
+// instead of failing the activity task. The function receives the activity type name and the raw arguments.
+//	func dynamicActivity(ctx context.Context, activityType string, args encoded.Values) (interface{}, error)
+// Only one dynamic activity can be registered, this method calls panic if one is already registered.
+func RegisterDynamic(activityFunc DynamicFunc) {
+	internal.RegisterDynamicActivity(activityFunc)
+}
+
 // GetInfo returns information about currently executing activity.
 func GetInfo(ctx context.Context) Info {
 	return internal.GetActivityInfo(ctx)
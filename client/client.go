@@ -41,12 +41,42 @@ type (
 	// Options are optional parameters for Client creation.
 	Options = internal.ClientOptions
 
+	// Interceptor intercepts the operations of a Client, see Options.Interceptors.
+	Interceptor = internal.ClientInterceptor
+
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
 	StartWorkflowOptions = internal.StartWorkflowOptions
 
 	// HistoryEventIterator is a iterator which can return history events
 	HistoryEventIterator = internal.HistoryEventIterator
 
+	// WorkflowExecutionIterator is a iterator which can return workflow executions
+	WorkflowExecutionIterator = internal.WorkflowExecutionIterator
+
+	// ListWorkflowFilter is the filter of the workflow executions returned by a WorkflowExecutionIterator.
+	ListWorkflowFilter = internal.ListWorkflowFilter
+
+	// BatchOperationType defines the operation applied by RunBatchOperation.
+	BatchOperationType = internal.BatchOperationType
+
+	// BatchOperationOptions configuration parameters for RunBatchOperation.
+	BatchOperationOptions = internal.BatchOperationOptions
+
+	// BatchOperationProgress is passed to BatchOperationOptions.ProgressCallback.
+	BatchOperationProgress = internal.BatchOperationProgress
+
+	// BatchOperationReport is the outcome of RunBatchOperation.
+	BatchOperationReport = internal.BatchOperationReport
+
+	// BatchOperationFailure is the error returned by the operation for a single execution.
+	BatchOperationFailure = internal.BatchOperationFailure
+
+	// WorkflowEvent is a history event of a workflow execution, decoded by Client.WatchWorkflow.
+	WorkflowEvent = internal.WorkflowEvent
+
+	// WorkflowEventType is the type of a WorkflowEvent.
+	WorkflowEventType = internal.WorkflowEventType
+
 	// WorkflowRun represents a started non child workflow
 	WorkflowRun = internal.WorkflowRun
 
@@ -95,6 +125,21 @@ type (
 		// NOTE: DO NOT USE THIS API INSIDE A WORKFLOW, USE workflow.ExecuteChildWorkflow instead
 		ExecuteWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (WorkflowRun, error)
 
+		// GetWorkflow retrieves a workflow execution and return a WorkflowRun instance
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+		//
+		// WorkflowRun has three methods:
+		//  - GetID() string: which return workflow ID (which is same as workflowID passed in)
+		//  - GetRunID() string: which return the run ID passed in
+		//  - Get(ctx context.Context, valuePtr interface{}) error: which will fill the workflow
+		//    execution result to valuePtr, if workflow execution is a success, or return corresponding
+		//    error. This is a blocking API.
+		// NOTE: if the retrieved workflow returned ContinueAsNewError during the workflow execution, the
+		// return result of GetRunID() will be the retrieved workflow run ID, not the new run ID caused by ContinueAsNewError,
+		// however, Get(ctx context.Context, valuePtr interface{}) will return result from the run which did not return ContinueAsNewError.
+		GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun
+
 		// SignalWorkflow sends a signals to a workflow in execution
 		// - workflow ID of the workflow.
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
@@ -156,6 +201,21 @@ type (
 		//		}
 		GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType s.HistoryEventFilterType) HistoryEventIterator
 
+		// WatchWorkflow returns a channel delivering the history events of a workflow as they happen, decoded
+		// through the DataConverter of the client. The watch follows the workflow through continue as new, and the
+		// channel is closed after the WorkflowClosed event. If the history can not be fetched, an Error event is
+		// delivered and the channel is closed. The channel is also closed, without further events, when ctx is done.
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+		// Example:-
+		//	for event := range WatchWorkflow(ctx, workflowID, runID) {
+		//		switch event.Type {
+		//		case WorkflowEventTypeActivityClosed:
+		//			...
+		//		}
+		//	}
+		WatchWorkflow(ctx context.Context, workflowID string, runID string) <-chan WorkflowEvent
+
 		// CompleteActivity reports activity completed.
 		// activity Execute method can return activity.ErrResultPending to
 		// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
@@ -240,6 +300,20 @@ type (
 		//  - QueryFailError
 		QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error)
 
+		// QueryWorkflowFromHistory queries a given workflow execution without a worker: the full history of the execution is
+		// fetched and replayed locally with the workflow definition registered in this process, then the query handler
+		// runs against the final state. Unlike QueryWorkflow it works for closed workflow executions as well.
+		// - workflowID is required.
+		// - runID can be default(empty string). if empty string then it will pick the last execution of that workflow ID.
+		// - queryType is the type of the query.
+		// - args... are the optional query parameters.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		QueryWorkflowFromHistory(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
 		//
@@ -256,6 +330,18 @@ type (
 		//  - InternalServiceError
 		//  - EntityNotExistError
 		DescribeTaskList(ctx context.Context, tasklist string, tasklistType s.TaskListType) (*s.DescribeTaskListResponse, error)
+
+		// ResetWorkflow resets a workflow execution to the point right after the decision finish event given in
+		// the request, and returns the run ID of the new execution. The current run, if still open, is terminated.
+		// Use GetLastDecisionTaskCompletedEventID, GetFirstDecisionTaskCompletedEventIDByBinaryChecksum or
+		// GetDecisionFinishEventID to find the DecisionFinishEventId of the request.
+		// - domain can be default(empty string). if empty string then the domain of the client is used.
+		// - requestID can be default(empty string). if empty string then a random one is generated.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error)
 	}
 
 	// DomainClient is the client for managing operations on the domain.
@@ -301,6 +387,50 @@ const (
 	WorkflowIDReusePolicyRejectDuplicate WorkflowIDReusePolicy = internal.WorkflowIDReusePolicyRejectDuplicate
 )
 
+const (
+	// BatchOperationTypeTerminate terminates the workflow executions through Client.TerminateWorkflow.
+	BatchOperationTypeTerminate BatchOperationType = internal.BatchOperationTypeTerminate
+
+	// BatchOperationTypeCancel cancels the workflow executions through Client.CancelWorkflow.
+	BatchOperationTypeCancel BatchOperationType = internal.BatchOperationTypeCancel
+
+	// BatchOperationTypeSignal signals the workflow executions through Client.SignalWorkflow.
+	BatchOperationTypeSignal BatchOperationType = internal.BatchOperationTypeSignal
+)
+
+const (
+	// WorkflowEventTypeWorkflowStarted is delivered when a run of the workflow starts.
+	WorkflowEventTypeWorkflowStarted WorkflowEventType = internal.WorkflowEventTypeWorkflowStarted
+	// WorkflowEventTypeActivityScheduled is delivered when the workflow schedules an activity.
+	WorkflowEventTypeActivityScheduled WorkflowEventType = internal.WorkflowEventTypeActivityScheduled
+	// WorkflowEventTypeActivityClosed is delivered when an activity completes, fails, times out or is canceled.
+	WorkflowEventTypeActivityClosed WorkflowEventType = internal.WorkflowEventTypeActivityClosed
+	// WorkflowEventTypeSignalReceived is delivered when the workflow receives a signal.
+	WorkflowEventTypeSignalReceived WorkflowEventType = internal.WorkflowEventTypeSignalReceived
+	// WorkflowEventTypeTimerStarted is delivered when the workflow starts a timer.
+	WorkflowEventTypeTimerStarted WorkflowEventType = internal.WorkflowEventTypeTimerStarted
+	// WorkflowEventTypeTimerFired is delivered when a timer fires.
+	WorkflowEventTypeTimerFired WorkflowEventType = internal.WorkflowEventTypeTimerFired
+	// WorkflowEventTypeTimerCanceled is delivered when a timer is canceled.
+	WorkflowEventTypeTimerCanceled WorkflowEventType = internal.WorkflowEventTypeTimerCanceled
+	// WorkflowEventTypeChildWorkflowInitiated is delivered when the workflow requests a child workflow to start.
+	WorkflowEventTypeChildWorkflowInitiated WorkflowEventType = internal.WorkflowEventTypeChildWorkflowInitiated
+	// WorkflowEventTypeChildWorkflowStarted is delivered when a child workflow is started.
+	WorkflowEventTypeChildWorkflowStarted WorkflowEventType = internal.WorkflowEventTypeChildWorkflowStarted
+	// WorkflowEventTypeChildWorkflowClosed is delivered when a child workflow fails to start, completes, fails,
+	// times out, is canceled or is terminated.
+	WorkflowEventTypeChildWorkflowClosed WorkflowEventType = internal.WorkflowEventTypeChildWorkflowClosed
+	// WorkflowEventTypeWorkflowContinuedAsNew is delivered when a run of the workflow continues as new. The watch
+	// goes on with the new run.
+	WorkflowEventTypeWorkflowContinuedAsNew WorkflowEventType = internal.WorkflowEventTypeWorkflowContinuedAsNew
+	// WorkflowEventTypeWorkflowClosed is delivered when the workflow completes, fails, times out, is canceled or is
+	// terminated. It is the last event delivered.
+	WorkflowEventTypeWorkflowClosed WorkflowEventType = internal.WorkflowEventTypeWorkflowClosed
+	// WorkflowEventTypeError is delivered when the history of the workflow can not be fetched. It is the last
+	// event delivered.
+	WorkflowEventTypeError WorkflowEventType = internal.WorkflowEventTypeError
+)
+
 // NewClient creates an instance of a workflow client
 func NewClient(service workflowserviceclient.Interface, domain string, options *Options) Client {
 	return internal.NewClient(service, domain, options)
@@ -311,6 +441,59 @@ func NewDomainClient(service workflowserviceclient.Interface, options *Options)
 	return internal.NewDomainClient(service, options)
 }
 
+// NewOpenWorkflowExecutionIterator returns an iterator over the open workflow executions matching the filter.
+// Pages are fetched from server through Client.ListOpenWorkflow as the iteration goes, transient errors are retried.
+// Example:-
+//	iter := NewOpenWorkflowExecutionIterator(ctx, client, ListWorkflowFilter{WorkflowType: "my-workflow"})
+//	for iter.HasNext() {
+//		execution, err := iter.Next()
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func NewOpenWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	return internal.NewOpenWorkflowExecutionIterator(ctx, c, filter)
+}
+
+// NewClosedWorkflowExecutionIterator returns an iterator over the closed workflow executions matching the filter.
+// Pages are fetched from server through Client.ListClosedWorkflow as the iteration goes, transient errors are retried.
+func NewClosedWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	return internal.NewClosedWorkflowExecutionIterator(ctx, c, filter)
+}
+
+// RunBatchOperation applies the operation given in options to every open workflow execution matching
+// options.Filter, and blocks until all of them are processed or ctx is done.
+// The returned error is non nil only if listing the executions failed or ctx is done, the errors returned
+// for individual executions are collected in BatchOperationReport.Failures instead.
+// The report of the executions processed so far is returned along with the error.
+func RunBatchOperation(ctx context.Context, c Client, options BatchOperationOptions) (*BatchOperationReport, error) {
+	return internal.RunBatchOperation(ctx, c, options)
+}
+
+// GetLastDecisionTaskCompletedEventID returns the ID of the last DecisionTaskCompleted event in the history of the
+// given workflow execution. Resetting to it discards everything that happened after the last completed decision.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetLastDecisionTaskCompletedEventID(ctx context.Context, c Client, workflowID, runID string) (int64, error) {
+	return internal.GetLastDecisionTaskCompletedEventID(ctx, c, workflowID, runID)
+}
+
+// GetFirstDecisionTaskCompletedEventIDByBinaryChecksum returns the ID of the first DecisionTaskCompleted event
+// reported by a worker with the given binary checksum. Resetting to it discards all the decisions made by that
+// binary, which is how workflows broken by a bad deployment are recovered.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetFirstDecisionTaskCompletedEventIDByBinaryChecksum(ctx context.Context, c Client, workflowID, runID, binaryChecksum string) (int64, error) {
+	return internal.GetFirstDecisionTaskCompletedEventIDByBinaryChecksum(ctx, c, workflowID, runID, binaryChecksum)
+}
+
+// GetDecisionFinishEventID verifies that the event with the given ID exists in the history of the workflow execution
+// and is a DecisionTaskCompleted, DecisionTaskFailed or DecisionTaskTimedOut event, which are the only events a
+// workflow can be reset to. It returns the same event ID on success.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetDecisionFinishEventID(ctx context.Context, c Client, workflowID, runID string, eventID int64) (int64, error) {
+	return internal.GetDecisionFinishEventID(ctx, c, workflowID, runID, eventID)
+}
+
 // make sure if new methods are added to internal.Client they are also added to public Client.
 var _ Client = internal.Client(nil)
 var _ internal.Client = Client(nil)
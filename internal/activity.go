@@ -55,9 +55,29 @@ type (
 
 	// RegisterActivityOptions consists of options for registering an activity
 	RegisterActivityOptions struct {
+		// Name of the activity, defaults to the function name. When a struct is registered, Name is used as the
+		// prefix of the activity names of its methods instead of the default "<struct name>.".
 		Name string
 	}
 
+	// DynamicActivityFunc is the function type of a dynamic activity, see RegisterDynamicActivity.
+	// It receives the name of the activity type being executed and its arguments as encoded values.
+	DynamicActivityFunc func(ctx context.Context, activityType string, args encoded.Values) (interface{}, error)
+
+	// ActivityHandler executes an activity with its decoded arguments, see ActivityInterceptor.
+	ActivityHandler func(ctx context.Context, args []interface{}) (interface{}, error)
+
+	// ActivityInterceptor intercepts the activity executions of a worker, to inject values in the activity context,
+	// classify panics, add logging fields, audit payloads or rewrite results and errors for example. It is configured
+	// with WorkerOptions.ActivityInterceptors and applies to both activities and local activities.
+	ActivityInterceptor interface {
+		// InterceptActivity is called for every activity execution with the arguments of the activity, not including
+		// its context. The arguments of a dynamic activity are a single encoded.Values. GetActivityInfo(ctx) tells which
+		// activity is executed. The interceptor calls next to execute the activity, it runs on the goroutine of the
+		// activity so it can recover panics of next. The returned result is encoded as the result of the activity.
+		InterceptActivity(ctx context.Context, args []interface{}, next ActivityHandler) (interface{}, error)
+	}
+
 	// ActivityOptions stores all activity-specific parameters that will be stored inside of a context.
 	// The current timeout resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 	// subjected to change in the future.
@@ -127,6 +147,10 @@ type (
 //	func sampleActivity(arg1 bool) (result int, err error)
 //	func sampleActivity(arg1 bool) (err error)
 // Serialization of all primitive types, structures is supported ... except channels, functions, variadic, unsafe pointer.
+// activityFunc can also be a pointer to a struct, in which case each of its exported methods that complies with the
+// expected format is registered as an activity named "<struct name>.<method name>". ExecuteActivity accepts the method
+// values of the registered struct, like ExecuteActivity(ctx, activities.SampleActivity). A struct type can be
+// registered only once.
 // This method calls panic if activityFunc doesn't comply with the expected format.
 func RegisterActivity(activityFunc interface{}) {
 	RegisterActivityWithOptions(activityFunc, RegisterActivityOptions{})
@@ -155,6 +179,20 @@ func RegisterActivityWithOptions(activityFunc interface{}, opts RegisterActivity
 	}
 }
 
+// RegisterDynamicActivity registers a function that executes every activity type without an exact
+// registration, instead of failing the activity task. It is used as:
+//	func dynamicActivity(ctx context.Context, activityType string, args encoded.Values) (interface{}, error) {
+//		...
+//	}
+// Only one dynamic activity can be registered, this method calls panic if one is already registered.
+func RegisterDynamicActivity(activityFunc DynamicActivityFunc) {
+	thImpl := getHostEnvironment()
+	err := thImpl.RegisterDynamicActivity(activityFunc)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // GetActivityInfo returns information about currently executing activity.
 func GetActivityInfo(ctx context.Context) ActivityInfo {
 	env := getActivityEnv(ctx)
@@ -254,6 +292,7 @@ func WithActivityTask(
 	logger *zap.Logger,
 	scope tally.Scope,
 	dataConverter encoded.DataConverter,
+	interceptors []ActivityInterceptor,
 ) context.Context {
 	var deadline time.Time
 	scheduled := time.Unix(0, task.GetScheduledTimestamp())
@@ -291,6 +330,7 @@ func WithActivityTask(
 			Name: *task.WorkflowType.Name,
 		},
 		workflowDomain: *task.WorkflowDomain,
+		interceptors:   interceptors,
 	})
 }
 
@@ -24,6 +24,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -114,6 +115,82 @@ func (s *activityTestSuite) TestActivityHeartbeat_EntityNotExist() {
 	require.Equal(s.T(), ctx.Err(), context.Canceled)
 }
 
+func (s *activityTestSuite) TestActivityAutoHeartbeat() {
+	_, cancel := context.WithCancel(context.Background())
+	invoker := newCadenceInvoker([]byte("task-token"), "identity", s.service, cancel, 1)
+
+	detailsCh := make(chan []byte, 1)
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions...).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{}, nil).
+		Do(func(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) {
+			detailsCh <- request.Details
+		}).Times(1)
+
+	// the details of the previous attempt are reported until the activity heartbeats itself
+	invoker.startAutoHeartBeat([]byte("previous"))
+	select {
+	case details := <-detailsCh:
+		s.Equal([]byte("previous"), details)
+	case <-time.After(2 * time.Second):
+		s.Fail("auto heartbeat not sent")
+	}
+	invoker.Close(false)
+}
+
+func (s *activityTestSuite) TestActivityAutoHeartbeat_CancelRequested() {
+	ctx, cancel := context.WithCancel(context.Background())
+	invoker := newCadenceInvoker([]byte("task-token"), "identity", s.service, cancel, 1)
+
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions...).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{CancelRequested: common.BoolPtr(true)}, nil).Times(1)
+
+	invoker.startAutoHeartBeat(nil)
+	<-ctx.Done()
+	require.Equal(s.T(), ctx.Err(), context.Canceled)
+	invoker.Close(false)
+}
+
+func (s *activityTestSuite) TestActivityAutoHeartbeat_CloseWaitsForHeartbeat() {
+	_, cancel := context.WithCancel(context.Background())
+	invoker := newCadenceInvoker([]byte("task-token"), "identity", s.service, cancel, 1)
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	s.service.EXPECT().RecordActivityTaskHeartbeat(gomock.Any(), gomock.Any(), callOptions...).
+		Return(&shared.RecordActivityTaskHeartbeatResponse{}, nil).
+		Do(func(ctx context.Context, request *shared.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) {
+			close(startedCh)
+			<-releaseCh
+		}).Times(1)
+
+	invoker.startAutoHeartBeat(nil)
+	select {
+	case <-startedCh:
+	case <-time.After(2 * time.Second):
+		s.Fail("auto heartbeat not sent")
+	}
+
+	closedCh := make(chan struct{})
+	go func() {
+		invoker.Close(false)
+		close(closedCh)
+	}()
+	select {
+	case <-closedCh:
+		s.Fail("Close returned while a heartbeat was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(releaseCh)
+	select {
+	case <-closedCh:
+	case <-time.After(2 * time.Second):
+		s.Fail("Close didn't return")
+	}
+
+	// no further heartbeat is sent after Close
+	time.Sleep(time.Second)
+}
+
 func (s *activityTestSuite) TestActivityHeartbeat_SuppressContinousInvokes() {
 	ctx, cancel := context.WithCancel(context.Background())
 	invoker := newServiceInvoker([]byte("task-token"), "identity", s.service, cancel, 2)
@@ -22,11 +22,13 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.uber.org/cadence/encoded"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	s "go.uber.org/cadence/.gen/go/shared"
@@ -86,6 +88,21 @@ type (
 		// NOTE: DO NOT USE THIS API INSIDE A WORKFLOW, USE workflow.ExecuteChildWorkflow instead
 		ExecuteWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (WorkflowRun, error)
 
+		// GetWorkflow retrieves a workflow execution and return a WorkflowRun instance
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+		//
+		// WorkflowRun has three methods:
+		//  - GetID() string: which return workflow ID (which is same as workflowID passed in)
+		//  - GetRunID() string: which return the run ID passed in
+		//  - Get(ctx context.Context, valuePtr interface{}) error: which will fill the workflow
+		//    execution result to valuePtr, if workflow execution is a success, or return corresponding
+		//    error. This is a blocking API.
+		// NOTE: if the retrieved workflow returned ContinueAsNewError during the workflow execution, the
+		// return result of GetRunID() will be the retrieved workflow run ID, not the new run ID caused by ContinueAsNewError,
+		// however, Get(ctx context.Context, valuePtr interface{}) will return result from the run which did not return ContinueAsNewError.
+		GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun
+
 		// SignalWorkflow sends a signals to a workflow in execution
 		// - workflow ID of the workflow.
 		// - runID can be default(empty string). if empty string then it will pick the running execution of that workflow ID.
@@ -147,6 +164,21 @@ type (
 		//		}
 		GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType s.HistoryEventFilterType) HistoryEventIterator
 
+		// WatchWorkflow returns a channel delivering the history events of a workflow as they happen, decoded
+		// through the DataConverter of the client. The watch follows the workflow through continue as new, and the
+		// channel is closed after the WorkflowClosed event. If the history can not be fetched, an Error event is
+		// delivered and the channel is closed. The channel is also closed, without further events, when ctx is done.
+		// - workflow ID of the workflow.
+		// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+		// Example:-
+		//	for event := range WatchWorkflow(ctx, workflowID, runID) {
+		//		switch event.Type {
+		//		case WorkflowEventTypeActivityClosed:
+		//			...
+		//		}
+		//	}
+		WatchWorkflow(ctx context.Context, workflowID string, runID string) <-chan WorkflowEvent
+
 		// CompleteActivity reports activity completed.
 		// activity Execute method can return acitivity.activity.ErrResultPending to
 		// indicate the activity is not completed when it's Execute method returns. In that case, this CompleteActivity() method
@@ -225,6 +257,20 @@ type (
 		//  - QueryFailError
 		QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error)
 
+		// QueryWorkflowFromHistory queries a given workflow execution without a worker: the full history of the execution is
+		// fetched and replayed locally with the workflow definition registered in this process, then the query handler
+		// runs against the final state. Unlike QueryWorkflow it works for closed workflow executions as well.
+		// - workflowID is required.
+		// - runID can be default(empty string). if empty string then it will pick the last execution of that workflow ID.
+		// - queryType is the type of the query.
+		// - args... are the optional query parameters.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		//  - QueryFailError
+		QueryWorkflowFromHistory(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error)
+
 		// DescribeWorkflowExecution returns information about the specified workflow execution.
 		// The errors it can return:
 		//  - BadRequestError
@@ -239,6 +285,18 @@ type (
 		//  - InternalServiceError
 		//  - EntityNotExistError
 		DescribeTaskList(ctx context.Context, tasklist string, tasklistType s.TaskListType) (*s.DescribeTaskListResponse, error)
+
+		// ResetWorkflow resets a workflow execution to the point right after the decision finish event given in
+		// the request, and returns the run ID of the new execution. The current run, if still open, is terminated.
+		// Use GetLastDecisionTaskCompletedEventID, GetFirstDecisionTaskCompletedEventIDByBinaryChecksum or
+		// GetDecisionFinishEventID to find the DecisionFinishEventId of the request.
+		// - domain can be default(empty string). if empty string then the domain of the client is used.
+		// - requestID can be default(empty string). if empty string then a random one is generated.
+		// The errors it can return:
+		//  - BadRequestError
+		//  - InternalServiceError
+		//  - EntityNotExistError
+		ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error)
 	}
 
 	// ClientOptions are optional parameters for Client creation.
@@ -246,6 +304,39 @@ type (
 		MetricsScope  tally.Scope
 		Identity      string
 		DataConverter encoded.DataConverter
+
+		// Optional: Interceptors wrap every operation of the created Client. The first interceptor is the outermost one,
+		// it sees a call first and its result last.
+		// default: no interceptors
+		Interceptors []ClientInterceptor
+
+		// Optional: ContextPropagators propagate values of the context of StartWorkflow, ExecuteWorkflow and
+		// SignalWithStartWorkflow to the started workflow, see ContextPropagator.
+		// default: no propagators
+		ContextPropagators []ContextPropagator
+
+		// Optional: Tracer emits a span for every workflow started, signaled or queried by the created Client, and
+		// propagates it to the started workflows. The span is a child of the span of the context of the call if any.
+		// default: no tracing
+		Tracer opentracing.Tracer
+
+		// Optional: Worker whose registrations resolve the workflow functions and aliases passed to StartWorkflow,
+		// ExecuteWorkflow and SignalWithStartWorkflow, decode the results of WorkflowRun.Get and replay the workflows
+		// queried by QueryWorkflowFromHistory. The global registrations are used for what is not registered with
+		// the worker. The client sees the registrations of this one worker only: the workflows of several workers are
+		// either registered globally, or started and queried with a client per worker.
+		// default: the global registrations only
+		Worker Worker
+	}
+
+	// ClientInterceptor intercepts the operations of a Client, to audit, authorize or validate them for example.
+	// Implementations usually return a struct that embeds next and overrides the methods of interest only.
+	// The calls the Client makes to its own methods go through the interceptors too: ExecuteWorkflow calls
+	// StartWorkflow, and ExecuteWorkflow, GetWorkflow, WatchWorkflow and QueryWorkflowFromHistory call
+	// GetWorkflowHistory and DescribeWorkflowExecution. Intercepting StartWorkflow covers ExecuteWorkflow for example.
+	ClientInterceptor interface {
+		// InterceptClient returns a Client that wraps next. It is called once, when the Client is created.
+		InterceptClient(next Client) Client
 	}
 
 	// StartWorkflowOptions configuration parameters for starting a workflow execution.
@@ -363,6 +454,75 @@ type (
 
 	// WorkflowIDReusePolicy defines workflow ID reuse behavior.
 	WorkflowIDReusePolicy int
+
+	// BatchOperationType defines the operation applied by RunBatchOperation.
+	BatchOperationType int
+
+	// BatchOperationOptions configuration parameters for RunBatchOperation.
+	BatchOperationOptions struct {
+		// Type - The operation applied to every matching open workflow execution.
+		// Mandatory: No default.
+		Type BatchOperationType
+
+		// Filter - Selects the open workflow executions the operation is applied to.
+		// Optional: default to all open workflow executions of the domain.
+		Filter ListWorkflowFilter
+
+		// Reason, Details - Passed to TerminateWorkflow for BatchOperationTypeTerminate.
+		Reason  string
+		Details []byte
+
+		// SignalName, SignalArg - Passed to SignalWorkflow for BatchOperationTypeSignal.
+		// SignalName is mandatory for BatchOperationTypeSignal.
+		SignalName string
+		SignalArg  interface{}
+
+		// Concurrency - The maximum number of executions the operation is applied to at the same time.
+		// Optional: default to 1.
+		Concurrency int
+
+		// RPS - The maximum number of executions the operation is applied to per second.
+		// Optional: default to no rate limit.
+		RPS float64
+
+		// DryRun - List the matching executions and report them through ProgressCallback and the returned
+		// BatchOperationReport without applying the operation.
+		// Optional: default to false.
+		DryRun bool
+
+		// ProgressCallback - Invoked after the operation is applied to each execution. It is invoked concurrently
+		// when Concurrency is greater than 1.
+		// Optional: default to no callback.
+		ProgressCallback func(progress BatchOperationProgress)
+	}
+
+	// BatchOperationProgress is passed to BatchOperationOptions.ProgressCallback.
+	BatchOperationProgress struct {
+		// Execution the operation was just applied to.
+		Execution WorkflowExecution
+		// Err returned by the operation for Execution, nil on success.
+		Err error
+		// Processed is the number of executions processed so far, including Execution.
+		Processed int
+		// Failed is the number of executions processed so far for which the operation failed.
+		Failed int
+	}
+
+	// BatchOperationReport is the outcome of RunBatchOperation.
+	BatchOperationReport struct {
+		// Processed is the number of executions the operation was applied to.
+		Processed int
+		// Succeeded is the number of executions the operation was applied to successfully.
+		Succeeded int
+		// Failures contains an entry for every execution the operation failed for.
+		Failures []BatchOperationFailure
+	}
+
+	// BatchOperationFailure is the error returned by the operation for a single execution.
+	BatchOperationFailure struct {
+		Execution WorkflowExecution
+		Err       error
+	}
 )
 
 const (
@@ -379,6 +539,17 @@ const (
 	WorkflowIDReusePolicyRejectDuplicate
 )
 
+const (
+	// BatchOperationTypeTerminate terminates the workflow executions through Client.TerminateWorkflow.
+	BatchOperationTypeTerminate BatchOperationType = iota + 1
+
+	// BatchOperationTypeCancel cancels the workflow executions through Client.CancelWorkflow.
+	BatchOperationTypeCancel
+
+	// BatchOperationTypeSignal signals the workflow executions through Client.SignalWorkflow.
+	BatchOperationTypeSignal
+)
+
 // NewClient creates an instance of a workflow client
 func NewClient(service workflowserviceclient.Interface, domain string, options *ClientOptions) Client {
 	var identity string
@@ -398,13 +569,36 @@ func NewClient(service workflowserviceclient.Interface, domain string, options *
 	} else {
 		dataConverter = getDefaultDataConverter()
 	}
-	return &workflowClient{
-		workflowService: metrics.NewWorkflowServiceWrapper(service, metricScope),
-		domain:          domain,
-		metricsScope:    metrics.NewTaggedScope(metricScope),
-		identity:        identity,
-		dataConverter:   dataConverter,
+	var contextPropagators []ContextPropagator
+	var interceptors []ClientInterceptor
+	if options != nil {
+		contextPropagators = options.ContextPropagators
+		interceptors = options.Interceptors
+		if options.Tracer != nil {
+			contextPropagators = append(append([]ContextPropagator(nil), contextPropagators...),
+				&tracingContextPropagator{tracer: options.Tracer})
+			interceptors = append([]ClientInterceptor{&tracingClientInterceptor{tracer: options.Tracer}}, interceptors...)
+		}
+	}
+	registry := getHostEnvironment()
+	if options != nil && options.Worker != nil {
+		registry = getWorkerRegistry(options.Worker)
+	}
+	wc := &workflowClient{
+		workflowService:    metrics.NewWorkflowServiceWrapper(service, metricScope),
+		domain:             domain,
+		metricsScope:       metrics.NewTaggedScope(metricScope),
+		identity:           identity,
+		dataConverter:      dataConverter,
+		contextPropagators: contextPropagators,
+		registry:           registry,
 	}
+	var client Client = wc
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		client = interceptors[i].InterceptClient(client)
+	}
+	wc.interceptedClient = client
+	return client
 }
 
 // NewDomainClient creates an instance of a domain client, to manager lifecycle of domains.
@@ -462,3 +656,117 @@ func NewValue(data []byte) encoded.Value {
 func NewValues(data []byte) encoded.Values {
 	return newEncodedValues(data, nil)
 }
+
+// NewOpenWorkflowExecutionIterator returns an iterator over the open workflow executions matching the filter.
+// Pages are fetched from server through Client.ListOpenWorkflow as the iteration goes, transient errors are retried.
+// Example:-
+//	iter := NewOpenWorkflowExecutionIterator(ctx, client, ListWorkflowFilter{WorkflowType: "my-workflow"})
+//	for iter.HasNext() {
+//		execution, err := iter.Next()
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func NewOpenWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	return newOpenWorkflowExecutionIterator(ctx, c, filter)
+}
+
+// NewClosedWorkflowExecutionIterator returns an iterator over the closed workflow executions matching the filter.
+// Pages are fetched from server through Client.ListClosedWorkflow as the iteration goes, transient errors are retried.
+func NewClosedWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	return newClosedWorkflowExecutionIterator(ctx, c, filter)
+}
+
+// RunBatchOperation applies the operation given in options to every open workflow execution matching
+// options.Filter, and blocks until all of them are processed or ctx is done. The matching executions are all listed
+// before the operation is applied, so that closing them doesn't shift the pages still to list.
+// The returned error is non nil only if listing the executions failed or ctx is done, the errors returned
+// for individual executions are collected in BatchOperationReport.Failures instead.
+// The report of the executions processed so far is returned along with the error.
+// Example:-
+//	To terminate all open runs of a workflow type started before a deploy,
+//		report, err := RunBatchOperation(ctx, client, BatchOperationOptions{
+//			Type:        BatchOperationTypeTerminate,
+//			Filter:      ListWorkflowFilter{WorkflowType: "my-workflow", LatestStartTime: deployTime},
+//			Reason:      "bad deploy",
+//			Concurrency: 10,
+//			RPS:         50,
+//		})
+func RunBatchOperation(ctx context.Context, c Client, options BatchOperationOptions) (*BatchOperationReport, error) {
+	op, err := newBatchOperation(c, options)
+	if err != nil {
+		return nil, err
+	}
+	return op.run(ctx)
+}
+
+// GetLastDecisionTaskCompletedEventID returns the ID of the last DecisionTaskCompleted event in the history of the
+// given workflow execution. Resetting to it discards everything that happened after the last completed decision.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetLastDecisionTaskCompletedEventID(ctx context.Context, c Client, workflowID, runID string) (int64, error) {
+	var eventID int64
+	err := iterateWorkflowHistory(ctx, c, workflowID, runID, func(event *s.HistoryEvent) bool {
+		if event.GetEventType() == s.EventTypeDecisionTaskCompleted {
+			eventID = event.GetEventId()
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if eventID == 0 {
+		return 0, errors.New("no DecisionTaskCompleted event found in workflow history")
+	}
+	return eventID, nil
+}
+
+// GetFirstDecisionTaskCompletedEventIDByBinaryChecksum returns the ID of the first DecisionTaskCompleted event
+// reported by a worker with the given binary checksum. Resetting to it discards all the decisions made by that
+// binary, which is how workflows broken by a bad deployment are recovered.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetFirstDecisionTaskCompletedEventIDByBinaryChecksum(ctx context.Context, c Client, workflowID, runID, binaryChecksum string) (int64, error) {
+	var eventID int64
+	err := iterateWorkflowHistory(ctx, c, workflowID, runID, func(event *s.HistoryEvent) bool {
+		if event.GetEventType() == s.EventTypeDecisionTaskCompleted &&
+			event.DecisionTaskCompletedEventAttributes.GetBinaryChecksum() == binaryChecksum {
+			eventID = event.GetEventId()
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if eventID == 0 {
+		return 0, fmt.Errorf("no DecisionTaskCompleted event found for binary checksum %v", binaryChecksum)
+	}
+	return eventID, nil
+}
+
+// GetDecisionFinishEventID verifies that the event with the given ID exists in the history of the workflow execution
+// and is a DecisionTaskCompleted, DecisionTaskFailed or DecisionTaskTimedOut event, which are the only events a
+// workflow can be reset to. It returns the same event ID on success.
+// - runID can be default(empty string). if empty string then it will pick the last running execution of that workflow ID.
+func GetDecisionFinishEventID(ctx context.Context, c Client, workflowID, runID string, eventID int64) (int64, error) {
+	var event *s.HistoryEvent
+	err := iterateWorkflowHistory(ctx, c, workflowID, runID, func(e *s.HistoryEvent) bool {
+		if e.GetEventId() == eventID {
+			event = e
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if event == nil {
+		return 0, fmt.Errorf("event %v not found in workflow history", eventID)
+	}
+	switch event.GetEventType() {
+	case s.EventTypeDecisionTaskCompleted, s.EventTypeDecisionTaskFailed, s.EventTypeDecisionTaskTimedOut:
+		return eventID, nil
+	default:
+		return 0, fmt.Errorf("event %v is %v, not a decision finish event", eventID, event.GetEventType())
+	}
+}
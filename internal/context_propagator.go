@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import "context"
+
+type (
+	// HeaderWriter is used by a ContextPropagator to write the values it propagates.
+	HeaderWriter interface {
+		Set(key string, value []byte)
+	}
+
+	// HeaderReader is used by a ContextPropagator to read the values propagated to it.
+	HeaderReader interface {
+		ForEachKey(handler func(key string, value []byte) error) error
+	}
+
+	// ContextPropagator propagates request scoped values, like a tenant ID, tracing baggage or an auth principal, from
+	// the client to workflows and from workflows to their activities and child workflows. The values are written to
+	// the payload of the started workflow, activity or child workflow, around the arguments encoded by the
+	// DataConverter, and restored into the context of the receiving side. Propagators are configured with
+	// ClientOptions.ContextPropagators and WorkerOptions.ContextPropagators, both sides need the same propagators.
+	ContextPropagator interface {
+		// Inject writes the values of the client or activity context to propagate.
+		Inject(ctx context.Context, writer HeaderWriter) error
+
+		// Extract restores the propagated values into the context of an activity.
+		Extract(ctx context.Context, reader HeaderReader) (context.Context, error)
+
+		// InjectFromWorkflow writes the values of the workflow context to propagate.
+		InjectFromWorkflow(ctx Context, writer HeaderWriter) error
+
+		// ExtractToWorkflow restores the propagated values into the context of a workflow.
+		ExtractToWorkflow(ctx Context, reader HeaderReader) (Context, error)
+	}
+)
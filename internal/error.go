@@ -129,6 +129,9 @@ type (
 		wfn    interface{}
 		args   []interface{}
 		params *executeWorkflowParams
+		// carrySignals is set by ContinueAsNewWithSignals, the unhandled signals are added to params.input once the
+		// workflow returned the error.
+		carrySignals bool
 	}
 
 	// UnknownExternalWorkflowExecutionError can be returned when external workflow doesn't exist
@@ -209,12 +212,29 @@ func NewCanceledError(details ...interface{}) *CanceledError {
 //  args - arguments for the new workflow.
 //
 func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *ContinueAsNewError {
+	return newContinueAsNewError(ctx, false, wfn, args)
+}
+
+// ContinueAsNewWithSignals creates ContinueAsNewError instance like NewContinueAsNewError, and carries over the
+// signals the workflow has not handled yet when it returns the error to the new execution. The signals are removed
+// from their signal channels once the workflow function returned, and the new execution receives them before the
+// signals sent to it, sorted by signal name and in the order they were received for each name. Use it for long
+// running workflows that must not lose a signal received after they decided to continue as new:
+//  return workflow.ContinueAsNewWithSignals(ctx, EntityWorkflow, state)
+func ContinueAsNewWithSignals(ctx Context, wfn interface{}, args ...interface{}) *ContinueAsNewError {
+	return newContinueAsNewError(ctx, true, wfn, args)
+}
+
+func newContinueAsNewError(ctx Context, carrySignals bool, wfn interface{}, args []interface{}) *ContinueAsNewError {
 	// Validate type and its arguments.
 	options := getWorkflowEnvOptions(ctx)
 	if options == nil {
 		panic("context is missing required options for continue as new")
 	}
-	workflowType, input, err := getValidatedWorkflowFunction(wfn, args, options.dataConverter)
+	workflowType, input, err := getValidatedWorkflowFunction(wfn, args, options.dataConverter, getWorkflowEnvironment(ctx).GetRegistry())
+	if err == nil {
+		input, err = wrapWorkflowContextEnvelope(ctx, input)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -233,7 +253,7 @@ func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *C
 		workflowType:    workflowType,
 		input:           input,
 	}
-	return &ContinueAsNewError{wfn: wfn, args: args, params: params}
+	return &ContinueAsNewError{wfn: wfn, args: args, params: params, carrySignals: carrySignals}
 }
 
 // Error from error interface
@@ -86,6 +86,7 @@ type (
 		DataConverter encoded.DataConverter
 		Attempt       int32
 		ScheduledTime time.Time
+		Header        propagationHeader // values propagated from the workflow context
 	}
 
 	// asyncActivityClient for requesting activity execution
@@ -127,6 +128,7 @@ type (
 		heartbeatDetails   []byte
 		workflowType       *WorkflowType
 		workflowDomain     string
+		interceptors       []ActivityInterceptor
 	}
 
 	// context.WithValue need this type instead of basic type string to avoid lint error
@@ -279,7 +281,7 @@ func validateFunctionArgs(f interface{}, args []interface{}, isWorkflow bool) er
 	return nil
 }
 
-func getValidatedActivityFunction(f interface{}, args []interface{}, dataConverter encoded.DataConverter) (*ActivityType, []byte, error) {
+func getValidatedActivityFunction(f interface{}, args []interface{}, dataConverter encoded.DataConverter, registry *hostEnvImpl) (*ActivityType, []byte, error) {
 	fnName := ""
 	fType := reflect.TypeOf(f)
 	switch getKind(fType) {
@@ -291,7 +293,7 @@ func getValidatedActivityFunction(f interface{}, args []interface{}, dataConvert
 			return nil, nil, err
 		}
 		fnName = getFunctionName(f)
-		if alias, ok := getHostEnvironment().getActivityAlias(fnName); ok {
+		if alias, ok := registry.getActivityAlias(fnName); ok {
 			fnName = alias
 		}
 
@@ -320,6 +322,12 @@ func isActivityContext(inType reflect.Type) bool {
 }
 
 func validateFunctionAndGetResults(f interface{}, values []reflect.Value, dataConverter encoded.DataConverter) ([]byte, error) {
+	result, err := getFunctionResults(f, values)
+	return encodeFunctionResult(result, err, dataConverter)
+}
+
+// getFunctionResults returns the result and the error returned by the function f, a nil pointer result is returned as nil.
+func getFunctionResults(f interface{}, values []reflect.Value) (interface{}, error) {
 	fnName := getFunctionName(f)
 	resultSize := len(values)
 
@@ -329,17 +337,13 @@ func validateFunctionAndGetResults(f interface{}, values []reflect.Value, dataCo
 			fnName, resultSize)
 	}
 
-	var result []byte
-	var err error
+	var result interface{}
 
 	// Parse result
 	if resultSize > 1 {
 		retValue := values[0]
 		if retValue.Kind() != reflect.Ptr || !retValue.IsNil() {
-			result, err = encodeArg(dataConverter, retValue.Interface())
-			if err != nil {
-				return nil, err
-			}
+			result = retValue.Interface()
 		}
 	}
 
@@ -357,6 +361,34 @@ func validateFunctionAndGetResults(f interface{}, values []reflect.Value, dataCo
 	return result, errInterface
 }
 
+func encodeFunctionResult(result interface{}, err error, dataConverter encoded.DataConverter) ([]byte, error) {
+	var data []byte
+	if result != nil {
+		var encodeErr error
+		if data, encodeErr = encodeArg(dataConverter, result); encodeErr != nil {
+			return nil, encodeErr
+		}
+	}
+	return data, err
+}
+
+// interceptActivity executes the activity handler through the interceptors of the activity context.
+func interceptActivity(ctx context.Context, args []interface{}, handler ActivityHandler) (interface{}, error) {
+	var interceptors []ActivityInterceptor
+	if ctx != nil {
+		if env, ok := ctx.Value(activityEnvContextKey).(*activityEnvironment); ok {
+			interceptors = env.interceptors
+		}
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, args []interface{}) (interface{}, error) {
+			return interceptor.InterceptActivity(ctx, args, next)
+		}
+	}
+	return handler(ctx, args)
+}
+
 func deSerializeFnResultFromFnType(fnType reflect.Type, result []byte, to interface{}, dataConverter encoded.DataConverter) error {
 	if fnType.Kind() != reflect.Func {
 		return fmt.Errorf("expecting only function type but got type: %v", fnType)
@@ -377,7 +409,7 @@ func deSerializeFnResultFromFnType(fnType reflect.Type, result []byte, to interf
 	return nil
 }
 
-func deSerializeFunctionResult(f interface{}, result []byte, to interface{}, dataConverter encoded.DataConverter) error {
+func deSerializeFunctionResult(f interface{}, result []byte, to interface{}, dataConverter encoded.DataConverter, registry *hostEnvImpl) error {
 	fType := reflect.TypeOf(f)
 	if dataConverter == nil {
 		dataConverter = getDefaultDataConverter()
@@ -391,7 +423,7 @@ func deSerializeFunctionResult(f interface{}, result []byte, to interface{}, dat
 	case reflect.String:
 		// If we know about this function through registration then we will try to return corresponding result type.
 		fnName := reflect.ValueOf(f).String()
-		if fnRegistered, ok := getHostEnvironment().getActivityFn(fnName); ok {
+		if fnRegistered, ok := registry.getActivityFn(fnName); ok {
 			return deSerializeFnResultFromFnType(reflect.TypeOf(fnRegistered), result, to, dataConverter)
 		}
 	}
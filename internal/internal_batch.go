@@ -0,0 +1,183 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultBatchOperationConcurrency = 1
+)
+
+type (
+	// batchOperation drives one run of RunBatchOperation.
+	batchOperation struct {
+		client  Client
+		options BatchOperationOptions
+		limiter *rate.Limiter
+
+		sync.Mutex
+		report BatchOperationReport
+	}
+)
+
+func (t BatchOperationType) String() string {
+	switch t {
+	case BatchOperationTypeTerminate:
+		return "Terminate"
+	case BatchOperationTypeCancel:
+		return "Cancel"
+	case BatchOperationTypeSignal:
+		return "Signal"
+	}
+	return fmt.Sprintf("BatchOperationType(%d)", int(t))
+}
+
+func newBatchOperation(c Client, options BatchOperationOptions) (*batchOperation, error) {
+	switch options.Type {
+	case 0:
+		return nil, errors.New("missing Type for batch operation")
+	case BatchOperationTypeTerminate, BatchOperationTypeCancel:
+	case BatchOperationTypeSignal:
+		if options.SignalName == "" {
+			return nil, errors.New("missing SignalName for signal batch operation")
+		}
+	default:
+		return nil, fmt.Errorf("unknown batch operation type %v", options.Type)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaultBatchOperationConcurrency
+	}
+
+	op := &batchOperation{
+		client:  c,
+		options: options,
+	}
+	if options.RPS > 0 {
+		op.limiter = rate.NewLimiter(rate.Limit(options.RPS), 1)
+	}
+	return op, nil
+}
+
+func (op *batchOperation) run(ctx context.Context) (*BatchOperationReport, error) {
+	executionCh := make(chan WorkflowExecution)
+	var wg sync.WaitGroup
+	for i := 0; i < op.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for execution := range executionCh {
+				op.complete(execution, op.apply(ctx, execution))
+			}
+		}()
+	}
+
+	err := op.dispatch(ctx, executionCh)
+	close(executionCh)
+	wg.Wait()
+
+	op.Lock()
+	defer op.Unlock()
+	report := op.report
+	return &report, err
+}
+
+// dispatch feeds the matching executions to the workers until all of them are fed or ctx is done.
+func (op *batchOperation) dispatch(ctx context.Context, executionCh chan<- WorkflowExecution) error {
+	executions, err := op.list(ctx)
+	if err != nil {
+		return err
+	}
+	for _, execution := range executions {
+		if op.limiter != nil && !op.options.DryRun {
+			if err := op.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		select {
+		case executionCh <- execution:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// list returns all the matching executions. Closing executions while listing them would shift the pages still to
+// list, and skip executions.
+func (op *batchOperation) list(ctx context.Context) ([]WorkflowExecution, error) {
+	var executions []WorkflowExecution
+	iter := newOpenWorkflowExecutionIterator(ctx, op.client, op.options.Filter)
+	for iter.HasNext() {
+		info, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, WorkflowExecution{
+			ID:    info.Execution.GetWorkflowId(),
+			RunID: info.Execution.GetRunId(),
+		})
+	}
+	return executions, nil
+}
+
+func (op *batchOperation) apply(ctx context.Context, execution WorkflowExecution) error {
+	if op.options.DryRun {
+		return nil
+	}
+	switch op.options.Type {
+	case BatchOperationTypeTerminate:
+		return op.client.TerminateWorkflow(ctx, execution.ID, execution.RunID, op.options.Reason, op.options.Details)
+	case BatchOperationTypeCancel:
+		return op.client.CancelWorkflow(ctx, execution.ID, execution.RunID)
+	case BatchOperationTypeSignal:
+		return op.client.SignalWorkflow(ctx, execution.ID, execution.RunID, op.options.SignalName, op.options.SignalArg)
+	}
+	panic(fmt.Sprintf("unknown batch operation type %v", op.options.Type))
+}
+
+func (op *batchOperation) complete(execution WorkflowExecution, err error) {
+	op.Lock()
+	op.report.Processed++
+	if err != nil {
+		op.report.Failures = append(op.report.Failures, BatchOperationFailure{Execution: execution, Err: err})
+	} else {
+		op.report.Succeeded++
+	}
+	progress := BatchOperationProgress{
+		Execution: execution,
+		Err:       err,
+		Processed: op.report.Processed,
+		Failed:    len(op.report.Failures),
+	}
+	op.Unlock()
+
+	// callback is invoked without holding the lock so that a slow callback doesn't block the other workers
+	if op.options.ProgressCallback != nil {
+		op.options.ProgressCallback(progress)
+	}
+}
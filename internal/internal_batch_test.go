@@ -0,0 +1,179 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+)
+
+func newTestBatchClient(t *testing.T, executionIDs ...string) (*workflowservicetest.MockClient, Client, *gomock.Controller) {
+	mockCtrl := gomock.NewController(t)
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	var executions []*shared.WorkflowExecutionInfo
+	for _, id := range executionIDs {
+		executions = append(executions, &shared.WorkflowExecutionInfo{
+			Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(id), RunId: common.StringPtr(runID)},
+		})
+	}
+	service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&shared.ListOpenWorkflowExecutionsResponse{Executions: executions}, nil).Times(1)
+	return service, NewClient(service, domain, nil), mockCtrl
+}
+
+func TestBatchOperation_Terminate(t *testing.T) {
+	service, client, mockCtrl := newTestBatchClient(t, "wid1", "wid2", "wid3")
+	defer mockCtrl.Finish()
+
+	failure := &shared.EntityNotExistsError{}
+	service.EXPECT().TerminateWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *shared.TerminateWorkflowExecutionRequest, _ ...interface{}) error {
+			require.Equal(t, runID, req.WorkflowExecution.GetRunId())
+			require.Equal(t, "bad deploy", req.GetReason())
+			if req.WorkflowExecution.GetWorkflowId() == "wid2" {
+				return failure
+			}
+			return nil
+		}).Times(3)
+
+	var lock sync.Mutex
+	var progress []BatchOperationProgress
+	report, err := RunBatchOperation(context.Background(), client, BatchOperationOptions{
+		Type:        BatchOperationTypeTerminate,
+		Reason:      "bad deploy",
+		Concurrency: 2,
+		RPS:         100,
+		ProgressCallback: func(p BatchOperationProgress) {
+			lock.Lock()
+			defer lock.Unlock()
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Processed)
+	require.Equal(t, 2, report.Succeeded)
+	require.Equal(t, []BatchOperationFailure{{Execution: WorkflowExecution{ID: "wid2", RunID: runID}, Err: failure}}, report.Failures)
+
+	require.Equal(t, 3, len(progress))
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Processed < progress[j].Processed })
+	var ids []string
+	for i, p := range progress {
+		require.Equal(t, i+1, p.Processed)
+		ids = append(ids, p.Execution.ID)
+	}
+	sort.Strings(ids)
+	require.Equal(t, []string{"wid1", "wid2", "wid3"}, ids)
+	require.Equal(t, 1, progress[2].Failed)
+}
+
+func TestBatchOperation_ListBeforeApply(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	page := func(id string, token []byte) *shared.ListOpenWorkflowExecutionsResponse {
+		return &shared.ListOpenWorkflowExecutionsResponse{
+			Executions: []*shared.WorkflowExecutionInfo{{
+				Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(id), RunId: common.StringPtr(runID)},
+			}},
+			NextPageToken: token,
+		}
+	}
+	gomock.InOrder(
+		service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).Return(page("wid1", []byte{1}), nil),
+		service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).Return(page("wid2", nil), nil),
+		service.EXPECT().RequestCancelWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2),
+	)
+
+	report, err := RunBatchOperation(context.Background(), NewClient(service, domain, nil), BatchOperationOptions{
+		Type: BatchOperationTypeCancel,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Succeeded)
+}
+
+func TestBatchOperation_Signal(t *testing.T) {
+	service, client, mockCtrl := newTestBatchClient(t, "wid1")
+	defer mockCtrl.Finish()
+
+	service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *shared.SignalWorkflowExecutionRequest, _ ...interface{}) error {
+			require.Equal(t, "wid1", req.WorkflowExecution.GetWorkflowId())
+			require.Equal(t, "my-signal", req.GetSignalName())
+			return nil
+		}).Times(1)
+
+	report, err := RunBatchOperation(context.Background(), client, BatchOperationOptions{
+		Type:       BatchOperationTypeSignal,
+		SignalName: "my-signal",
+		SignalArg:  "arg",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Succeeded)
+}
+
+func TestBatchOperation_DryRun(t *testing.T) {
+	_, client, mockCtrl := newTestBatchClient(t, "wid1", "wid2")
+	defer mockCtrl.Finish()
+
+	// no cancel request is expected by the mock
+	report, err := RunBatchOperation(context.Background(), client, BatchOperationOptions{
+		Type:   BatchOperationTypeCancel,
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Processed)
+	require.Equal(t, 2, report.Succeeded)
+	require.Empty(t, report.Failures)
+}
+
+func TestBatchOperation_ListError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	service := workflowservicetest.NewMockClient(mockCtrl)
+	service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, &shared.BadRequestError{}).Times(1)
+
+	report, err := RunBatchOperation(context.Background(), NewClient(service, domain, nil), BatchOperationOptions{
+		Type: BatchOperationTypeCancel,
+	})
+	require.Equal(t, &shared.BadRequestError{}, err)
+	require.Equal(t, 0, report.Processed)
+}
+
+func TestBatchOperation_InvalidOptions(t *testing.T) {
+	_, err := RunBatchOperation(context.Background(), nil, BatchOperationOptions{})
+	require.Equal(t, errors.New("missing Type for batch operation"), err)
+
+	_, err = RunBatchOperation(context.Background(), nil, BatchOperationOptions{Type: BatchOperationTypeSignal})
+	require.Equal(t, errors.New("missing SignalName for signal batch operation"), err)
+
+	_, err = RunBatchOperation(context.Background(), nil, BatchOperationOptions{Type: BatchOperationType(100)})
+	require.Error(t, err)
+}
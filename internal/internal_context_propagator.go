@@ -0,0 +1,200 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// contextEnvelopePrefix marks a payload that carries propagated context values around the arguments encoded by the
+// DataConverter. Payloads without the prefix are the encoded arguments only.
+var contextEnvelopePrefix = []byte("\x00cadence-context\x00")
+
+type (
+	// propagationHeader holds the values written by the context propagators.
+	propagationHeader map[string][]byte
+
+	// contextEnvelope is the payload of a workflow, activity or child workflow that has propagated context values.
+	// The payload of a workflow continued as new by ContinueAsNewWithSignals carries the unhandled signals too.
+	contextEnvelope struct {
+		Header  propagationHeader `json:"header"`
+		Payload []byte            `json:"payload"`
+		Signals []carriedSignal   `json:"signals,omitempty"`
+	}
+
+	// carriedSignal is a signal carried over to the next run of a workflow.
+	carriedSignal struct {
+		Name  string `json:"name"`
+		Input []byte `json:"input"`
+	}
+)
+
+func (h propagationHeader) Set(key string, value []byte) {
+	h[key] = value
+}
+
+func (h propagationHeader) ForEachKey(handler func(key string, value []byte) error) error {
+	for key, value := range h {
+		if err := handler(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// injectContext returns the values of the client or activity context to propagate.
+func injectContext(ctx context.Context, propagators []ContextPropagator) (propagationHeader, error) {
+	header := propagationHeader{}
+	for _, p := range propagators {
+		if err := p.Inject(ctx, header); err != nil {
+			return nil, fmt.Errorf("unable to inject context: %v", err)
+		}
+	}
+	return header, nil
+}
+
+// injectWorkflowContext returns the values of the workflow context to propagate.
+func injectWorkflowContext(ctx Context, propagators []ContextPropagator) (propagationHeader, error) {
+	header := propagationHeader{}
+	for _, p := range propagators {
+		if err := p.InjectFromWorkflow(ctx, header); err != nil {
+			return nil, fmt.Errorf("unable to inject workflow context: %v", err)
+		}
+	}
+	return header, nil
+}
+
+// extractContext restores the propagated values into the context of an activity.
+func extractContext(ctx context.Context, header propagationHeader, propagators []ContextPropagator) (context.Context, error) {
+	for _, p := range propagators {
+		var err error
+		if ctx, err = p.Extract(ctx, header); err != nil {
+			return nil, fmt.Errorf("unable to extract context: %v", err)
+		}
+	}
+	return ctx, nil
+}
+
+// extractWorkflowContext restores the propagated values into the context of a workflow.
+func extractWorkflowContext(ctx Context, header propagationHeader, propagators []ContextPropagator) (Context, error) {
+	for _, p := range propagators {
+		var err error
+		if ctx, err = p.ExtractToWorkflow(ctx, header); err != nil {
+			return nil, fmt.Errorf("unable to extract workflow context: %v", err)
+		}
+	}
+	return ctx, nil
+}
+
+// wrapContextEnvelope returns the payload with the propagated values, or the payload itself if there is none.
+func wrapContextEnvelope(header propagationHeader, payload []byte) ([]byte, error) {
+	return encodeContextEnvelope(contextEnvelope{Header: header, Payload: payload})
+}
+
+// encodeContextEnvelope returns the payload of envelope with its propagated values and signals, or the payload itself
+// if there is none.
+func encodeContextEnvelope(envelope contextEnvelope) ([]byte, error) {
+	if len(envelope.Header) == 0 && len(envelope.Signals) == 0 {
+		return envelope.Payload, nil
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode context envelope: %v", err)
+	}
+	return append(append([]byte{}, contextEnvelopePrefix...), data...), nil
+}
+
+// unwrapContextEnvelope returns the propagated values and the payload of data. The header is nil if data doesn't
+// carry propagated values.
+func unwrapContextEnvelope(data []byte) (propagationHeader, []byte, error) {
+	envelope, err := decodeContextEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return envelope.Header, envelope.Payload, nil
+}
+
+// decodeContextEnvelope returns the envelope of data. The envelope only has a payload if data isn't one.
+func decodeContextEnvelope(data []byte) (contextEnvelope, error) {
+	var envelope contextEnvelope
+	if !bytes.HasPrefix(data, contextEnvelopePrefix) {
+		envelope.Payload = data
+		return envelope, nil
+	}
+	if err := json.Unmarshal(data[len(contextEnvelopePrefix):], &envelope); err != nil {
+		return envelope, fmt.Errorf("unable to decode context envelope: %v", err)
+	}
+	return envelope, nil
+}
+
+// wrapWorkflowContextEnvelope returns the payload with the values of the workflow context to propagate.
+func wrapWorkflowContextEnvelope(ctx Context, payload []byte) ([]byte, error) {
+	propagators := getWorkflowEnvironment(ctx).GetContextPropagators()
+	if len(propagators) == 0 {
+		return payload, nil
+	}
+	header, err := injectWorkflowContext(ctx, propagators)
+	if err != nil {
+		return nil, err
+	}
+	return wrapContextEnvelope(header, payload)
+}
+
+// wrapContextEnvelopeSignals returns data with the signals to carry over to the next run of the workflow.
+func wrapContextEnvelopeSignals(data []byte, signals []carriedSignal) ([]byte, error) {
+	envelope, err := decodeContextEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Signals = append(envelope.Signals, signals...)
+	return encodeContextEnvelope(envelope)
+}
+
+// unwrapWorkflowContextEnvelope returns the workflow context with the values propagated in data, and the payload of data.
+func unwrapWorkflowContextEnvelope(ctx Context, data []byte) (Context, []byte, error) {
+	header, payload, err := unwrapContextEnvelope(data)
+	if err != nil || header == nil {
+		return ctx, payload, err
+	}
+	ctx, err = extractWorkflowContext(ctx, header, getWorkflowEnvironment(ctx).GetContextPropagators())
+	return ctx, payload, err
+}
+
+// unwrapActivityContextEnvelope returns the activity context with the values propagated in data, and the payload of data.
+func unwrapActivityContextEnvelope(ctx context.Context, data []byte, propagators []ContextPropagator) (context.Context, []byte, error) {
+	header, payload, err := unwrapContextEnvelope(data)
+	if err != nil || header == nil {
+		return ctx, payload, err
+	}
+	ctx, err = extractContext(ctx, header, propagators)
+	return ctx, payload, err
+}
+
+// contextEnvelopePayload returns the payload of data without the propagated values, or data if it can't be decoded.
+func contextEnvelopePayload(data []byte) []byte {
+	if _, payload, err := unwrapContextEnvelope(data); err == nil {
+		return payload
+	}
+	return data
+}
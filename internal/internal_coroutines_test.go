@@ -638,6 +638,248 @@ func TestPanic(t *testing.T) {
 	require.Contains(t, err.StackTrace(), "cadence/internal.TestPanic")
 }
 
+func TestWaitGroup(t *testing.T) {
+	var history []string
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		wg := NewNamedWaitGroup(ctx, "children")
+		c := NewNamedChannel(ctx, "release")
+		for i := 0; i < 3; i++ {
+			ii := i
+			wg.Add(1)
+			GoNamed(ctx, fmt.Sprintf("c-%v", i), func(ctx Context) {
+				defer wg.Done()
+				c.Receive(ctx, nil)
+				history = append(history, fmt.Sprintf("child-%v", ii))
+			})
+		}
+		assert.NoError(t, wg.Wait(ctx))
+	})
+	d.ExecuteUntilAllBlocked()
+	require.False(t, d.IsDone())
+	require.Contains(t, d.StackTrace(), "coroutine 1 [blocked on children.Wait]:")
+	d.Close()
+
+	history = nil
+	d, _ = newDispatcher(createRootTestContext(), func(ctx Context) {
+		wg := NewWaitGroup(ctx)
+		for i := 0; i < 3; i++ {
+			ii := i
+			wg.Add(1)
+			Go(ctx, func(ctx Context) {
+				defer wg.Done()
+				history = append(history, fmt.Sprintf("child-%v", ii))
+			})
+		}
+		assert.NoError(t, wg.Wait(ctx))
+		history = append(history, "root-done")
+	})
+	d.ExecuteUntilAllBlocked()
+	require.True(t, d.IsDone(), d.StackTrace())
+	require.EqualValues(t, []string{"child-0", "child-1", "child-2", "root-done"}, history)
+}
+
+func TestMutex(t *testing.T) {
+	var history []string
+	var release Channel
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		m := NewNamedMutex(ctx, "state")
+		release = NewNamedChannel(ctx, "release")
+		done := NewWaitGroup(ctx)
+		for i := 0; i < 3; i++ {
+			ii := i
+			done.Add(1)
+			GoNamed(ctx, fmt.Sprintf("c-%v", i), func(ctx Context) {
+				defer done.Done()
+				assert.NoError(t, m.Lock(ctx))
+				history = append(history, fmt.Sprintf("lock-%v", ii))
+				release.Receive(ctx, nil)
+				history = append(history, fmt.Sprintf("unlock-%v", ii))
+				m.Unlock()
+			})
+		}
+		assert.NoError(t, done.Wait(ctx))
+		assert.True(t, m.TryLock())
+		assert.False(t, m.TryLock())
+		m.Unlock()
+		assert.Panics(t, m.Unlock)
+	})
+	d.ExecuteUntilAllBlocked()
+	require.False(t, d.IsDone())
+	stack := d.StackTrace()
+	require.Contains(t, stack, "coroutine c-1 [blocked on state.Lock]:")
+	require.Contains(t, stack, "coroutine c-2 [blocked on state.Lock]:")
+	for i := 0; i < 3; i++ {
+		release.SendAsync(nil)
+		d.ExecuteUntilAllBlocked()
+	}
+	require.True(t, d.IsDone(), d.StackTrace())
+	expected := []string{
+		"lock-0",
+		"unlock-0",
+		"lock-1",
+		"unlock-1",
+		"lock-2",
+		"unlock-2",
+	}
+	require.EqualValues(t, expected, history)
+}
+
+func TestSemaphore(t *testing.T) {
+	var history []string
+	var release Channel
+	var cancel CancelFunc
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		s := NewNamedSemaphore(ctx, "workers", 2)
+		release = NewNamedChannel(ctx, "release")
+		for i := 0; i < 3; i++ {
+			ii := i
+			GoNamed(ctx, fmt.Sprintf("c-%v", i), func(ctx Context) {
+				assert.NoError(t, s.Acquire(ctx))
+				history = append(history, fmt.Sprintf("acquired-%v", ii))
+				release.Receive(ctx, nil)
+				s.Release()
+			})
+		}
+		GoNamed(ctx, "canceled", func(ctx Context) {
+			ctx, cancel = WithCancel(ctx)
+			err := s.Acquire(ctx)
+			history = append(history, fmt.Sprintf("canceled-%v", err == ErrCanceled))
+		})
+		assert.Panics(t, func() { NewNamedSemaphore(ctx, "empty", 0) })
+	})
+	d.ExecuteUntilAllBlocked()
+	require.False(t, d.IsDone())
+	stack := d.StackTrace()
+	require.Contains(t, stack, "coroutine c-2 [blocked on workers.Acquire]:")
+	require.Contains(t, stack, "coroutine canceled [blocked on workers.Acquire]:")
+
+	// a canceled waiter gives up its place in the queue
+	cancel()
+	d.ExecuteUntilAllBlocked()
+	release.SendAsync(nil)
+	d.ExecuteUntilAllBlocked()
+	expected := []string{
+		"acquired-0",
+		"acquired-1",
+		"canceled-true",
+		"acquired-2",
+	}
+	require.EqualValues(t, expected, history)
+
+	release.SendAsync(nil)
+	d.ExecuteUntilAllBlocked()
+	release.SendAsync(nil)
+	d.ExecuteUntilAllBlocked()
+	require.True(t, d.IsDone(), d.StackTrace())
+}
+
+func TestCanceledWaitersMakeProgress(t *testing.T) {
+	var history []string
+	var cancelLock, cancelWait CancelFunc
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		held := NewNamedMutex(ctx, "held")
+		assert.True(t, held.TryLock())
+		pending := NewNamedWaitGroup(ctx, "pending")
+		pending.Add(1)
+		released1 := NewNamedMutex(ctx, "released1")
+		assert.True(t, released1.TryLock())
+		released2 := NewNamedMutex(ctx, "released2")
+		assert.True(t, released2.TryLock())
+		block := NewNamedChannel(ctx, "block")
+
+		// the coroutines blocked on the released mutexes run before the canceled ones in every pass of the
+		// dispatcher, they only get the mutexes if the dispatcher makes another pass
+		GoNamed(ctx, "waiter-1", func(ctx Context) {
+			assert.NoError(t, released1.Lock(ctx))
+			history = append(history, "locked-1")
+		})
+		GoNamed(ctx, "waiter-2", func(ctx Context) {
+			assert.NoError(t, released2.Lock(ctx))
+			history = append(history, "locked-2")
+		})
+		GoNamed(ctx, "canceled-lock", func(ctx Context) {
+			var canceledCtx Context
+			canceledCtx, cancelLock = WithCancel(ctx)
+			assert.Equal(t, ErrCanceled, held.Lock(canceledCtx))
+			released1.Unlock()
+			block.Receive(ctx, nil)
+		})
+		GoNamed(ctx, "canceled-wait", func(ctx Context) {
+			var canceledCtx Context
+			canceledCtx, cancelWait = WithCancel(ctx)
+			assert.Equal(t, ErrCanceled, pending.Wait(canceledCtx))
+			released2.Unlock()
+			block.Receive(ctx, nil)
+		})
+	})
+	defer d.Close()
+	d.ExecuteUntilAllBlocked()
+	require.Empty(t, history)
+
+	cancelLock()
+	d.ExecuteUntilAllBlocked()
+	require.EqualValues(t, []string{"locked-1"}, history)
+
+	cancelWait()
+	d.ExecuteUntilAllBlocked()
+	require.EqualValues(t, []string{"locked-1", "locked-2"}, history)
+}
+
+func TestGroup(t *testing.T) {
+	var history []string
+	var release Channel
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		release = NewNamedChannel(ctx, "release")
+		g := NewGroup(ctx)
+		g.SetLimit(2)
+		for i := 0; i < 3; i++ {
+			ii := i
+			g.Go(func(ctx Context) error {
+				history = append(history, fmt.Sprintf("started-%v", ii))
+				var err error
+				NewSelector(ctx).
+					AddReceive(ctx.Done(), func(c Channel, more bool) {
+						err = ctx.Err()
+					}).
+					AddReceive(release, func(c Channel, more bool) {
+						var v string
+						c.Receive(ctx, &v)
+						if v == "fail" {
+							err = fmt.Errorf("failed-%v", ii)
+						}
+					}).
+					Select(ctx)
+				history = append(history, fmt.Sprintf("returned-%v-%v", ii, err))
+				return err
+			})
+		}
+		assert.Panics(t, func() { g.SetLimit(1) })
+		err := g.Wait(ctx)
+		history = append(history, fmt.Sprintf("wait-%v", err))
+	})
+	d.ExecuteUntilAllBlocked()
+	require.False(t, d.IsDone())
+	require.Contains(t, d.StackTrace(), "[blocked on group-1.Acquire]:")
+	require.Contains(t, d.StackTrace(), "coroutine 1 [blocked on group-1.Wait]:")
+
+	release.SendAsync("ok")
+	d.ExecuteUntilAllBlocked()
+	release.SendAsync("fail")
+	d.ExecuteUntilAllBlocked()
+	require.True(t, d.IsDone(), d.StackTrace())
+
+	expected := []string{
+		"started-0",
+		"started-1",
+		"returned-0-<nil>",
+		"started-2",
+		"returned-1-failed-1",
+		"returned-2-CanceledError",
+		"wait-failed-1",
+	}
+	require.EqualValues(t, expected, history)
+}
+
 func TestFutureSetValue(t *testing.T) {
 	var history []string
 	var f Future
@@ -815,6 +1057,55 @@ func TestFutureSet(t *testing.T) {
 	require.EqualValues(t, expected, history)
 }
 
+func TestFutureCombinators(t *testing.T) {
+	var history []string
+	var s1, s2, s3 Settable
+	d, _ := newDispatcher(createRootTestContext(), func(ctx Context) {
+		var f1, f2, f3 Future
+		f1, s1 = NewFuture(ctx)
+		f2, s2 = NewFuture(ctx)
+		f3, s3 = NewFuture(ctx)
+		assert.NoError(t, AllOf(ctx).Get(ctx, nil))
+		length := Then(ctx, f1, func(ctx Context, f Future) (interface{}, error) {
+			var v string
+			if err := f.Get(ctx, &v); err != nil {
+				return nil, err
+			}
+			return len(v), nil
+		})
+
+		var v string
+		assert.NoError(t, AnyOf(ctx, f1, f2).Get(ctx, &v))
+		assert.True(t, f2.IsReady())
+		history = append(history, fmt.Sprintf("any-%v", v))
+
+		all := AllOf(ctx, f1, f2, f3)
+		err := all.Get(ctx, nil)
+		assert.False(t, f1.IsReady())
+		history = append(history, fmt.Sprintf("all-%v", err))
+
+		assert.NoError(t, AllOf(ctx, f2).Get(ctx, nil))
+		var n int
+		assert.NoError(t, length.Get(ctx, &n))
+		history = append(history, fmt.Sprintf("then-%v", n))
+	})
+	d.ExecuteUntilAllBlocked()
+	s2.SetValue("value2")
+	d.ExecuteUntilAllBlocked()
+	s3.SetError(errors.New("error3"))
+	d.ExecuteUntilAllBlocked()
+	s1.SetValue("value1")
+	d.ExecuteUntilAllBlocked()
+	require.True(t, d.IsDone(), d.StackTrace())
+
+	expected := []string{
+		"any-value2",
+		"all-error3",
+		"then-6",
+	}
+	require.EqualValues(t, expected, history)
+}
+
 func TestFutureChain(t *testing.T) {
 	var history []string
 	var f1, cf1, f2, cf2 Future
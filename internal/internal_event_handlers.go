@@ -106,9 +106,11 @@ type (
 		isReplay              bool // flag to indicate if workflow is in replay mode
 		enableLoggingInReplay bool // flag to indicate if workflow should enable logging in replay mode
 
-		metricsScope  tally.Scope
-		hostEnv       *hostEnvImpl
-		dataConverter encoded.DataConverter
+		metricsScope         tally.Scope
+		hostEnv              *hostEnvImpl
+		dataConverter        encoded.DataConverter
+		workflowInterceptors []WorkflowInterceptor
+		contextPropagators   []ContextPropagator
 	}
 
 	localActivityTask struct {
@@ -170,6 +172,8 @@ func newWorkflowExecutionEventHandler(
 	scope tally.Scope,
 	hostEnv *hostEnvImpl,
 	dataConverter encoded.DataConverter,
+	workflowInterceptors []WorkflowInterceptor,
+	contextPropagators []ContextPropagator,
 ) workflowExecutionEventHandler {
 	context := &workflowEnvironmentImpl{
 		workflowInfo:          workflowInfo,
@@ -183,6 +187,8 @@ func newWorkflowExecutionEventHandler(
 		enableLoggingInReplay: enableLoggingInReplay,
 		hostEnv:               hostEnv,
 		dataConverter:         dataConverter,
+		workflowInterceptors:  workflowInterceptors,
+		contextPropagators:    contextPropagators,
 	}
 	context.logger = logger.With(
 		zapcore.Field{Key: tagWorkflowType, Type: zapcore.StringType, String: workflowInfo.WorkflowType.Name},
@@ -339,6 +345,18 @@ func (wc *workflowEnvironmentImpl) GetDataConverter() encoded.DataConverter {
 	return wc.dataConverter
 }
 
+func (wc *workflowEnvironmentImpl) GetRegistry() *hostEnvImpl {
+	return wc.hostEnv
+}
+
+func (wc *workflowEnvironmentImpl) GetWorkflowInterceptors() []WorkflowInterceptor {
+	return wc.workflowInterceptors
+}
+
+func (wc *workflowEnvironmentImpl) GetContextPropagators() []ContextPropagator {
+	return wc.contextPropagators
+}
+
 func (wc *workflowEnvironmentImpl) IsReplaying() bool {
 	return wc.isReplay
 }
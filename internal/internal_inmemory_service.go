@@ -0,0 +1,2018 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+// All code in this file is private to the package.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/yarpc"
+)
+
+const (
+	defaultInMemoryServiceLongPollTimeout = time.Minute
+	defaultInMemoryServiceDecisionTimeout = 10 * time.Second
+)
+
+type (
+	// inMemoryService is an in-memory implementation of workflowserviceclient.Interface. All state is guarded by the
+	// embedded mutex, long polls wait on the notify channels of task lists and executions.
+	inMemoryService struct {
+		sync.Mutex
+		longPollTimeout time.Duration
+		isClosed        bool
+		closeCh         chan struct{}
+
+		domains    map[string]*s.DescribeDomainResponse
+		executions map[inMemoryExecutionKey]*inMemoryExecution
+		current    map[inMemoryWorkflowKey]*inMemoryExecution
+		taskLists  map[inMemoryTaskListKey]*inMemoryTaskList
+		queries    map[int64]*inMemoryQuery
+		nextID     int64
+	}
+
+	inMemoryWorkflowKey struct {
+		domain     string
+		workflowID string
+	}
+
+	inMemoryExecutionKey struct {
+		domain     string
+		workflowID string
+		runID      string
+	}
+
+	inMemoryTaskListKey struct {
+		domain       string
+		name         string
+		taskListType s.TaskListType
+	}
+
+	// inMemoryTaskList holds the tasks of a task list. A task starts itself when polled and returns the poll response, or
+	// nil when it went stale in the meantime.
+	inMemoryTaskList struct {
+		tasks   []func(identity string) interface{}
+		notify  chan struct{}
+		pollers map[string]time.Time
+	}
+
+	// inMemoryTaskToken is the json encoded task token of decision, activity and query tasks.
+	inMemoryTaskToken struct {
+		Domain     string `json:"domain"`
+		WorkflowID string `json:"workflowID"`
+		RunID      string `json:"runID"`
+		ScheduleID int64  `json:"scheduleID,omitempty"`
+		Attempt    int64  `json:"attempt,omitempty"`
+		QueryID    int64  `json:"queryID,omitempty"`
+	}
+
+	inMemoryExecution struct {
+		domain      string
+		execution   *s.WorkflowExecution
+		attributes  *s.WorkflowExecutionStartedEventAttributes
+		requestID   string
+		startTime   time.Time
+		closeTime   time.Time
+		closeStatus *s.WorkflowExecutionCloseStatus
+
+		history []*s.HistoryEvent
+		// buffered are the events that happened while a decision task was in flight, they are added to the history
+		// once the decision task is closed.
+		buffered []*s.HistoryEvent
+		notify   chan struct{}
+
+		decisionScheduledID    int64
+		decisionStartedID      int64
+		decisionAttempt        int64
+		previousStartedEventID int64
+
+		activities      map[int64]*inMemoryActivity
+		activityIDs     map[string]int64
+		timers          map[string]*inMemoryTimer
+		children        map[int64]*inMemoryChild
+		cancelRequested bool
+
+		parent            *inMemoryExecution
+		parentInitiatedID int64
+	}
+
+	inMemoryActivity struct {
+		scheduledID    int64
+		attributes     *s.ActivityTaskScheduledEventAttributes
+		scheduledTime  time.Time
+		attemptTime    time.Time
+		attempt        int32
+		started        bool
+		startedTime    time.Time
+		identity       string
+		details        []byte
+		heartbeatTime  time.Time
+		cancelID       int64
+		heartbeatTimer *time.Timer
+	}
+
+	inMemoryTimer struct {
+		startedID int64
+		timer     *time.Timer
+	}
+
+	inMemoryChild struct {
+		domain       string
+		workflowType *s.WorkflowType
+		startedID    *int64
+	}
+
+	inMemoryQuery struct {
+		resultCh chan *s.RespondQueryTaskCompletedRequest
+	}
+)
+
+var _ workflowserviceclient.Interface = (*inMemoryService)(nil)
+
+func newInMemoryService(options TestServiceOptions) *inMemoryService {
+	longPollTimeout := options.LongPollTimeout
+	if longPollTimeout <= 0 {
+		longPollTimeout = defaultInMemoryServiceLongPollTimeout
+	}
+	return &inMemoryService{
+		longPollTimeout: longPollTimeout,
+		closeCh:         make(chan struct{}),
+		domains:         make(map[string]*s.DescribeDomainResponse),
+		executions:      make(map[inMemoryExecutionKey]*inMemoryExecution),
+		current:         make(map[inMemoryWorkflowKey]*inMemoryExecution),
+		taskLists:       make(map[inMemoryTaskListKey]*inMemoryTaskList),
+		queries:         make(map[int64]*inMemoryQuery),
+	}
+}
+
+// Close releases the pending long polls and stops the timers of the service.
+func (ts *inMemoryService) Close() {
+	ts.Lock()
+	defer ts.Unlock()
+	if !ts.isClosed {
+		ts.isClosed = true
+		close(ts.closeCh)
+	}
+}
+
+// afterFunc runs fn under the service lock once d elapsed, unless the service is closed by then.
+func (ts *inMemoryService) afterFunc(d time.Duration, fn func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		ts.Lock()
+		defer ts.Unlock()
+		if !ts.isClosed {
+			fn()
+		}
+	})
+}
+
+func (ts *inMemoryService) newID() int64 {
+	ts.nextID++
+	return ts.nextID
+}
+
+// Domains
+
+func (ts *inMemoryService) RegisterDomain(ctx context.Context, request *s.RegisterDomainRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	name := request.GetName()
+	if name == "" {
+		return &s.BadRequestError{Message: "Domain name is not set on request."}
+	}
+	if _, ok := ts.domains[name]; ok {
+		return &s.DomainAlreadyExistsError{Message: "Domain already exists."}
+	}
+	ts.domains[name] = &s.DescribeDomainResponse{
+		DomainInfo: &s.DomainInfo{
+			Name:        common.StringPtr(name),
+			Status:      s.DomainStatusRegistered.Ptr(),
+			Description: request.Description,
+			OwnerEmail:  request.OwnerEmail,
+			Data:        request.Data,
+		},
+		Configuration: &s.DomainConfiguration{
+			WorkflowExecutionRetentionPeriodInDays: request.WorkflowExecutionRetentionPeriodInDays,
+			EmitMetric:                             request.EmitMetric,
+			ArchivalStatus:                         request.ArchivalStatus,
+			ArchivalBucketName:                     request.ArchivalBucketName,
+		},
+		ReplicationConfiguration: &s.DomainReplicationConfiguration{
+			ActiveClusterName: request.ActiveClusterName,
+			Clusters:          request.Clusters,
+		},
+		FailoverVersion: common.Int64Ptr(0),
+		IsGlobalDomain:  common.BoolPtr(false),
+	}
+	return nil
+}
+
+func (ts *inMemoryService) DescribeDomain(ctx context.Context, request *s.DescribeDomainRequest, opts ...yarpc.CallOption) (*s.DescribeDomainResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	domain, err := ts.getDomain(request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	response := *domain
+	return &response, nil
+}
+
+func (ts *inMemoryService) ListDomains(ctx context.Context, request *s.ListDomainsRequest, opts ...yarpc.CallOption) (*s.ListDomainsResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	var names []string
+	for name := range ts.domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	start, end, nextPageToken := inMemoryPage(len(names), request.GetPageSize(), request.NextPageToken)
+	response := &s.ListDomainsResponse{NextPageToken: nextPageToken}
+	for _, name := range names[start:end] {
+		domain := *ts.domains[name]
+		response.Domains = append(response.Domains, &domain)
+	}
+	return response, nil
+}
+
+func (ts *inMemoryService) UpdateDomain(ctx context.Context, request *s.UpdateDomainRequest, opts ...yarpc.CallOption) (*s.UpdateDomainResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	domain, err := ts.getDomain(request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	info := *domain.DomainInfo
+	if updated := request.UpdatedInfo; updated != nil {
+		if updated.Description != nil {
+			info.Description = updated.Description
+		}
+		if updated.OwnerEmail != nil {
+			info.OwnerEmail = updated.OwnerEmail
+		}
+		if updated.Data != nil {
+			info.Data = updated.Data
+		}
+	}
+	domain.DomainInfo = &info
+	if request.Configuration != nil {
+		domain.Configuration = request.Configuration
+	}
+	if request.ReplicationConfiguration != nil {
+		domain.ReplicationConfiguration = request.ReplicationConfiguration
+	}
+	return &s.UpdateDomainResponse{
+		DomainInfo:               domain.DomainInfo,
+		Configuration:            domain.Configuration,
+		ReplicationConfiguration: domain.ReplicationConfiguration,
+		FailoverVersion:          domain.FailoverVersion,
+		IsGlobalDomain:           domain.IsGlobalDomain,
+	}, nil
+}
+
+func (ts *inMemoryService) DeprecateDomain(ctx context.Context, request *s.DeprecateDomainRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	domain, err := ts.getDomain(request.GetName())
+	if err != nil {
+		return err
+	}
+	info := *domain.DomainInfo
+	info.Status = s.DomainStatusDeprecated.Ptr()
+	domain.DomainInfo = &info
+	return nil
+}
+
+func (ts *inMemoryService) getDomain(name string) (*s.DescribeDomainResponse, error) {
+	domain, ok := ts.domains[name]
+	if !ok {
+		return nil, &s.EntityNotExistsError{Message: fmt.Sprintf("Domain: %s does not exist.", name)}
+	}
+	return domain, nil
+}
+
+// Workflow executions
+
+func (ts *inMemoryService) StartWorkflowExecution(ctx context.Context, request *s.StartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.StartWorkflowExecutionResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.startWorkflow(request.GetDomain(), request.GetWorkflowId(), request.GetRequestId(),
+		request.WorkflowIdReusePolicy, &s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType:                        request.WorkflowType,
+			TaskList:                            request.TaskList,
+			Input:                               request.Input,
+			ExecutionStartToCloseTimeoutSeconds: request.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      request.TaskStartToCloseTimeoutSeconds,
+			ChildPolicy:                         request.ChildPolicy,
+			Identity:                            request.Identity,
+			RetryPolicy:                         request.RetryPolicy,
+			CronSchedule:                        request.CronSchedule,
+		}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &s.StartWorkflowExecutionResponse{RunId: e.execution.RunId}, nil
+}
+
+func (ts *inMemoryService) SignalWithStartWorkflowExecution(ctx context.Context, request *s.SignalWithStartWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.StartWorkflowExecutionResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	if _, err := ts.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	signal := &s.WorkflowExecutionSignaledEventAttributes{
+		SignalName: request.SignalName,
+		Input:      request.SignalInput,
+		Identity:   request.Identity,
+	}
+	if e, ok := ts.current[inMemoryWorkflowKey{request.GetDomain(), request.GetWorkflowId()}]; ok && e.isOpen() {
+		ts.signalWorkflow(e, signal)
+		return &s.StartWorkflowExecutionResponse{RunId: e.execution.RunId}, nil
+	}
+	e, err := ts.startWorkflow(request.GetDomain(), request.GetWorkflowId(), request.GetRequestId(),
+		request.WorkflowIdReusePolicy, &s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType:                        request.WorkflowType,
+			TaskList:                            request.TaskList,
+			Input:                               request.Input,
+			ExecutionStartToCloseTimeoutSeconds: request.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      request.TaskStartToCloseTimeoutSeconds,
+			Identity:                            request.Identity,
+			RetryPolicy:                         request.RetryPolicy,
+			CronSchedule:                        request.CronSchedule,
+		}, signal)
+	if err != nil {
+		return nil, err
+	}
+	return &s.StartWorkflowExecutionResponse{RunId: e.execution.RunId}, nil
+}
+
+func (ts *inMemoryService) SignalWorkflowExecution(ctx context.Context, request *s.SignalWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	ts.signalWorkflow(e, &s.WorkflowExecutionSignaledEventAttributes{
+		SignalName: request.SignalName,
+		Input:      request.Input,
+		Identity:   request.Identity,
+	})
+	return nil
+}
+
+func (ts *inMemoryService) RequestCancelWorkflowExecution(ctx context.Context, request *s.RequestCancelWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	return ts.requestCancelWorkflow(e, &s.WorkflowExecutionCancelRequestedEventAttributes{Identity: request.Identity})
+}
+
+func (ts *inMemoryService) TerminateWorkflowExecution(ctx context.Context, request *s.TerminateWorkflowExecutionRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getOpenExecution(request.GetDomain(), request.WorkflowExecution)
+	if err != nil {
+		return err
+	}
+	ts.closeWorkflowWithoutDecision(e, s.EventTypeWorkflowExecutionTerminated, s.WorkflowExecutionCloseStatusTerminated, func(event *s.HistoryEvent) {
+		event.WorkflowExecutionTerminatedEventAttributes = &s.WorkflowExecutionTerminatedEventAttributes{
+			Reason:   request.Reason,
+			Details:  request.Details,
+			Identity: request.Identity,
+		}
+	})
+	return nil
+}
+
+func (ts *inMemoryService) ResetWorkflowExecution(ctx context.Context, request *s.ResetWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.ResetWorkflowExecutionResponse, error) {
+	return nil, &s.BadRequestError{Message: "ResetWorkflowExecution is not supported by the test service."}
+}
+
+func (ts *inMemoryService) ResetStickyTaskList(ctx context.Context, request *s.ResetStickyTaskListRequest, opts ...yarpc.CallOption) (*s.ResetStickyTaskListResponse, error) {
+	// decision tasks are never dispatched to sticky task lists, there is nothing to reset
+	return &s.ResetStickyTaskListResponse{}, nil
+}
+
+func (ts *inMemoryService) DescribeWorkflowExecution(ctx context.Context, request *s.DescribeWorkflowExecutionRequest, opts ...yarpc.CallOption) (*s.DescribeWorkflowExecutionResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		return nil, err
+	}
+	response := &s.DescribeWorkflowExecutionResponse{
+		ExecutionConfiguration: &s.WorkflowExecutionConfiguration{
+			TaskList:                            e.attributes.TaskList,
+			ExecutionStartToCloseTimeoutSeconds: e.attributes.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      e.attributes.TaskStartToCloseTimeoutSeconds,
+			ChildPolicy:                         e.attributes.ChildPolicy,
+		},
+		WorkflowExecutionInfo: e.info(),
+	}
+	var scheduledIDs []int64
+	for scheduledID := range e.activities {
+		scheduledIDs = append(scheduledIDs, scheduledID)
+	}
+	sort.Slice(scheduledIDs, func(i, j int) bool { return scheduledIDs[i] < scheduledIDs[j] })
+	for _, scheduledID := range scheduledIDs {
+		a := e.activities[scheduledID]
+		state := s.PendingActivityStateScheduled
+		if a.cancelID != 0 {
+			state = s.PendingActivityStateCancelRequested
+		} else if a.started {
+			state = s.PendingActivityStateStarted
+		}
+		info := &s.PendingActivityInfo{
+			ActivityID:       a.attributes.ActivityId,
+			ActivityType:     a.attributes.ActivityType,
+			State:            &state,
+			HeartbeatDetails: a.details,
+			Attempt:          common.Int32Ptr(a.attempt),
+		}
+		if !a.heartbeatTime.IsZero() {
+			info.LastHeartbeatTimestamp = common.Int64Ptr(a.heartbeatTime.UnixNano())
+		}
+		if a.started {
+			info.LastStartedTimestamp = common.Int64Ptr(a.startedTime.UnixNano())
+		}
+		response.PendingActivities = append(response.PendingActivities, info)
+	}
+	return response, nil
+}
+
+func (ts *inMemoryService) ListOpenWorkflowExecutions(ctx context.Context, request *s.ListOpenWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListOpenWorkflowExecutionsResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	executions, nextPageToken, err := ts.listWorkflows(request.GetDomain(), true, request.StartTimeFilter,
+		request.ExecutionFilter, request.TypeFilter, nil, request.GetMaximumPageSize(), request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &s.ListOpenWorkflowExecutionsResponse{Executions: executions, NextPageToken: nextPageToken}, nil
+}
+
+func (ts *inMemoryService) ListClosedWorkflowExecutions(ctx context.Context, request *s.ListClosedWorkflowExecutionsRequest, opts ...yarpc.CallOption) (*s.ListClosedWorkflowExecutionsResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	executions, nextPageToken, err := ts.listWorkflows(request.GetDomain(), false, request.StartTimeFilter,
+		request.ExecutionFilter, request.TypeFilter, request.StatusFilter, request.GetMaximumPageSize(), request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &s.ListClosedWorkflowExecutionsResponse{Executions: executions, NextPageToken: nextPageToken}, nil
+}
+
+func (ts *inMemoryService) listWorkflows(domain string, open bool, startTimeFilter *s.StartTimeFilter,
+	executionFilter *s.WorkflowExecutionFilter, typeFilter *s.WorkflowTypeFilter, statusFilter *s.WorkflowExecutionCloseStatus,
+	pageSize int32, pageToken []byte) ([]*s.WorkflowExecutionInfo, []byte, error) {
+	if _, err := ts.getDomain(domain); err != nil {
+		return nil, nil, err
+	}
+	var matches []*inMemoryExecution
+	for key, e := range ts.executions {
+		if key.domain != domain || e.isOpen() != open {
+			continue
+		}
+		startTime := e.startTime.UnixNano()
+		if startTimeFilter != nil && (startTime < startTimeFilter.GetEarliestTime() ||
+			(startTimeFilter.LatestTime != nil && startTime > startTimeFilter.GetLatestTime())) {
+			continue
+		}
+		if executionFilter != nil && executionFilter.GetWorkflowId() != key.workflowID {
+			continue
+		}
+		if typeFilter != nil && typeFilter.GetName() != e.attributes.WorkflowType.GetName() {
+			continue
+		}
+		if statusFilter != nil && *statusFilter != *e.closeStatus {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].startTime.Equal(matches[j].startTime) {
+			return matches[i].startTime.After(matches[j].startTime)
+		}
+		return matches[i].execution.GetRunId() < matches[j].execution.GetRunId()
+	})
+
+	start, end, nextPageToken := inMemoryPage(len(matches), pageSize, pageToken)
+	var executions []*s.WorkflowExecutionInfo
+	for _, e := range matches[start:end] {
+		executions = append(executions, e.info())
+	}
+	return executions, nextPageToken, nil
+}
+
+func (ts *inMemoryService) GetWorkflowExecutionHistory(ctx context.Context, request *s.GetWorkflowExecutionHistoryRequest, opts ...yarpc.CallOption) (*s.GetWorkflowExecutionHistoryResponse, error) {
+	nextEventIndex := 0
+	if len(request.NextPageToken) > 0 {
+		var err error
+		if nextEventIndex, err = strconv.Atoi(string(request.NextPageToken)); err != nil {
+			return nil, &s.BadRequestError{Message: "Invalid NextPageToken."}
+		}
+	}
+	closeEventOnly := request.GetHistoryEventFilterType() == s.HistoryEventFilterTypeCloseEvent
+
+	timer := time.NewTimer(ts.longPollTimeout)
+	defer timer.Stop()
+	for {
+		ts.Lock()
+		e, err := ts.getExecution(request.GetDomain(), request.Execution)
+		if err != nil {
+			ts.Unlock()
+			return nil, err
+		}
+		history := &s.History{}
+		switch {
+		case closeEventOnly && !e.isOpen():
+			history.Events = e.history[len(e.history)-1:]
+			ts.Unlock()
+			return &s.GetWorkflowExecutionHistoryResponse{History: history}, nil
+		case !closeEventOnly && nextEventIndex < len(e.history):
+			history.Events = append(history.Events, e.history[nextEventIndex:]...)
+			var nextPageToken []byte
+			if e.isOpen() && request.GetWaitForNewEvent() {
+				nextPageToken = []byte(strconv.Itoa(len(e.history)))
+			}
+			ts.Unlock()
+			return &s.GetWorkflowExecutionHistoryResponse{History: history, NextPageToken: nextPageToken}, nil
+		case !e.isOpen() || !request.GetWaitForNewEvent():
+			ts.Unlock()
+			return &s.GetWorkflowExecutionHistoryResponse{History: history}, nil
+		}
+		notify := e.notify
+		ts.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			// let the caller poll again from the same position
+			return &s.GetWorkflowExecutionHistoryResponse{
+				History:       &s.History{},
+				NextPageToken: []byte(strconv.Itoa(nextEventIndex)),
+			}, nil
+		case <-ts.closeCh:
+			return nil, &s.InternalServiceError{Message: "Test service is closed."}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (ts *inMemoryService) QueryWorkflow(ctx context.Context, request *s.QueryWorkflowRequest, opts ...yarpc.CallOption) (*s.QueryWorkflowResponse, error) {
+	ts.Lock()
+	e, err := ts.getExecution(request.GetDomain(), request.Execution)
+	if err != nil {
+		ts.Unlock()
+		return nil, err
+	}
+	if e.previousStartedEventID == 0 {
+		ts.Unlock()
+		return nil, &s.QueryFailedError{Message: "Workflow execution has not completed its first decision task."}
+	}
+	queryID := ts.newID()
+	query := &inMemoryQuery{resultCh: make(chan *s.RespondQueryTaskCompletedRequest, 1)}
+	ts.queries[queryID] = query
+	ts.addTask(inMemoryTaskListKey{e.domain, e.attributes.TaskList.GetName(), s.TaskListTypeDecision}, func(identity string) interface{} {
+		if _, ok := ts.queries[queryID]; !ok {
+			return nil
+		}
+		return &s.PollForDecisionTaskResponse{
+			TaskToken:              e.token(0, 0, queryID),
+			WorkflowExecution:      e.execution,
+			WorkflowType:           e.attributes.WorkflowType,
+			PreviousStartedEventId: common.Int64Ptr(e.previousStartedEventID),
+			History:                &s.History{Events: append([]*s.HistoryEvent(nil), e.history...)},
+			Query:                  request.Query,
+		}
+	})
+	ts.Unlock()
+
+	defer func() {
+		ts.Lock()
+		delete(ts.queries, queryID)
+		ts.Unlock()
+	}()
+
+	select {
+	case result := <-query.resultCh:
+		if result.GetCompletedType() != s.QueryTaskCompletedTypeCompleted {
+			return nil, &s.QueryFailedError{Message: result.GetErrorMessage()}
+		}
+		return &s.QueryWorkflowResponse{QueryResult: result.QueryResult}, nil
+	case <-ts.closeCh:
+		return nil, &s.InternalServiceError{Message: "Test service is closed."}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (ts *inMemoryService) RespondQueryTaskCompleted(ctx context.Context, request *s.RespondQueryTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	token, err := decodeInMemoryTaskToken(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	ts.Lock()
+	defer ts.Unlock()
+	query, ok := ts.queries[token.QueryID]
+	if !ok {
+		return &s.EntityNotExistsError{Message: "Query task not found."}
+	}
+	delete(ts.queries, token.QueryID)
+	query.resultCh <- request
+	return nil
+}
+
+func (ts *inMemoryService) startWorkflow(domain, workflowID, requestID string, reusePolicy *s.WorkflowIdReusePolicy,
+	attributes *s.WorkflowExecutionStartedEventAttributes, signal *s.WorkflowExecutionSignaledEventAttributes) (*inMemoryExecution, error) {
+	if _, err := ts.getDomain(domain); err != nil {
+		return nil, err
+	}
+	switch {
+	case workflowID == "":
+		return nil, &s.BadRequestError{Message: "WorkflowId is not set on request."}
+	case attributes.WorkflowType.GetName() == "":
+		return nil, &s.BadRequestError{Message: "WorkflowType is not set on request."}
+	case attributes.TaskList.GetName() == "":
+		return nil, &s.BadRequestError{Message: "TaskList is not set on request."}
+	case attributes.GetExecutionStartToCloseTimeoutSeconds() <= 0:
+		return nil, &s.BadRequestError{Message: "A valid ExecutionStartToCloseTimeoutSeconds is not set on request."}
+	}
+
+	if prev, ok := ts.current[inMemoryWorkflowKey{domain, workflowID}]; ok {
+		if requestID != "" && prev.requestID == requestID {
+			return prev, nil
+		}
+		policy := s.WorkflowIdReusePolicyAllowDuplicateFailedOnly
+		if reusePolicy != nil {
+			policy = *reusePolicy
+		}
+		if prev.isOpen() || policy == s.WorkflowIdReusePolicyRejectDuplicate ||
+			(policy == s.WorkflowIdReusePolicyAllowDuplicateFailedOnly && *prev.closeStatus == s.WorkflowExecutionCloseStatusCompleted) {
+			return nil, &s.WorkflowExecutionAlreadyStartedError{
+				Message:        common.StringPtr(fmt.Sprintf("Workflow execution already started: %s.", workflowID)),
+				StartRequestId: common.StringPtr(prev.requestID),
+				RunId:          prev.execution.RunId,
+			}
+		}
+	}
+	return ts.newExecution(domain, workflowID, requestID, attributes, signal), nil
+}
+
+func (ts *inMemoryService) newExecution(domain, workflowID, requestID string,
+	attributes *s.WorkflowExecutionStartedEventAttributes, signal *s.WorkflowExecutionSignaledEventAttributes) *inMemoryExecution {
+	e := &inMemoryExecution{
+		domain: domain,
+		execution: &s.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(uuid.New()),
+		},
+		attributes:  attributes,
+		requestID:   requestID,
+		startTime:   time.Now(),
+		notify:      make(chan struct{}),
+		activities:  make(map[int64]*inMemoryActivity),
+		activityIDs: make(map[string]int64),
+		timers:      make(map[string]*inMemoryTimer),
+		children:    make(map[int64]*inMemoryChild),
+	}
+	ts.executions[inMemoryExecutionKey{domain, workflowID, e.execution.GetRunId()}] = e
+	ts.current[inMemoryWorkflowKey{domain, workflowID}] = e
+
+	e.addEvent(s.EventTypeWorkflowExecutionStarted, func(event *s.HistoryEvent) {
+		event.WorkflowExecutionStartedEventAttributes = attributes
+	})
+	if signal != nil {
+		e.addEvent(s.EventTypeWorkflowExecutionSignaled, func(event *s.HistoryEvent) {
+			event.WorkflowExecutionSignaledEventAttributes = signal
+		})
+	}
+	ts.scheduleDecision(e)
+
+	timeout := time.Duration(attributes.GetExecutionStartToCloseTimeoutSeconds()) * time.Second
+	ts.afterFunc(timeout, func() {
+		if e.isOpen() {
+			ts.closeWorkflowWithoutDecision(e, s.EventTypeWorkflowExecutionTimedOut, s.WorkflowExecutionCloseStatusTimedOut, func(event *s.HistoryEvent) {
+				event.WorkflowExecutionTimedOutEventAttributes = &s.WorkflowExecutionTimedOutEventAttributes{
+					TimeoutType: s.TimeoutTypeStartToClose.Ptr(),
+				}
+			})
+		}
+	})
+	return e
+}
+
+func (ts *inMemoryService) getExecution(domain string, execution *s.WorkflowExecution) (*inMemoryExecution, error) {
+	if _, err := ts.getDomain(domain); err != nil {
+		return nil, err
+	}
+	var e *inMemoryExecution
+	if execution.GetRunId() == "" {
+		e = ts.current[inMemoryWorkflowKey{domain, execution.GetWorkflowId()}]
+	} else {
+		e = ts.executions[inMemoryExecutionKey{domain, execution.GetWorkflowId(), execution.GetRunId()}]
+	}
+	if e == nil {
+		return nil, &s.EntityNotExistsError{Message: "Workflow execution not found."}
+	}
+	return e, nil
+}
+
+func (ts *inMemoryService) getOpenExecution(domain string, execution *s.WorkflowExecution) (*inMemoryExecution, error) {
+	e, err := ts.getExecution(domain, execution)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isOpen() {
+		return nil, &s.EntityNotExistsError{Message: "Workflow execution already completed."}
+	}
+	return e, nil
+}
+
+func (ts *inMemoryService) signalWorkflow(e *inMemoryExecution, attributes *s.WorkflowExecutionSignaledEventAttributes) {
+	e.addEvent(s.EventTypeWorkflowExecutionSignaled, func(event *s.HistoryEvent) {
+		event.WorkflowExecutionSignaledEventAttributes = attributes
+	})
+	ts.scheduleDecision(e)
+}
+
+func (ts *inMemoryService) requestCancelWorkflow(e *inMemoryExecution, attributes *s.WorkflowExecutionCancelRequestedEventAttributes) error {
+	if e.cancelRequested {
+		return &s.CancellationAlreadyRequestedError{Message: "Cancellation already requested for this workflow execution."}
+	}
+	e.cancelRequested = true
+	e.addEvent(s.EventTypeWorkflowExecutionCancelRequested, func(event *s.HistoryEvent) {
+		event.WorkflowExecutionCancelRequestedEventAttributes = attributes
+	})
+	ts.scheduleDecision(e)
+	return nil
+}
+
+// closeWorkflowWithoutDecision closes the workflow execution on behalf of the service, by a termination or a timeout.
+func (ts *inMemoryService) closeWorkflowWithoutDecision(e *inMemoryExecution, eventType s.EventType, status s.WorkflowExecutionCloseStatus,
+	setAttributes func(event *s.HistoryEvent)) {
+	e.decisionScheduledID = 0
+	e.decisionStartedID = 0
+	e.flushBufferedEvents()
+	e.addEvent(eventType, setAttributes)
+	ts.closeWorkflow(e, status)
+}
+
+// closeWorkflow marks the execution closed, after its close event was added to the history, and reports the result
+// to the parent workflow if any.
+func (ts *inMemoryService) closeWorkflow(e *inMemoryExecution, status s.WorkflowExecutionCloseStatus) {
+	e.closeStatus = &status
+	e.closeTime = time.Now()
+	e.decisionScheduledID = 0
+	e.decisionStartedID = 0
+	for _, t := range e.timers {
+		t.timer.Stop()
+	}
+	for _, a := range e.activities {
+		if a.heartbeatTimer != nil {
+			a.heartbeatTimer.Stop()
+		}
+	}
+	e.notifyAll()
+
+	parent := e.parent
+	if parent == nil || status == s.WorkflowExecutionCloseStatusContinuedAsNew || !parent.isOpen() {
+		return
+	}
+	child, ok := parent.children[e.parentInitiatedID]
+	if !ok {
+		return
+	}
+	delete(parent.children, e.parentInitiatedID)
+
+	closeEvent := e.history[len(e.history)-1]
+	domain := common.StringPtr(child.domain)
+	initiatedID := common.Int64Ptr(e.parentInitiatedID)
+	parent.addEvent(s.EventTypeChildWorkflowExecutionCompleted, func(event *s.HistoryEvent) {
+		switch status {
+		case s.WorkflowExecutionCloseStatusCompleted:
+			event.ChildWorkflowExecutionCompletedEventAttributes = &s.ChildWorkflowExecutionCompletedEventAttributes{
+				Result:            closeEvent.WorkflowExecutionCompletedEventAttributes.Result,
+				Domain:            domain,
+				WorkflowExecution: e.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  initiatedID,
+				StartedEventId:    child.startedID,
+			}
+		case s.WorkflowExecutionCloseStatusFailed:
+			event.EventType = common.EventTypePtr(s.EventTypeChildWorkflowExecutionFailed)
+			event.ChildWorkflowExecutionFailedEventAttributes = &s.ChildWorkflowExecutionFailedEventAttributes{
+				Reason:            closeEvent.WorkflowExecutionFailedEventAttributes.Reason,
+				Details:           closeEvent.WorkflowExecutionFailedEventAttributes.Details,
+				Domain:            domain,
+				WorkflowExecution: e.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  initiatedID,
+				StartedEventId:    child.startedID,
+			}
+		case s.WorkflowExecutionCloseStatusCanceled:
+			event.EventType = common.EventTypePtr(s.EventTypeChildWorkflowExecutionCanceled)
+			event.ChildWorkflowExecutionCanceledEventAttributes = &s.ChildWorkflowExecutionCanceledEventAttributes{
+				Details:           closeEvent.WorkflowExecutionCanceledEventAttributes.Details,
+				Domain:            domain,
+				WorkflowExecution: e.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  initiatedID,
+				StartedEventId:    child.startedID,
+			}
+		case s.WorkflowExecutionCloseStatusTimedOut:
+			event.EventType = common.EventTypePtr(s.EventTypeChildWorkflowExecutionTimedOut)
+			event.ChildWorkflowExecutionTimedOutEventAttributes = &s.ChildWorkflowExecutionTimedOutEventAttributes{
+				TimeoutType:       s.TimeoutTypeStartToClose.Ptr(),
+				Domain:            domain,
+				WorkflowExecution: e.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  initiatedID,
+				StartedEventId:    child.startedID,
+			}
+		default:
+			event.EventType = common.EventTypePtr(s.EventTypeChildWorkflowExecutionTerminated)
+			event.ChildWorkflowExecutionTerminatedEventAttributes = &s.ChildWorkflowExecutionTerminatedEventAttributes{
+				Domain:            domain,
+				WorkflowExecution: e.execution,
+				WorkflowType:      child.workflowType,
+				InitiatedEventId:  initiatedID,
+				StartedEventId:    child.startedID,
+			}
+		}
+	})
+	ts.scheduleDecision(parent)
+}
+
+// Task lists
+
+func (ts *inMemoryService) addTask(key inMemoryTaskListKey, task func(identity string) interface{}) {
+	tl := ts.getTaskList(key)
+	tl.tasks = append(tl.tasks, task)
+	close(tl.notify)
+	tl.notify = make(chan struct{})
+}
+
+func (ts *inMemoryService) getTaskList(key inMemoryTaskListKey) *inMemoryTaskList {
+	tl, ok := ts.taskLists[key]
+	if !ok {
+		tl = &inMemoryTaskList{notify: make(chan struct{}), pollers: make(map[string]time.Time)}
+		ts.taskLists[key] = tl
+	}
+	return tl
+}
+
+// pollTask long polls the task list and returns the response of the first task that starts, or nil on timeout.
+func (ts *inMemoryService) pollTask(ctx context.Context, key inMemoryTaskListKey, identity string) (interface{}, error) {
+	timer := time.NewTimer(ts.longPollTimeout)
+	defer timer.Stop()
+	for {
+		ts.Lock()
+		if ts.isClosed {
+			ts.Unlock()
+			return nil, &s.InternalServiceError{Message: "Test service is closed."}
+		}
+		if _, err := ts.getDomain(key.domain); err != nil {
+			ts.Unlock()
+			return nil, err
+		}
+		tl := ts.getTaskList(key)
+		tl.pollers[identity] = time.Now()
+		for len(tl.tasks) > 0 {
+			task := tl.tasks[0]
+			tl.tasks = tl.tasks[1:]
+			if response := task(identity); response != nil {
+				ts.Unlock()
+				return response, nil
+			}
+		}
+		notify := tl.notify
+		ts.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return nil, nil
+		case <-ts.closeCh:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+func (ts *inMemoryService) DescribeTaskList(ctx context.Context, request *s.DescribeTaskListRequest, opts ...yarpc.CallOption) (*s.DescribeTaskListResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	if _, err := ts.getDomain(request.GetDomain()); err != nil {
+		return nil, err
+	}
+	response := &s.DescribeTaskListResponse{}
+	tl, ok := ts.taskLists[inMemoryTaskListKey{request.GetDomain(), request.TaskList.GetName(), request.GetTaskListType()}]
+	if !ok {
+		return response, nil
+	}
+	for identity, lastAccessTime := range tl.pollers {
+		response.Pollers = append(response.Pollers, &s.PollerInfo{
+			Identity:       common.StringPtr(identity),
+			LastAccessTime: common.Int64Ptr(lastAccessTime.UnixNano()),
+		})
+	}
+	sort.Slice(response.Pollers, func(i, j int) bool {
+		return response.Pollers[i].GetIdentity() < response.Pollers[j].GetIdentity()
+	})
+	return response, nil
+}
+
+// Decision tasks
+
+func (ts *inMemoryService) PollForDecisionTask(ctx context.Context, request *s.PollForDecisionTaskRequest, opts ...yarpc.CallOption) (*s.PollForDecisionTaskResponse, error) {
+	response, err := ts.pollTask(ctx, inMemoryTaskListKey{request.GetDomain(), request.TaskList.GetName(), s.TaskListTypeDecision}, request.GetIdentity())
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return &s.PollForDecisionTaskResponse{}, nil
+	}
+	return response.(*s.PollForDecisionTaskResponse), nil
+}
+
+// scheduleDecision schedules a decision task unless one is already scheduled. Events that arrive while the decision
+// task is in flight are buffered and trigger a new decision task once it is closed.
+func (ts *inMemoryService) scheduleDecision(e *inMemoryExecution) {
+	if !e.isOpen() || e.decisionScheduledID != 0 {
+		return
+	}
+	timeout := e.attributes.GetTaskStartToCloseTimeoutSeconds()
+	if timeout <= 0 {
+		timeout = int32(defaultInMemoryServiceDecisionTimeout / time.Second)
+	}
+	scheduled := e.addEvent(s.EventTypeDecisionTaskScheduled, func(event *s.HistoryEvent) {
+		event.DecisionTaskScheduledEventAttributes = &s.DecisionTaskScheduledEventAttributes{
+			TaskList:                   e.attributes.TaskList,
+			StartToCloseTimeoutSeconds: common.Int32Ptr(timeout),
+			Attempt:                    common.Int64Ptr(e.decisionAttempt),
+		}
+	})
+	scheduledID := scheduled.GetEventId()
+	e.decisionScheduledID = scheduledID
+	ts.addTask(inMemoryTaskListKey{e.domain, e.attributes.TaskList.GetName(), s.TaskListTypeDecision}, func(identity string) interface{} {
+		if response := ts.startDecision(e, scheduledID, identity); response != nil {
+			return response
+		}
+		return nil
+	})
+}
+
+func (ts *inMemoryService) startDecision(e *inMemoryExecution, scheduledID int64, identity string) *s.PollForDecisionTaskResponse {
+	if !e.isOpen() || e.decisionScheduledID != scheduledID || e.decisionStartedID != 0 {
+		return nil
+	}
+	started := e.addEvent(s.EventTypeDecisionTaskStarted, func(event *s.HistoryEvent) {
+		event.DecisionTaskStartedEventAttributes = &s.DecisionTaskStartedEventAttributes{
+			ScheduledEventId: common.Int64Ptr(scheduledID),
+			Identity:         common.StringPtr(identity),
+			RequestId:        common.StringPtr(uuid.New()),
+		}
+	})
+	startedID := started.GetEventId()
+	e.decisionStartedID = startedID
+
+	timeout := time.Duration(e.history[scheduledID-1].DecisionTaskScheduledEventAttributes.GetStartToCloseTimeoutSeconds()) * time.Second
+	ts.afterFunc(timeout, func() {
+		if !e.isOpen() || e.decisionStartedID != startedID {
+			return
+		}
+		e.decisionScheduledID = 0
+		e.decisionStartedID = 0
+		e.decisionAttempt++
+		e.addEvent(s.EventTypeDecisionTaskTimedOut, func(event *s.HistoryEvent) {
+			event.DecisionTaskTimedOutEventAttributes = &s.DecisionTaskTimedOutEventAttributes{
+				ScheduledEventId: common.Int64Ptr(scheduledID),
+				StartedEventId:   common.Int64Ptr(startedID),
+				TimeoutType:      s.TimeoutTypeStartToClose.Ptr(),
+			}
+		})
+		e.flushBufferedEvents()
+		ts.scheduleDecision(e)
+	})
+
+	return &s.PollForDecisionTaskResponse{
+		TaskToken:                 e.token(scheduledID, e.decisionAttempt, 0),
+		WorkflowExecution:         e.execution,
+		WorkflowType:              e.attributes.WorkflowType,
+		PreviousStartedEventId:    common.Int64Ptr(e.previousStartedEventID),
+		StartedEventId:            common.Int64Ptr(startedID),
+		Attempt:                   common.Int64Ptr(e.decisionAttempt),
+		History:                   &s.History{Events: append([]*s.HistoryEvent(nil), e.history...)},
+		WorkflowExecutionTaskList: e.attributes.TaskList,
+	}
+}
+
+// getStartedDecision returns the execution of the decision task identified by the token, if that task is in flight.
+func (ts *inMemoryService) getStartedDecision(taskToken []byte) (*inMemoryExecution, error) {
+	token, err := decodeInMemoryTaskToken(taskToken)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := ts.executions[inMemoryExecutionKey{token.Domain, token.WorkflowID, token.RunID}]
+	if !ok || !e.isOpen() || e.decisionScheduledID != token.ScheduleID || e.decisionStartedID == 0 {
+		return nil, &s.EntityNotExistsError{Message: "Decision task not found."}
+	}
+	return e, nil
+}
+
+func (ts *inMemoryService) RespondDecisionTaskFailed(ctx context.Context, request *s.RespondDecisionTaskFailedRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getStartedDecision(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	ts.failDecision(e, request.GetCause(), request.Details, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) failDecision(e *inMemoryExecution, cause s.DecisionTaskFailedCause, details []byte, identity string) {
+	scheduledID, startedID := e.decisionScheduledID, e.decisionStartedID
+	e.decisionScheduledID = 0
+	e.decisionStartedID = 0
+	e.decisionAttempt++
+	e.addEvent(s.EventTypeDecisionTaskFailed, func(event *s.HistoryEvent) {
+		event.DecisionTaskFailedEventAttributes = &s.DecisionTaskFailedEventAttributes{
+			ScheduledEventId: common.Int64Ptr(scheduledID),
+			StartedEventId:   common.Int64Ptr(startedID),
+			Cause:            &cause,
+			Details:          details,
+			Identity:         common.StringPtr(identity),
+		}
+	})
+	e.flushBufferedEvents()
+	ts.scheduleDecision(e)
+}
+
+func (ts *inMemoryService) RespondDecisionTaskCompleted(ctx context.Context, request *s.RespondDecisionTaskCompletedRequest, opts ...yarpc.CallOption) (*s.RespondDecisionTaskCompletedResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	e, err := ts.getStartedDecision(request.TaskToken)
+	if err != nil {
+		return nil, err
+	}
+	if cause, ok := ts.validateDecisions(e, request.Decisions); !ok {
+		ts.failDecision(e, cause, nil, request.GetIdentity())
+		return &s.RespondDecisionTaskCompletedResponse{}, nil
+	}
+
+	scheduledID, startedID := e.decisionScheduledID, e.decisionStartedID
+	e.decisionScheduledID = 0
+	e.decisionStartedID = 0
+	e.decisionAttempt = 0
+	e.previousStartedEventID = startedID
+	completed := e.addEvent(s.EventTypeDecisionTaskCompleted, func(event *s.HistoryEvent) {
+		event.DecisionTaskCompletedEventAttributes = &s.DecisionTaskCompletedEventAttributes{
+			ExecutionContext: request.ExecutionContext,
+			ScheduledEventId: common.Int64Ptr(scheduledID),
+			StartedEventId:   common.Int64Ptr(startedID),
+			Identity:         request.Identity,
+			BinaryChecksum:   request.BinaryChecksum,
+		}
+	})
+
+	newDecision := request.GetForceCreateNewDecisionTask()
+	for _, d := range request.Decisions {
+		if ts.processDecision(e, d, completed.EventId, request.GetIdentity()) {
+			newDecision = true
+		}
+		if !e.isOpen() {
+			break
+		}
+	}
+	if e.flushBufferedEvents() {
+		newDecision = true
+	}
+
+	response := &s.RespondDecisionTaskCompletedResponse{}
+	if newDecision {
+		ts.scheduleDecision(e)
+		if request.GetReturnNewDecisionTask() && e.decisionScheduledID != 0 {
+			response.DecisionTask = ts.startDecision(e, e.decisionScheduledID, request.GetIdentity())
+		}
+	}
+	return response, nil
+}
+
+// validateDecisions rejects the decisions the service would fail the decision task for, before any of them applies.
+func (ts *inMemoryService) validateDecisions(e *inMemoryExecution, decisions []*s.Decision) (s.DecisionTaskFailedCause, bool) {
+	activityIDs := make(map[string]bool)
+	timerIDs := make(map[string]bool)
+	for _, d := range decisions {
+		switch d.GetDecisionType() {
+		case s.DecisionTypeScheduleActivityTask:
+			attributes := d.ScheduleActivityTaskDecisionAttributes
+			activityID := attributes.GetActivityId()
+			if _, ok := e.activityIDs[activityID]; ok || activityID == "" || activityIDs[activityID] ||
+				attributes.ActivityType.GetName() == "" || attributes.TaskList.GetName() == "" {
+				return s.DecisionTaskFailedCauseBadScheduleActivityAttributes, false
+			}
+			activityIDs[activityID] = true
+		case s.DecisionTypeStartTimer:
+			timerID := d.StartTimerDecisionAttributes.GetTimerId()
+			if _, ok := e.timers[timerID]; ok || timerIDs[timerID] {
+				return s.DecisionTaskFailedCauseStartTimerDuplicateID, false
+			}
+			timerIDs[timerID] = true
+		case s.DecisionTypeStartChildWorkflowExecution:
+			attributes := d.StartChildWorkflowExecutionDecisionAttributes
+			domain := attributes.GetDomain()
+			if domain == "" {
+				domain = e.domain
+			}
+			if _, err := ts.getDomain(domain); err != nil || attributes.GetWorkflowId() == "" ||
+				attributes.WorkflowType.GetName() == "" || attributes.TaskList.GetName() == "" ||
+				attributes.GetExecutionStartToCloseTimeoutSeconds() <= 0 {
+				return s.DecisionTaskFailedCauseBadStartChildExecutionAttributes, false
+			}
+		case s.DecisionTypeCompleteWorkflowExecution, s.DecisionTypeFailWorkflowExecution,
+			s.DecisionTypeCancelWorkflowExecution, s.DecisionTypeContinueAsNewWorkflowExecution:
+			// new events must be handled by the workflow before it is allowed to close
+			if len(e.buffered) > 0 {
+				return s.DecisionTaskFailedCauseUnhandledDecision, false
+			}
+		}
+	}
+	return 0, true
+}
+
+// processDecision applies a decision and returns true if it requires a new decision task.
+func (ts *inMemoryService) processDecision(e *inMemoryExecution, d *s.Decision, completedID *int64, identity string) bool {
+	switch d.GetDecisionType() {
+	case s.DecisionTypeScheduleActivityTask:
+		ts.scheduleActivity(e, d.ScheduleActivityTaskDecisionAttributes, completedID)
+
+	case s.DecisionTypeRequestCancelActivityTask:
+		return ts.requestCancelActivity(e, d.RequestCancelActivityTaskDecisionAttributes.GetActivityId(), completedID)
+
+	case s.DecisionTypeStartTimer:
+		attributes := d.StartTimerDecisionAttributes
+		started := e.addEvent(s.EventTypeTimerStarted, func(event *s.HistoryEvent) {
+			event.TimerStartedEventAttributes = &s.TimerStartedEventAttributes{
+				TimerId:                      attributes.TimerId,
+				StartToFireTimeoutSeconds:    attributes.StartToFireTimeoutSeconds,
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		timerID := attributes.GetTimerId()
+		t := &inMemoryTimer{startedID: started.GetEventId()}
+		t.timer = ts.afterFunc(time.Duration(attributes.GetStartToFireTimeoutSeconds())*time.Second, func() {
+			if current, ok := e.timers[timerID]; !ok || current != t || !e.isOpen() {
+				return
+			}
+			delete(e.timers, timerID)
+			e.addEvent(s.EventTypeTimerFired, func(event *s.HistoryEvent) {
+				event.TimerFiredEventAttributes = &s.TimerFiredEventAttributes{
+					TimerId:        common.StringPtr(timerID),
+					StartedEventId: common.Int64Ptr(t.startedID),
+				}
+			})
+			ts.scheduleDecision(e)
+		})
+		e.timers[timerID] = t
+
+	case s.DecisionTypeCancelTimer:
+		timerID := d.CancelTimerDecisionAttributes.GetTimerId()
+		t, ok := e.timers[timerID]
+		if !ok {
+			e.addEvent(s.EventTypeCancelTimerFailed, func(event *s.HistoryEvent) {
+				event.CancelTimerFailedEventAttributes = &s.CancelTimerFailedEventAttributes{
+					TimerId:                      common.StringPtr(timerID),
+					Cause:                        common.StringPtr("TIMER_ID_UNKNOWN"),
+					DecisionTaskCompletedEventId: completedID,
+					Identity:                     common.StringPtr(identity),
+				}
+			})
+			return true
+		}
+		t.timer.Stop()
+		delete(e.timers, timerID)
+		e.addEvent(s.EventTypeTimerCanceled, func(event *s.HistoryEvent) {
+			event.TimerCanceledEventAttributes = &s.TimerCanceledEventAttributes{
+				TimerId:                      common.StringPtr(timerID),
+				StartedEventId:               common.Int64Ptr(t.startedID),
+				DecisionTaskCompletedEventId: completedID,
+				Identity:                     common.StringPtr(identity),
+			}
+		})
+
+	case s.DecisionTypeRecordMarker:
+		attributes := d.RecordMarkerDecisionAttributes
+		e.addEvent(s.EventTypeMarkerRecorded, func(event *s.HistoryEvent) {
+			event.MarkerRecordedEventAttributes = &s.MarkerRecordedEventAttributes{
+				MarkerName:                   attributes.MarkerName,
+				Details:                      attributes.Details,
+				Header:                       attributes.Header,
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+
+	case s.DecisionTypeCompleteWorkflowExecution:
+		e.addEvent(s.EventTypeWorkflowExecutionCompleted, func(event *s.HistoryEvent) {
+			event.WorkflowExecutionCompletedEventAttributes = &s.WorkflowExecutionCompletedEventAttributes{
+				Result:                       d.CompleteWorkflowExecutionDecisionAttributes.Result,
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		ts.closeWorkflow(e, s.WorkflowExecutionCloseStatusCompleted)
+
+	case s.DecisionTypeFailWorkflowExecution:
+		e.addEvent(s.EventTypeWorkflowExecutionFailed, func(event *s.HistoryEvent) {
+			event.WorkflowExecutionFailedEventAttributes = &s.WorkflowExecutionFailedEventAttributes{
+				Reason:                       d.FailWorkflowExecutionDecisionAttributes.Reason,
+				Details:                      d.FailWorkflowExecutionDecisionAttributes.Details,
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		ts.closeWorkflow(e, s.WorkflowExecutionCloseStatusFailed)
+
+	case s.DecisionTypeCancelWorkflowExecution:
+		e.addEvent(s.EventTypeWorkflowExecutionCanceled, func(event *s.HistoryEvent) {
+			event.WorkflowExecutionCanceledEventAttributes = &s.WorkflowExecutionCanceledEventAttributes{
+				Details:                      d.CancelWorkflowExecutionDecisionAttributes.Details,
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		ts.closeWorkflow(e, s.WorkflowExecutionCloseStatusCanceled)
+
+	case s.DecisionTypeContinueAsNewWorkflowExecution:
+		ts.continueAsNew(e, d.ContinueAsNewWorkflowExecutionDecisionAttributes, completedID)
+
+	case s.DecisionTypeRequestCancelExternalWorkflowExecution:
+		ts.requestCancelExternalWorkflow(e, d.RequestCancelExternalWorkflowExecutionDecisionAttributes, completedID)
+		return true
+
+	case s.DecisionTypeSignalExternalWorkflowExecution:
+		ts.signalExternalWorkflow(e, d.SignalExternalWorkflowExecutionDecisionAttributes, completedID, identity)
+		return true
+
+	case s.DecisionTypeStartChildWorkflowExecution:
+		ts.startChildWorkflow(e, d.StartChildWorkflowExecutionDecisionAttributes, completedID)
+		return true
+	}
+	return false
+}
+
+func (ts *inMemoryService) continueAsNew(e *inMemoryExecution, attributes *s.ContinueAsNewWorkflowExecutionDecisionAttributes, completedID *int64) {
+	executionTimeout := attributes.ExecutionStartToCloseTimeoutSeconds
+	if executionTimeout == nil {
+		executionTimeout = e.attributes.ExecutionStartToCloseTimeoutSeconds
+	}
+	taskTimeout := attributes.TaskStartToCloseTimeoutSeconds
+	if taskTimeout == nil {
+		taskTimeout = e.attributes.TaskStartToCloseTimeoutSeconds
+	}
+	taskList := attributes.TaskList
+	if taskList.GetName() == "" {
+		taskList = e.attributes.TaskList
+	}
+
+	// the close event of this run must be in the history before the new run starts
+	continuedAsNew := e.addEvent(s.EventTypeWorkflowExecutionContinuedAsNew, func(event *s.HistoryEvent) {
+		event.WorkflowExecutionContinuedAsNewEventAttributes = &s.WorkflowExecutionContinuedAsNewEventAttributes{
+			WorkflowType:                        attributes.WorkflowType,
+			TaskList:                            taskList,
+			Input:                               attributes.Input,
+			ExecutionStartToCloseTimeoutSeconds: executionTimeout,
+			TaskStartToCloseTimeoutSeconds:      taskTimeout,
+			DecisionTaskCompletedEventId:        completedID,
+			BackoffStartIntervalInSeconds:       attributes.BackoffStartIntervalInSeconds,
+			Initiator:                           attributes.Initiator,
+			FailureReason:                       attributes.FailureReason,
+			FailureDetails:                      attributes.FailureDetails,
+			LastCompletionResult:                attributes.LastCompletionResult,
+		}
+	})
+	ts.closeWorkflow(e, s.WorkflowExecutionCloseStatusContinuedAsNew)
+
+	next := ts.newExecution(e.domain, e.execution.GetWorkflowId(), "", &s.WorkflowExecutionStartedEventAttributes{
+		WorkflowType:                        attributes.WorkflowType,
+		ParentWorkflowDomain:                e.attributes.ParentWorkflowDomain,
+		ParentWorkflowExecution:             e.attributes.ParentWorkflowExecution,
+		ParentInitiatedEventId:              e.attributes.ParentInitiatedEventId,
+		TaskList:                            taskList,
+		Input:                               attributes.Input,
+		ExecutionStartToCloseTimeoutSeconds: executionTimeout,
+		TaskStartToCloseTimeoutSeconds:      taskTimeout,
+		ChildPolicy:                         e.attributes.ChildPolicy,
+		ContinuedExecutionRunId:             e.execution.RunId,
+		Initiator:                           attributes.Initiator,
+		ContinuedFailureReason:              attributes.FailureReason,
+		ContinuedFailureDetails:             attributes.FailureDetails,
+		LastCompletionResult:                attributes.LastCompletionResult,
+		RetryPolicy:                         attributes.RetryPolicy,
+		CronSchedule:                        attributes.CronSchedule,
+	}, nil)
+	next.parent = e.parent
+	next.parentInitiatedID = e.parentInitiatedID
+	continuedAsNew.WorkflowExecutionContinuedAsNewEventAttributes.NewExecutionRunId = next.execution.RunId
+}
+
+func (ts *inMemoryService) requestCancelExternalWorkflow(e *inMemoryExecution, attributes *s.RequestCancelExternalWorkflowExecutionDecisionAttributes, completedID *int64) {
+	domain := attributes.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	execution := &s.WorkflowExecution{WorkflowId: attributes.WorkflowId, RunId: attributes.RunId}
+	initiated := e.addEvent(s.EventTypeRequestCancelExternalWorkflowExecutionInitiated, func(event *s.HistoryEvent) {
+		event.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes = &s.RequestCancelExternalWorkflowExecutionInitiatedEventAttributes{
+			DecisionTaskCompletedEventId: completedID,
+			Domain:                       common.StringPtr(domain),
+			WorkflowExecution:            execution,
+			Control:                      attributes.Control,
+			ChildWorkflowOnly:            attributes.ChildWorkflowOnly,
+		}
+	})
+
+	target, err := ts.getOpenExecution(domain, execution)
+	if err == nil && target != e {
+		err = ts.requestCancelWorkflow(target, &s.WorkflowExecutionCancelRequestedEventAttributes{
+			ExternalInitiatedEventId:  initiated.EventId,
+			ExternalWorkflowExecution: e.execution,
+		})
+		if _, ok := err.(*s.CancellationAlreadyRequestedError); ok {
+			err = nil
+		}
+	}
+	if err != nil || target == e {
+		e.addEvent(s.EventTypeRequestCancelExternalWorkflowExecutionFailed, func(event *s.HistoryEvent) {
+			event.RequestCancelExternalWorkflowExecutionFailedEventAttributes = &s.RequestCancelExternalWorkflowExecutionFailedEventAttributes{
+				Cause:                        s.CancelExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution.Ptr(),
+				DecisionTaskCompletedEventId: completedID,
+				Domain:                       common.StringPtr(domain),
+				WorkflowExecution:            execution,
+				InitiatedEventId:             initiated.EventId,
+				Control:                      attributes.Control,
+			}
+		})
+		return
+	}
+	e.addEvent(s.EventTypeExternalWorkflowExecutionCancelRequested, func(event *s.HistoryEvent) {
+		event.ExternalWorkflowExecutionCancelRequestedEventAttributes = &s.ExternalWorkflowExecutionCancelRequestedEventAttributes{
+			InitiatedEventId:  initiated.EventId,
+			Domain:            common.StringPtr(domain),
+			WorkflowExecution: execution,
+		}
+	})
+}
+
+func (ts *inMemoryService) signalExternalWorkflow(e *inMemoryExecution, attributes *s.SignalExternalWorkflowExecutionDecisionAttributes, completedID *int64, identity string) {
+	domain := attributes.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	initiated := e.addEvent(s.EventTypeSignalExternalWorkflowExecutionInitiated, func(event *s.HistoryEvent) {
+		event.SignalExternalWorkflowExecutionInitiatedEventAttributes = &s.SignalExternalWorkflowExecutionInitiatedEventAttributes{
+			DecisionTaskCompletedEventId: completedID,
+			Domain:                       common.StringPtr(domain),
+			WorkflowExecution:            attributes.Execution,
+			SignalName:                   attributes.SignalName,
+			Input:                        attributes.Input,
+			Control:                      attributes.Control,
+			ChildWorkflowOnly:            attributes.ChildWorkflowOnly,
+		}
+	})
+
+	target, err := ts.getOpenExecution(domain, attributes.Execution)
+	if err != nil {
+		e.addEvent(s.EventTypeSignalExternalWorkflowExecutionFailed, func(event *s.HistoryEvent) {
+			event.SignalExternalWorkflowExecutionFailedEventAttributes = &s.SignalExternalWorkflowExecutionFailedEventAttributes{
+				Cause:                        s.SignalExternalWorkflowExecutionFailedCauseUnknownExternalWorkflowExecution.Ptr(),
+				DecisionTaskCompletedEventId: completedID,
+				Domain:                       common.StringPtr(domain),
+				WorkflowExecution:            attributes.Execution,
+				InitiatedEventId:             initiated.EventId,
+				Control:                      attributes.Control,
+			}
+		})
+		return
+	}
+	ts.signalWorkflow(target, &s.WorkflowExecutionSignaledEventAttributes{
+		SignalName: attributes.SignalName,
+		Input:      attributes.Input,
+		Identity:   common.StringPtr(identity),
+	})
+	e.addEvent(s.EventTypeExternalWorkflowExecutionSignaled, func(event *s.HistoryEvent) {
+		event.ExternalWorkflowExecutionSignaledEventAttributes = &s.ExternalWorkflowExecutionSignaledEventAttributes{
+			InitiatedEventId:  initiated.EventId,
+			Domain:            common.StringPtr(domain),
+			WorkflowExecution: attributes.Execution,
+			Control:           attributes.Control,
+		}
+	})
+}
+
+func (ts *inMemoryService) startChildWorkflow(e *inMemoryExecution, attributes *s.StartChildWorkflowExecutionDecisionAttributes, completedID *int64) {
+	domain := attributes.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	initiated := e.addEvent(s.EventTypeStartChildWorkflowExecutionInitiated, func(event *s.HistoryEvent) {
+		event.StartChildWorkflowExecutionInitiatedEventAttributes = &s.StartChildWorkflowExecutionInitiatedEventAttributes{
+			Domain:                              common.StringPtr(domain),
+			WorkflowId:                          attributes.WorkflowId,
+			WorkflowType:                        attributes.WorkflowType,
+			TaskList:                            attributes.TaskList,
+			Input:                               attributes.Input,
+			ExecutionStartToCloseTimeoutSeconds: attributes.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      attributes.TaskStartToCloseTimeoutSeconds,
+			ChildPolicy:                         attributes.ChildPolicy,
+			Control:                             attributes.Control,
+			DecisionTaskCompletedEventId:        completedID,
+			WorkflowIdReusePolicy:               attributes.WorkflowIdReusePolicy,
+			RetryPolicy:                         attributes.RetryPolicy,
+			CronSchedule:                        attributes.CronSchedule,
+		}
+	})
+	initiatedID := initiated.GetEventId()
+
+	child, err := ts.startWorkflow(domain, attributes.GetWorkflowId(), "", attributes.WorkflowIdReusePolicy,
+		&s.WorkflowExecutionStartedEventAttributes{
+			WorkflowType:                        attributes.WorkflowType,
+			ParentWorkflowDomain:                common.StringPtr(e.domain),
+			ParentWorkflowExecution:             e.execution,
+			ParentInitiatedEventId:              common.Int64Ptr(initiatedID),
+			TaskList:                            attributes.TaskList,
+			Input:                               attributes.Input,
+			ExecutionStartToCloseTimeoutSeconds: attributes.ExecutionStartToCloseTimeoutSeconds,
+			TaskStartToCloseTimeoutSeconds:      attributes.TaskStartToCloseTimeoutSeconds,
+			ChildPolicy:                         attributes.ChildPolicy,
+			RetryPolicy:                         attributes.RetryPolicy,
+			CronSchedule:                        attributes.CronSchedule,
+		}, nil)
+	if err != nil {
+		// the attributes were validated with the decisions, the workflow ID of the child can only be in use
+		if _, ok := err.(*s.WorkflowExecutionAlreadyStartedError); !ok {
+			panic(fmt.Sprintf("unexpected error starting child workflow: %v", err))
+		}
+		e.addEvent(s.EventTypeStartChildWorkflowExecutionFailed, func(event *s.HistoryEvent) {
+			event.StartChildWorkflowExecutionFailedEventAttributes = &s.StartChildWorkflowExecutionFailedEventAttributes{
+				Domain:                       common.StringPtr(domain),
+				WorkflowId:                   attributes.WorkflowId,
+				WorkflowType:                 attributes.WorkflowType,
+				Cause:                        s.ChildWorkflowExecutionFailedCauseWorkflowAlreadyRunning.Ptr(),
+				Control:                      attributes.Control,
+				InitiatedEventId:             common.Int64Ptr(initiatedID),
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		return
+	}
+	child.parent = e
+	child.parentInitiatedID = initiatedID
+
+	started := e.addEvent(s.EventTypeChildWorkflowExecutionStarted, func(event *s.HistoryEvent) {
+		event.ChildWorkflowExecutionStartedEventAttributes = &s.ChildWorkflowExecutionStartedEventAttributes{
+			Domain:            common.StringPtr(domain),
+			InitiatedEventId:  common.Int64Ptr(initiatedID),
+			WorkflowExecution: child.execution,
+			WorkflowType:      attributes.WorkflowType,
+		}
+	})
+	e.children[initiatedID] = &inMemoryChild{
+		domain:       domain,
+		workflowType: attributes.WorkflowType,
+		startedID:    started.EventId,
+	}
+}
+
+// Activity tasks
+
+func (ts *inMemoryService) PollForActivityTask(ctx context.Context, request *s.PollForActivityTaskRequest, opts ...yarpc.CallOption) (*s.PollForActivityTaskResponse, error) {
+	response, err := ts.pollTask(ctx, inMemoryTaskListKey{request.GetDomain(), request.TaskList.GetName(), s.TaskListTypeActivity}, request.GetIdentity())
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return &s.PollForActivityTaskResponse{}, nil
+	}
+	return response.(*s.PollForActivityTaskResponse), nil
+}
+
+func (ts *inMemoryService) scheduleActivity(e *inMemoryExecution, decision *s.ScheduleActivityTaskDecisionAttributes, completedID *int64) {
+	domain := decision.GetDomain()
+	if domain == "" {
+		domain = e.domain
+	}
+	scheduled := e.addEvent(s.EventTypeActivityTaskScheduled, func(event *s.HistoryEvent) {
+		event.ActivityTaskScheduledEventAttributes = &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:                    decision.ActivityId,
+			ActivityType:                  decision.ActivityType,
+			Domain:                        common.StringPtr(domain),
+			TaskList:                      decision.TaskList,
+			Input:                         decision.Input,
+			ScheduleToCloseTimeoutSeconds: decision.ScheduleToCloseTimeoutSeconds,
+			ScheduleToStartTimeoutSeconds: decision.ScheduleToStartTimeoutSeconds,
+			StartToCloseTimeoutSeconds:    decision.StartToCloseTimeoutSeconds,
+			HeartbeatTimeoutSeconds:       decision.HeartbeatTimeoutSeconds,
+			DecisionTaskCompletedEventId:  completedID,
+			RetryPolicy:                   decision.RetryPolicy,
+		}
+	})
+	a := &inMemoryActivity{
+		scheduledID:   scheduled.GetEventId(),
+		attributes:    scheduled.ActivityTaskScheduledEventAttributes,
+		scheduledTime: time.Now(),
+	}
+	e.activities[a.scheduledID] = a
+	e.activityIDs[decision.GetActivityId()] = a.scheduledID
+
+	if timeout := a.attributes.GetScheduleToCloseTimeoutSeconds(); timeout > 0 {
+		ts.afterFunc(time.Duration(timeout)*time.Second, func() {
+			if e.activities[a.scheduledID] == a {
+				ts.closeActivity(e, a, s.EventTypeActivityTaskTimedOut, func(event *s.HistoryEvent, startedID *int64) {
+					event.ActivityTaskTimedOutEventAttributes = &s.ActivityTaskTimedOutEventAttributes{
+						Details:          a.details,
+						ScheduledEventId: common.Int64Ptr(a.scheduledID),
+						StartedEventId:   startedID,
+						TimeoutType:      s.TimeoutTypeScheduleToClose.Ptr(),
+					}
+				})
+			}
+		})
+	}
+	ts.dispatchActivity(e, a)
+}
+
+// dispatchActivity adds the current attempt of the activity to its task list.
+func (ts *inMemoryService) dispatchActivity(e *inMemoryExecution, a *inMemoryActivity) {
+	attempt := a.attempt
+	a.attemptTime = time.Now()
+	ts.addTask(inMemoryTaskListKey{a.attributes.GetDomain(), a.attributes.TaskList.GetName(), s.TaskListTypeActivity}, func(identity string) interface{} {
+		if e.activities[a.scheduledID] != a || a.attempt != attempt || a.started {
+			return nil
+		}
+		return ts.startActivity(e, a, identity)
+	})
+
+	if timeout := a.attributes.GetScheduleToStartTimeoutSeconds(); timeout > 0 {
+		ts.afterFunc(time.Duration(timeout)*time.Second, func() {
+			if e.activities[a.scheduledID] == a && a.attempt == attempt && !a.started {
+				ts.closeActivity(e, a, s.EventTypeActivityTaskTimedOut, func(event *s.HistoryEvent, startedID *int64) {
+					event.ActivityTaskTimedOutEventAttributes = &s.ActivityTaskTimedOutEventAttributes{
+						Details:          a.details,
+						ScheduledEventId: common.Int64Ptr(a.scheduledID),
+						TimeoutType:      s.TimeoutTypeScheduleToStart.Ptr(),
+					}
+				})
+			}
+		})
+	}
+}
+
+func (ts *inMemoryService) startActivity(e *inMemoryExecution, a *inMemoryActivity, identity string) *s.PollForActivityTaskResponse {
+	a.started = true
+	a.startedTime = time.Now()
+	a.identity = identity
+	attempt := a.attempt
+
+	if timeout := a.attributes.GetStartToCloseTimeoutSeconds(); timeout > 0 {
+		ts.afterFunc(time.Duration(timeout)*time.Second, func() {
+			if e.activities[a.scheduledID] == a && a.attempt == attempt && a.started {
+				ts.activityTimedOut(e, a, s.TimeoutTypeStartToClose)
+			}
+		})
+	}
+	ts.resetHeartbeatTimer(e, a)
+
+	return &s.PollForActivityTaskResponse{
+		TaskToken:                       e.token(a.scheduledID, int64(a.attempt), 0),
+		WorkflowExecution:               e.execution,
+		ActivityId:                      a.attributes.ActivityId,
+		ActivityType:                    a.attributes.ActivityType,
+		Input:                           a.attributes.Input,
+		ScheduledTimestamp:              common.Int64Ptr(a.scheduledTime.UnixNano()),
+		ScheduleToCloseTimeoutSeconds:   a.attributes.ScheduleToCloseTimeoutSeconds,
+		StartedTimestamp:                common.Int64Ptr(a.startedTime.UnixNano()),
+		StartToCloseTimeoutSeconds:      a.attributes.StartToCloseTimeoutSeconds,
+		HeartbeatTimeoutSeconds:         a.attributes.HeartbeatTimeoutSeconds,
+		Attempt:                         common.Int32Ptr(a.attempt),
+		ScheduledTimestampOfThisAttempt: common.Int64Ptr(a.attemptTime.UnixNano()),
+		HeartbeatDetails:                a.details,
+		WorkflowType:                    e.attributes.WorkflowType,
+		WorkflowDomain:                  common.StringPtr(e.domain),
+	}
+}
+
+func (ts *inMemoryService) resetHeartbeatTimer(e *inMemoryExecution, a *inMemoryActivity) {
+	timeout := a.attributes.GetHeartbeatTimeoutSeconds()
+	if timeout <= 0 {
+		return
+	}
+	if a.heartbeatTimer != nil {
+		a.heartbeatTimer.Stop()
+	}
+	attempt := a.attempt
+	var timer *time.Timer
+	timer = ts.afterFunc(time.Duration(timeout)*time.Second, func() {
+		if e.activities[a.scheduledID] == a && a.attempt == attempt && a.started && a.heartbeatTimer == timer {
+			ts.activityTimedOut(e, a, s.TimeoutTypeHeartbeat)
+		}
+	})
+	a.heartbeatTimer = timer
+}
+
+func (ts *inMemoryService) activityTimedOut(e *inMemoryExecution, a *inMemoryActivity, timeoutType s.TimeoutType) {
+	if ts.retryActivity(e, a, "timeout:"+timeoutType.String()) {
+		return
+	}
+	ts.closeActivity(e, a, s.EventTypeActivityTaskTimedOut, func(event *s.HistoryEvent, startedID *int64) {
+		event.ActivityTaskTimedOutEventAttributes = &s.ActivityTaskTimedOutEventAttributes{
+			Details:          a.details,
+			ScheduledEventId: common.Int64Ptr(a.scheduledID),
+			StartedEventId:   startedID,
+			TimeoutType:      timeoutType.Ptr(),
+		}
+	})
+}
+
+// retryActivity schedules the next attempt of the activity according to its retry policy, if any.
+func (ts *inMemoryService) retryActivity(e *inMemoryExecution, a *inMemoryActivity, reason string) bool {
+	policy := a.attributes.RetryPolicy
+	if policy == nil || a.cancelID != 0 || (policy.GetMaximumAttempts() > 0 && a.attempt >= policy.GetMaximumAttempts()-1) {
+		return false
+	}
+	var expireTime time.Time
+	if policy.GetExpirationIntervalInSeconds() > 0 {
+		expireTime = a.scheduledTime.Add(time.Duration(policy.GetExpirationIntervalInSeconds()) * time.Second)
+	}
+	backoff := getRetryBackoffWithNowTime(&RetryPolicy{
+		InitialInterval:          time.Duration(policy.GetInitialIntervalInSeconds()) * time.Second,
+		BackoffCoefficient:       policy.GetBackoffCoefficient(),
+		MaximumInterval:          time.Duration(policy.GetMaximumIntervalInSeconds()) * time.Second,
+		ExpirationInterval:       time.Duration(policy.GetExpirationIntervalInSeconds()) * time.Second,
+		MaximumAttempts:          policy.GetMaximumAttempts(),
+		NonRetriableErrorReasons: policy.NonRetriableErrorReasons,
+	}, a.attempt, reason, time.Now(), expireTime)
+	if backoff == noRetryBackoff {
+		return false
+	}
+
+	a.attempt++
+	a.started = false
+	if a.heartbeatTimer != nil {
+		a.heartbeatTimer.Stop()
+		a.heartbeatTimer = nil
+	}
+	attempt := a.attempt
+	ts.afterFunc(backoff, func() {
+		if e.activities[a.scheduledID] == a && a.attempt == attempt {
+			ts.dispatchActivity(e, a)
+		}
+	})
+	return true
+}
+
+// closeActivity adds the started event of the activity, if it was started, and its close event to the history.
+func (ts *inMemoryService) closeActivity(e *inMemoryExecution, a *inMemoryActivity, eventType s.EventType,
+	setAttributes func(event *s.HistoryEvent, startedID *int64)) {
+	delete(e.activities, a.scheduledID)
+	delete(e.activityIDs, a.attributes.GetActivityId())
+	if a.heartbeatTimer != nil {
+		a.heartbeatTimer.Stop()
+	}
+
+	var startedID *int64
+	if a.started {
+		started := e.addEvent(s.EventTypeActivityTaskStarted, func(event *s.HistoryEvent) {
+			event.ActivityTaskStartedEventAttributes = &s.ActivityTaskStartedEventAttributes{
+				ScheduledEventId: common.Int64Ptr(a.scheduledID),
+				Identity:         common.StringPtr(a.identity),
+				RequestId:        common.StringPtr(uuid.New()),
+				Attempt:          common.Int32Ptr(a.attempt),
+			}
+		})
+		startedID = started.EventId
+	}
+	e.addEvent(eventType, func(event *s.HistoryEvent) {
+		setAttributes(event, startedID)
+	})
+	ts.scheduleDecision(e)
+}
+
+func (ts *inMemoryService) requestCancelActivity(e *inMemoryExecution, activityID string, completedID *int64) bool {
+	scheduledID, ok := e.activityIDs[activityID]
+	if !ok {
+		e.addEvent(s.EventTypeRequestCancelActivityTaskFailed, func(event *s.HistoryEvent) {
+			event.RequestCancelActivityTaskFailedEventAttributes = &s.RequestCancelActivityTaskFailedEventAttributes{
+				ActivityId:                   common.StringPtr(activityID),
+				Cause:                        common.StringPtr("ACTIVITY_ID_UNKNOWN"),
+				DecisionTaskCompletedEventId: completedID,
+			}
+		})
+		return true
+	}
+	a := e.activities[scheduledID]
+	requested := e.addEvent(s.EventTypeActivityTaskCancelRequested, func(event *s.HistoryEvent) {
+		event.ActivityTaskCancelRequestedEventAttributes = &s.ActivityTaskCancelRequestedEventAttributes{
+			ActivityId:                   common.StringPtr(activityID),
+			DecisionTaskCompletedEventId: completedID,
+		}
+	})
+	a.cancelID = requested.GetEventId()
+	if a.started {
+		// the activity learns about the cancellation from its next heartbeat
+		return false
+	}
+	ts.closeActivity(e, a, s.EventTypeActivityTaskCanceled, func(event *s.HistoryEvent, startedID *int64) {
+		event.ActivityTaskCanceledEventAttributes = &s.ActivityTaskCanceledEventAttributes{
+			LatestCancelRequestedEventId: common.Int64Ptr(a.cancelID),
+			ScheduledEventId:             common.Int64Ptr(a.scheduledID),
+		}
+	})
+	return true
+}
+
+// getStartedActivity returns the activity identified by the task token, if its attempt is in flight.
+func (ts *inMemoryService) getStartedActivity(taskToken []byte) (*inMemoryExecution, *inMemoryActivity, error) {
+	token, err := decodeInMemoryTaskToken(taskToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e, ok := ts.executions[inMemoryExecutionKey{token.Domain, token.WorkflowID, token.RunID}]; ok && e.isOpen() {
+		if a, ok := e.activities[token.ScheduleID]; ok && a.started && int64(a.attempt) == token.Attempt {
+			return e, a, nil
+		}
+	}
+	return nil, nil, &s.EntityNotExistsError{Message: "Activity task not found."}
+}
+
+// getStartedActivityByID returns the activity identified by its ID, if it is in flight.
+func (ts *inMemoryService) getStartedActivityByID(domain, workflowID, runID, activityID string) (*inMemoryExecution, *inMemoryActivity, error) {
+	e, err := ts.getOpenExecution(domain, &s.WorkflowExecution{WorkflowId: common.StringPtr(workflowID), RunId: common.StringPtr(runID)})
+	if err != nil {
+		return nil, nil, err
+	}
+	if scheduledID, ok := e.activityIDs[activityID]; ok {
+		if a := e.activities[scheduledID]; a.started {
+			return e, a, nil
+		}
+	}
+	return nil, nil, &s.EntityNotExistsError{Message: "Activity task not found."}
+}
+
+func (ts *inMemoryService) RecordActivityTaskHeartbeat(ctx context.Context, request *s.RecordActivityTaskHeartbeatRequest, opts ...yarpc.CallOption) (*s.RecordActivityTaskHeartbeatResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivity(request.TaskToken)
+	if err != nil {
+		return nil, err
+	}
+	return ts.recordHeartbeat(e, a, request.Details), nil
+}
+
+func (ts *inMemoryService) RecordActivityTaskHeartbeatByID(ctx context.Context, request *s.RecordActivityTaskHeartbeatByIDRequest, opts ...yarpc.CallOption) (*s.RecordActivityTaskHeartbeatResponse, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return nil, err
+	}
+	return ts.recordHeartbeat(e, a, request.Details), nil
+}
+
+func (ts *inMemoryService) recordHeartbeat(e *inMemoryExecution, a *inMemoryActivity, details []byte) *s.RecordActivityTaskHeartbeatResponse {
+	a.details = details
+	a.heartbeatTime = time.Now()
+	ts.resetHeartbeatTimer(e, a)
+	return &s.RecordActivityTaskHeartbeatResponse{CancelRequested: common.BoolPtr(a.cancelID != 0)}
+}
+
+func (ts *inMemoryService) RespondActivityTaskCompleted(ctx context.Context, request *s.RespondActivityTaskCompletedRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	ts.activityCompleted(e, a, request.Result, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) RespondActivityTaskCompletedByID(ctx context.Context, request *s.RespondActivityTaskCompletedByIDRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	ts.activityCompleted(e, a, request.Result, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) activityCompleted(e *inMemoryExecution, a *inMemoryActivity, result []byte, identity string) {
+	ts.closeActivity(e, a, s.EventTypeActivityTaskCompleted, func(event *s.HistoryEvent, startedID *int64) {
+		event.ActivityTaskCompletedEventAttributes = &s.ActivityTaskCompletedEventAttributes{
+			Result:           result,
+			ScheduledEventId: common.Int64Ptr(a.scheduledID),
+			StartedEventId:   startedID,
+			Identity:         common.StringPtr(identity),
+		}
+	})
+}
+
+func (ts *inMemoryService) RespondActivityTaskFailed(ctx context.Context, request *s.RespondActivityTaskFailedRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	ts.activityFailed(e, a, request.GetReason(), request.Details, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) RespondActivityTaskFailedByID(ctx context.Context, request *s.RespondActivityTaskFailedByIDRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	ts.activityFailed(e, a, request.GetReason(), request.Details, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) activityFailed(e *inMemoryExecution, a *inMemoryActivity, reason string, details []byte, identity string) {
+	if ts.retryActivity(e, a, reason) {
+		return
+	}
+	ts.closeActivity(e, a, s.EventTypeActivityTaskFailed, func(event *s.HistoryEvent, startedID *int64) {
+		event.ActivityTaskFailedEventAttributes = &s.ActivityTaskFailedEventAttributes{
+			Reason:           common.StringPtr(reason),
+			Details:          details,
+			ScheduledEventId: common.Int64Ptr(a.scheduledID),
+			StartedEventId:   startedID,
+			Identity:         common.StringPtr(identity),
+		}
+	})
+}
+
+func (ts *inMemoryService) RespondActivityTaskCanceled(ctx context.Context, request *s.RespondActivityTaskCanceledRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivity(request.TaskToken)
+	if err != nil {
+		return err
+	}
+	ts.activityCanceled(e, a, request.Details, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) RespondActivityTaskCanceledByID(ctx context.Context, request *s.RespondActivityTaskCanceledByIDRequest, opts ...yarpc.CallOption) error {
+	ts.Lock()
+	defer ts.Unlock()
+	e, a, err := ts.getStartedActivityByID(request.GetDomain(), request.GetWorkflowID(), request.GetRunID(), request.GetActivityID())
+	if err != nil {
+		return err
+	}
+	ts.activityCanceled(e, a, request.Details, request.GetIdentity())
+	return nil
+}
+
+func (ts *inMemoryService) activityCanceled(e *inMemoryExecution, a *inMemoryActivity, details []byte, identity string) {
+	ts.closeActivity(e, a, s.EventTypeActivityTaskCanceled, func(event *s.HistoryEvent, startedID *int64) {
+		event.ActivityTaskCanceledEventAttributes = &s.ActivityTaskCanceledEventAttributes{
+			Details:                      details,
+			LatestCancelRequestedEventId: common.Int64Ptr(a.cancelID),
+			ScheduledEventId:             common.Int64Ptr(a.scheduledID),
+			StartedEventId:               startedID,
+			Identity:                     common.StringPtr(identity),
+		}
+	})
+}
+
+// Execution state
+
+func (e *inMemoryExecution) isOpen() bool {
+	return e.closeStatus == nil
+}
+
+// addEvent adds an event to the history, or buffers it while a decision task is in flight. The event ID of a buffered
+// event is allocated but only set when the event is flushed, so that other events can already refer to it.
+func (e *inMemoryExecution) addEvent(eventType s.EventType, setAttributes func(event *s.HistoryEvent)) *s.HistoryEvent {
+	event := &s.HistoryEvent{
+		EventId:   new(int64),
+		Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+		EventType: common.EventTypePtr(eventType),
+	}
+	setAttributes(event)
+	if e.decisionStartedID != 0 {
+		e.buffered = append(e.buffered, event)
+		return event
+	}
+	e.appendEvent(event)
+	return event
+}
+
+func (e *inMemoryExecution) appendEvent(event *s.HistoryEvent) {
+	*event.EventId = int64(len(e.history) + 1)
+	e.history = append(e.history, event)
+	e.notifyAll()
+}
+
+// flushBufferedEvents adds the buffered events to the history and returns true if there were any.
+func (e *inMemoryExecution) flushBufferedEvents() bool {
+	buffered := e.buffered
+	e.buffered = nil
+	for _, event := range buffered {
+		e.appendEvent(event)
+	}
+	return len(buffered) > 0
+}
+
+func (e *inMemoryExecution) notifyAll() {
+	close(e.notify)
+	e.notify = make(chan struct{})
+}
+
+func (e *inMemoryExecution) info() *s.WorkflowExecutionInfo {
+	info := &s.WorkflowExecutionInfo{
+		Execution:     e.execution,
+		Type:          e.attributes.WorkflowType,
+		StartTime:     common.Int64Ptr(e.startTime.UnixNano()),
+		CloseStatus:   e.closeStatus,
+		HistoryLength: common.Int64Ptr(int64(len(e.history))),
+	}
+	if !e.isOpen() {
+		info.CloseTime = common.Int64Ptr(e.closeTime.UnixNano())
+	}
+	return info
+}
+
+func (e *inMemoryExecution) token(scheduleID, attempt, queryID int64) []byte {
+	token, _ := json.Marshal(&inMemoryTaskToken{
+		Domain:     e.domain,
+		WorkflowID: e.execution.GetWorkflowId(),
+		RunID:      e.execution.GetRunId(),
+		ScheduleID: scheduleID,
+		Attempt:    attempt,
+		QueryID:    queryID,
+	})
+	return token
+}
+
+func decodeInMemoryTaskToken(taskToken []byte) (*inMemoryTaskToken, error) {
+	var token inMemoryTaskToken
+	if err := json.Unmarshal(taskToken, &token); err != nil {
+		return nil, &s.BadRequestError{Message: "Invalid task token."}
+	}
+	return &token, nil
+}
+
+// inMemoryPage returns the bounds of the page of a list of the given length, and the token of the next page.
+func inMemoryPage(length int, pageSize int32, pageToken []byte) (int, int, []byte) {
+	start, _ := strconv.Atoi(string(pageToken))
+	if start > length || start < 0 {
+		start = length
+	}
+	end := length
+	if pageSize > 0 && start+int(pageSize) < length {
+		end = start + int(pageSize)
+	}
+	var nextPageToken []byte
+	if end < length {
+		nextPageToken = []byte(strconv.Itoa(end))
+	}
+	return start, end, nextPageToken
+}
@@ -0,0 +1,369 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/internal/common"
+	"go.uber.org/zap"
+)
+
+const inMemoryTestTaskList = "inmemory-tl"
+
+var inMemoryActivityAttempts int32
+
+func init() {
+	RegisterWorkflowWithOptions(inMemoryParentWorkflow, RegisterWorkflowOptions{Name: "inMemoryParentWorkflow"})
+	RegisterWorkflowWithOptions(inMemoryChildWorkflow, RegisterWorkflowOptions{Name: "inMemoryChildWorkflow"})
+	RegisterWorkflowWithOptions(inMemorySleepWorkflow, RegisterWorkflowOptions{Name: "inMemorySleepWorkflow"})
+	RegisterWorkflowWithOptions(inMemoryStartChildWorkflow, RegisterWorkflowOptions{Name: "inMemoryStartChildWorkflow"})
+	RegisterActivityWithOptions(inMemoryFlakyActivity, RegisterActivityOptions{Name: "inMemoryFlakyActivity"})
+}
+
+func inMemoryParentWorkflow(ctx Context) (string, error) {
+	status := "waiting"
+	if err := SetQueryHandler(ctx, "status", func() (string, error) { return status, nil }); err != nil {
+		return "", err
+	}
+
+	var name string
+	GetSignalChannel(ctx, "name").Receive(ctx, &name)
+	status = "running"
+
+	ctx = WithActivityOptions(ctx, ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+		RetryPolicy: &RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 1,
+			MaximumAttempts:    3,
+		},
+	})
+	var greeting string
+	if err := ExecuteActivity(ctx, "inMemoryFlakyActivity", name).Get(ctx, &greeting); err != nil {
+		return "", err
+	}
+	if err := Sleep(ctx, time.Second); err != nil {
+		return "", err
+	}
+
+	ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{ExecutionStartToCloseTimeout: time.Minute})
+	var result string
+	if err := ExecuteChildWorkflow(ctx, "inMemoryChildWorkflow", greeting).Get(ctx, &result); err != nil {
+		return "", err
+	}
+	status = "done"
+	return result, nil
+}
+
+func inMemoryChildWorkflow(ctx Context, greeting string) (string, error) {
+	return greeting + "!", nil
+}
+
+func inMemorySleepWorkflow(ctx Context) error {
+	return Sleep(ctx, time.Hour)
+}
+
+func inMemoryStartChildWorkflow(ctx Context, childID string) error {
+	ctx = WithChildWorkflowOptions(ctx, ChildWorkflowOptions{WorkflowID: childID, ExecutionStartToCloseTimeout: time.Minute})
+	return ExecuteChildWorkflow(ctx, "inMemorySleepWorkflow").GetChildWorkflowExecution().Get(ctx, nil)
+}
+
+func inMemoryTracedWorkflow(ctx Context) (string, error) {
+	if err := SetQueryHandler(ctx, "ping", func() (string, error) { return "pong", nil }); err != nil {
+		return "", err
+	}
+	ctx = WithActivityOptions(ctx, ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	var greeting string
+	if err := ExecuteActivity(ctx, "inMemoryTracedActivity", "Cadence").Get(ctx, &greeting); err != nil {
+		return "", err
+	}
+	ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{ScheduleToCloseTimeout: time.Minute})
+	var result string
+	if err := ExecuteLocalActivity(ctx, inMemoryTracedLocalActivity, greeting).Get(ctx, &result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func inMemoryTracedActivity(ctx context.Context, name string) (string, error) {
+	return "Hello " + name, nil
+}
+
+func inMemoryTracedLocalActivity(ctx context.Context, greeting string) (string, error) {
+	return greeting + "!", nil
+}
+
+func inMemoryFlakyActivity(ctx context.Context, name string) (string, error) {
+	if atomic.AddInt32(&inMemoryActivityAttempts, 1) == 1 {
+		return "", errors.New("flaky")
+	}
+	return "Hello " + name, nil
+}
+
+type inMemoryServiceTestSuite struct {
+	suite.Suite
+	service TestService
+	worker  Worker
+	client  Client
+}
+
+func TestInMemoryServiceSuite(t *testing.T) {
+	suite.Run(t, new(inMemoryServiceTestSuite))
+}
+
+func (s *inMemoryServiceTestSuite) SetupTest() {
+	s.service = NewTestService(TestServiceOptions{LongPollTimeout: 200 * time.Millisecond})
+	err := NewDomainClient(s.service, nil).Register(context.Background(), &shared.RegisterDomainRequest{
+		Name:                                   common.StringPtr(domain),
+		WorkflowExecutionRetentionPeriodInDays: common.Int32Ptr(1),
+	})
+	s.NoError(err)
+
+	// the workflows left open by the tests would stay in the global workflow cache if sticky execution was enabled
+	s.worker = NewWorker(s.service, domain, inMemoryTestTaskList, WorkerOptions{
+		Logger:                 zap.NewNop(),
+		DisableStickyExecution: true,
+	})
+	s.NoError(s.worker.Start())
+	s.client = NewClient(s.service, domain, nil)
+}
+
+func (s *inMemoryServiceTestSuite) TearDownTest() {
+	s.worker.Stop()
+	s.service.Close()
+}
+
+func (s *inMemoryServiceTestSuite) TestRegisterDomainTwice() {
+	err := NewDomainClient(s.service, nil).Register(context.Background(), &shared.RegisterDomainRequest{
+		Name: common.StringPtr(domain),
+	})
+	s.IsType(&shared.DomainAlreadyExistsError{}, err)
+}
+
+func (s *inMemoryServiceTestSuite) TestWorkflow() {
+	atomic.StoreInt32(&inMemoryActivityAttempts, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemoryParentWorkflow")
+	s.NoError(err)
+
+	_, err = s.client.StartWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+		WorkflowIDReusePolicy:        WorkflowIDReusePolicyRejectDuplicate,
+	}, "inMemoryParentWorkflow")
+	s.IsType(&shared.WorkflowExecutionAlreadyStartedError{}, err)
+
+	// queries fail until the first decision task is completed
+	value, err := s.client.QueryWorkflow(ctx, run.GetID(), run.GetRunID(), "status")
+	for i := 0; i < 10 && err != nil; i++ {
+		s.IsType(&shared.QueryFailedError{}, err)
+		time.Sleep(100 * time.Millisecond)
+		value, err = s.client.QueryWorkflow(ctx, run.GetID(), run.GetRunID(), "status")
+	}
+	s.NoError(err)
+	var status string
+	s.NoError(value.Get(&status))
+	s.Equal("waiting", status)
+
+	s.NoError(s.client.SignalWorkflow(ctx, run.GetID(), run.GetRunID(), "name", "Cadence"))
+
+	var result string
+	s.NoError(run.Get(ctx, &result))
+	s.Equal("Hello Cadence!", result)
+	s.Equal(int32(2), atomic.LoadInt32(&inMemoryActivityAttempts))
+
+	value, err = s.client.QueryWorkflow(ctx, run.GetID(), run.GetRunID(), "status")
+	s.NoError(err)
+	s.NoError(value.Get(&status))
+	s.Equal("done", status)
+}
+
+func (s *inMemoryServiceTestSuite) TestCancelWorkflow() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-cancel-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemorySleepWorkflow")
+	s.NoError(err)
+
+	s.NoError(s.client.CancelWorkflow(ctx, run.GetID(), run.GetRunID()))
+	s.IsType(&shared.CancellationAlreadyRequestedError{}, s.client.CancelWorkflow(ctx, run.GetID(), run.GetRunID()))
+
+	err = run.Get(ctx, nil)
+	s.IsType(&CanceledError{}, err)
+}
+
+func (s *inMemoryServiceTestSuite) lastEvent(ctx context.Context, workflowID string) *shared.HistoryEvent {
+	var last *shared.HistoryEvent
+	iter := s.client.GetWorkflowHistory(ctx, workflowID, "", false, shared.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		s.NoError(err)
+		last = event
+	}
+	return last
+}
+
+func (s *inMemoryServiceTestSuite) TestTerminateWorkflow() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-terminate-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemorySleepWorkflow")
+	s.NoError(err)
+
+	s.NoError(s.client.TerminateWorkflow(ctx, run.GetID(), run.GetRunID(), "test", nil))
+	s.IsType(&TerminatedError{}, run.Get(ctx, nil))
+	event := s.lastEvent(ctx, run.GetID())
+	s.Equal(shared.EventTypeWorkflowExecutionTerminated, event.GetEventType())
+	s.Equal("test", event.WorkflowExecutionTerminatedEventAttributes.GetReason())
+}
+
+func (s *inMemoryServiceTestSuite) TestWorkflowTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-timeout-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Second,
+	}, "inMemorySleepWorkflow")
+	s.NoError(err)
+
+	s.IsType(&TimeoutError{}, run.Get(ctx, nil))
+	event := s.lastEvent(ctx, run.GetID())
+	s.Equal(shared.EventTypeWorkflowExecutionTimedOut, event.GetEventType())
+	s.NotNil(event.WorkflowExecutionTimedOutEventAttributes)
+}
+
+func (s *inMemoryServiceTestSuite) TestStartChildWorkflowAlreadyRunning() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.client.StartWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-running-child-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemorySleepWorkflow")
+	s.NoError(err)
+
+	run, err := s.client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-parent-wid",
+		TaskList:                     inMemoryTestTaskList,
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemoryStartChildWorkflow", "inmemory-running-child-wid")
+	s.NoError(err)
+	s.Error(run.Get(ctx, nil))
+
+	var failed *shared.StartChildWorkflowExecutionFailedEventAttributes
+	iter := s.client.GetWorkflowHistory(ctx, run.GetID(), "", false, shared.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		s.NoError(err)
+		if event.GetEventType() == shared.EventTypeStartChildWorkflowExecutionFailed {
+			failed = event.StartChildWorkflowExecutionFailedEventAttributes
+		}
+	}
+	s.NotNil(failed)
+	s.Equal(shared.ChildWorkflowExecutionFailedCauseWorkflowAlreadyRunning, failed.GetCause())
+}
+
+func (s *inMemoryServiceTestSuite) TestTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// the service sends the full history with every decision task, so the workflow is replayed by each of them
+	tracer := mocktracer.New()
+	worker := NewWorker(s.service, domain, "inmemory-traced-tl", WorkerOptions{Logger: zap.NewNop(), Tracer: tracer})
+	worker.RegisterWorkflowWithOptions(inMemoryTracedWorkflow, RegisterWorkflowOptions{Name: "inMemoryTracedWorkflow"})
+	worker.RegisterActivityWithOptions(inMemoryTracedActivity, RegisterActivityOptions{Name: "inMemoryTracedActivity"})
+	s.NoError(worker.Start())
+	defer worker.Stop()
+	client := NewClient(s.service, domain, &ClientOptions{Tracer: tracer})
+
+	run, err := client.ExecuteWorkflow(ctx, StartWorkflowOptions{
+		ID:                           "inmemory-traced-wid",
+		TaskList:                     "inmemory-traced-tl",
+		ExecutionStartToCloseTimeout: time.Minute,
+	}, "inMemoryTracedWorkflow")
+	s.NoError(err)
+	var result string
+	s.NoError(run.Get(ctx, &result))
+	s.Equal("Hello Cadence!", result)
+
+	// the query replays the whole workflow once more
+	value, err := client.QueryWorkflow(ctx, run.GetID(), run.GetRunID(), "ping")
+	s.NoError(err)
+	s.NoError(value.Get(&result))
+	s.Equal("pong", result)
+
+	spans := make(map[string][]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = append(spans[span.OperationName], span)
+	}
+	s.Len(spans[tracingOperationStartWorkflow], 1)
+	s.Len(spans[tracingOperationQueryWorkflow], 1)
+	// the calls of the workflow are traced once, whatever the number of replays
+	s.Len(spans[tracingOperationExecuteActivity], 1)
+	s.Len(spans[tracingOperationExecuteLocalActivity], 1)
+	s.Len(spans[tracingOperationRunActivity], 1)
+	s.Len(spans[tracingOperationRunLocalActivity], 1)
+	s.True(len(spans[tracingOperationDecisionTask]) >= 2)
+	for _, span := range spans[tracingOperationDecisionTask] {
+		s.Equal("inMemoryTracedWorkflow", span.Tag(tagWorkflowType))
+		s.Equal(run.GetID(), span.Tag(tagWorkflowID))
+	}
+
+	// the spans are propagated from the client to the workflow calls, and from the calls to the activity executions
+	start := spans[tracingOperationStartWorkflow][0]
+	executeActivity := spans[tracingOperationExecuteActivity][0]
+	executeLocalActivity := spans[tracingOperationExecuteLocalActivity][0]
+	s.Equal(start.SpanContext.SpanID, executeActivity.ParentID)
+	s.Equal(start.SpanContext.SpanID, executeLocalActivity.ParentID)
+	s.Equal("inMemoryTracedActivity", executeActivity.Tag(tagActivityType))
+	s.Equal(executeActivity.SpanContext.SpanID, spans[tracingOperationRunActivity][0].ParentID)
+	s.Equal(executeLocalActivity.SpanContext.SpanID, spans[tracingOperationRunLocalActivity][0].ParentID)
+}
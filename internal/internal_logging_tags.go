@@ -35,5 +35,6 @@ const (
 	tagWorkerType        = "WorkerType"
 	tagSideEffectID      = "SideEffectID"
 	tagChildWorkflowID   = "ChildWorkflowID"
+	tagChildWorkflowType = "ChildWorkflowType"
 	tagLocalActivityType = "LocalActivityType"
 )
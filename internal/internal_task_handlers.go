@@ -47,6 +47,10 @@ import (
 const (
 	defaultHeartBeatIntervalInSec = 10 * 60
 
+	// autoHeartBeatRatio is the fraction of the heartbeat timeout after which the framework heartbeats on behalf of
+	// the activity when WorkerOptions.AutoHeartBeat is set.
+	autoHeartBeatRatio = 0.5
+
 	defaultStickyCacheSize = 10000
 
 	noRetryBackoff = time.Duration(-1)
@@ -118,20 +122,25 @@ type (
 		laTunnel                       *localActivityTunnel
 		nonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
 		dataConverter                  encoded.DataConverter
+		workflowInterceptors           []WorkflowInterceptor
+		contextPropagators             []ContextPropagator
 	}
 
 	activityProvider func(name string) activity
 	// activityTaskHandlerImpl is the implementation of ActivityTaskHandler
 	activityTaskHandlerImpl struct {
-		taskListName     string
-		identity         string
-		service          workflowserviceclient.Interface
-		metricsScope     *metrics.TaggedScope
-		logger           *zap.Logger
-		userContext      context.Context
-		hostEnv          *hostEnvImpl
-		activityProvider activityProvider
-		dataConverter    encoded.DataConverter
+		taskListName       string
+		identity           string
+		service            workflowserviceclient.Interface
+		metricsScope       *metrics.TaggedScope
+		logger             *zap.Logger
+		userContext        context.Context
+		hostEnv            *hostEnvImpl
+		activityProvider   activityProvider
+		dataConverter      encoded.DataConverter
+		autoHeartBeat      bool
+		interceptors       []ActivityInterceptor
+		contextPropagators []ContextPropagator
 	}
 
 	// history wrapper method to help information about events.
@@ -319,6 +328,8 @@ func newWorkflowTaskHandler(
 		hostEnv:                        hostEnv,
 		nonDeterministicWorkflowPolicy: params.NonDeterministicWorkflowPolicy,
 		dataConverter:                  params.DataConverter,
+		workflowInterceptors:           params.WorkflowInterceptors,
+		contextPropagators:             params.ContextPropagators,
 	}
 }
 
@@ -417,7 +428,9 @@ func (w *workflowExecutionContextImpl) onEviction() {
 	// because once destroyed, no sensible information
 	// may be ascertained about the execution context's state,
 	// nor should any of its methods be invoked.
-	if w.shouldResetStickyOnEviction() {
+	// w.laTunnel is nil for the contexts of replayed workflows, they
+	// don't run on a sticky task list that would need to be reset.
+	if w.laTunnel != nil && w.shouldResetStickyOnEviction() {
 		w.queueResetStickinessTask()
 	}
 
@@ -463,7 +476,9 @@ func (w *workflowExecutionContextImpl) createEventHandler() {
 		w.wth.enableLoggingInReplay,
 		w.wth.metricsScope,
 		w.wth.hostEnv,
-		w.wth.dataConverter).(*workflowExecutionEventHandlerImpl)
+		w.wth.dataConverter,
+		w.wth.workflowInterceptors,
+		w.wth.contextPropagators).(*workflowExecutionEventHandlerImpl)
 }
 
 func resetHistory(task *s.PollForDecisionTaskResponse, historyIterator HistoryIterator) (*s.History, error) {
@@ -1357,15 +1372,18 @@ func newActivityTaskHandlerWithCustomProvider(
 	activityProvider activityProvider,
 ) ActivityTaskHandler {
 	return &activityTaskHandlerImpl{
-		taskListName:     params.TaskList,
-		identity:         params.Identity,
-		service:          service,
-		logger:           params.Logger,
-		metricsScope:     metrics.NewTaggedScope(params.MetricsScope),
-		userContext:      params.UserContext,
-		hostEnv:          env,
-		activityProvider: activityProvider,
-		dataConverter:    params.DataConverter,
+		taskListName:       params.TaskList,
+		identity:           params.Identity,
+		service:            service,
+		logger:             params.Logger,
+		metricsScope:       metrics.NewTaggedScope(params.MetricsScope),
+		userContext:        params.UserContext,
+		hostEnv:            env,
+		activityProvider:   activityProvider,
+		dataConverter:      params.DataConverter,
+		autoHeartBeat:      params.AutoHeartBeat,
+		interceptors:       params.ActivityInterceptors,
+		contextPropagators: params.ContextPropagators,
 	}
 }
 
@@ -1378,6 +1396,8 @@ type cadenceInvoker struct {
 	heartBeatTimeoutInSec int32       // The heart beat interval configured for this activity.
 	hbBatchEndTimer       *time.Timer // Whether we started a batch of operations that need to be reported in the cycle. This gets started on a user call.
 	lastDetailsToReport   *[]byte
+	lastDetails           []byte         // The details of the last heartbeat, reported again by auto heart beating.
+	autoHeartBeatWG       sync.WaitGroup // Tracks the auto heart beating goroutine so Close can wait for it to exit.
 	closeCh               chan struct{}
 }
 
@@ -1385,6 +1405,8 @@ func (i *cadenceInvoker) Heartbeat(details []byte) error {
 	i.Lock()
 	defer i.Unlock()
 
+	i.lastDetails = details
+
 	if i.hbBatchEndTimer != nil {
 		// If we have started batching window, keep track of last reported progress.
 		i.lastDetailsToReport = &details
@@ -1437,6 +1459,44 @@ func (i *cadenceInvoker) Heartbeat(details []byte) error {
 	return err
 }
 
+// startAutoHeartBeat heartbeats with the last reported details at a fraction of the heartbeat timeout until the invoker
+// is closed. The heartbeats go through Heartbeat, so they are batched and deliver cancellation like the ones of the
+// activity itself.
+func (i *cadenceInvoker) startAutoHeartBeat(details []byte) {
+	if i.heartBeatTimeoutInSec <= 0 {
+		return
+	}
+	i.Lock()
+	i.lastDetails = details
+	i.Unlock()
+
+	interval := time.Duration(autoHeartBeatRatio * float64(time.Duration(i.heartBeatTimeoutInSec)*time.Second))
+	i.autoHeartBeatWG.Add(1)
+	go func() {
+		defer i.autoHeartBeatWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-i.closeCh:
+				return
+			}
+			// both cases may be ready, don't heartbeat once the invoker is closed
+			select {
+			case <-i.closeCh:
+				return
+			default:
+			}
+
+			i.Lock()
+			details := i.lastDetails
+			i.Unlock()
+			i.Heartbeat(details)
+		}
+	}()
+}
+
 func (i *cadenceInvoker) internalHeartBeat(details []byte) (bool, error) {
 	isActivityCancelled := false
 	timeout := time.Duration(i.heartBeatTimeoutInSec) * time.Second
@@ -1466,10 +1526,17 @@ func (i *cadenceInvoker) internalHeartBeat(details []byte) (bool, error) {
 	return isActivityCancelled, err
 }
 
+// Close stops the heartbeats of the invoker. It waits for the auto heart beating goroutine to exit, so no heartbeat is
+// sent on behalf of the activity after Close returns.
 func (i *cadenceInvoker) Close(flushBufferedHeartbeat bool) {
 	i.Lock()
-	defer i.Unlock()
 	close(i.closeCh)
+	i.Unlock()
+	// the goroutine may be heartbeating, which needs the lock
+	i.autoHeartBeatWG.Wait()
+
+	i.Lock()
+	defer i.Unlock()
 	if i.hbBatchEndTimer != nil {
 		i.hbBatchEndTimer.Stop()
 		if flushBufferedHeartbeat && i.lastDetailsToReport != nil {
@@ -1486,6 +1553,16 @@ func newServiceInvoker(
 	cancelHandler func(),
 	heartBeatTimeoutInSec int32,
 ) ServiceInvoker {
+	return newCadenceInvoker(taskToken, identity, service, cancelHandler, heartBeatTimeoutInSec)
+}
+
+func newCadenceInvoker(
+	taskToken []byte,
+	identity string,
+	service workflowserviceclient.Interface,
+	cancelHandler func(),
+	heartBeatTimeoutInSec int32,
+) *cadenceInvoker {
 	return &cadenceInvoker{
 		taskToken:             taskToken,
 		identity:              identity,
@@ -1510,7 +1587,11 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 		rootCtx = context.Background()
 	}
 	canCtx, cancel := context.WithCancel(rootCtx)
-	invoker := newServiceInvoker(t.TaskToken, ath.identity, ath.service, cancel, t.GetHeartbeatTimeoutSeconds())
+	invoker := newCadenceInvoker(t.TaskToken, ath.identity, ath.service, cancel, t.GetHeartbeatTimeoutSeconds())
+	if ath.autoHeartBeat {
+		// keep the details of the previous attempt until the activity reports its own
+		invoker.startAutoHeartBeat(t.HeartbeatDetails)
+	}
 	defer func() {
 		_, activityCompleted := result.(*s.RespondActivityTaskCompletedRequest)
 		invoker.Close(!activityCompleted) // flush buffered heartbeat if activity was not successfully completed.
@@ -1519,7 +1600,7 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	workflowType := t.WorkflowType.GetName()
 	activityType := t.ActivityType.GetName()
 	metricsScope := getMetricsScopeForActivity(ath.metricsScope, workflowType, activityType)
-	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsScope, ath.dataConverter)
+	ctx := WithActivityTask(canCtx, t, taskList, invoker, ath.logger, metricsScope, ath.dataConverter, ath.interceptors)
 
 	activityImplementation := ath.getActivity(activityType)
 	if activityImplementation == nil {
@@ -1547,7 +1628,12 @@ func (ath *activityTaskHandlerImpl) Execute(taskList string, t *s.PollForActivit
 	info := ctx.Value(activityEnvContextKey).(*activityEnvironment)
 	ctx, dlCancelFunc := context.WithDeadline(ctx, info.deadline)
 
-	output, err := activityImplementation.Execute(ctx, t.Input)
+	ctx, input, err := unwrapActivityContextEnvelope(ctx, t.Input, ath.contextPropagators)
+	if err != nil {
+		dlCancelFunc()
+		return convertActivityResultToRespondRequest(ath.identity, t.TaskToken, nil, err, ath.dataConverter), nil
+	}
+	output, err := activityImplementation.Execute(ctx, input)
 
 	dlCancelFunc()
 	if <-ctx.Done(); ctx.Err() == context.DeadlineExceeded {
@@ -1566,11 +1652,15 @@ func (ath *activityTaskHandlerImpl) getActivity(name string) activity {
 		return a
 	}
 
+	if af := ath.hostEnv.getDynamicActivity(); af != nil {
+		return &dynamicActivityExecutor{name: name, fn: af}
+	}
+
 	return nil
 }
 
 func (ath *activityTaskHandlerImpl) getRegisteredActivityNames() (activityNames []string) {
-	for _, a := range ath.hostEnv.activityFuncMap {
+	for _, a := range ath.hostEnv.getRegisteredActivities() {
 		activityNames = append(activityNames, a.ActivityType().Name)
 	}
 	return
@@ -32,7 +32,9 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowservicetest"
 	s "go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/internal/common"
@@ -449,6 +451,45 @@ func (t *TaskHandlersTestSuite) TestCacheEvictionWhenErrorOccurs() {
 	t.EqualValues(getWorkflowCache().Size(), 0)
 }
 
+func (t *TaskHandlersTestSuite) TestDecisionTaskSpanFailedWithProcessingError() {
+	taskList := "taskList"
+	testEvents := []*s.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &s.WorkflowExecutionStartedEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskScheduled(2, &s.DecisionTaskScheduledEventAttributes{TaskList: &s.TaskList{Name: &taskList}}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &s.DecisionTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(2)}),
+		createTestEventActivityTaskScheduled(5, &s.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &s.ActivityType{Name: common.StringPtr("some-other-activity")},
+			TaskList:     &s.TaskList{Name: &taskList},
+		}),
+	}
+	params := workerExecutionParameters{
+		TaskList:                       taskList,
+		Identity:                       "test-id-1",
+		Logger:                         zap.NewNop(),
+		NonDeterministicWorkflowPolicy: NonDeterministicWorkflowPolicyBlockWorkflow,
+	}
+	taskHandler := newWorkflowTaskHandler(testDomain, params, nil, getHostEnvironment())
+	newWorkflowTaskWorkerInternal(taskHandler, t.service, testDomain, params)
+	tracer := mocktracer.New()
+	poller := &workflowTaskPoller{
+		service:      t.service,
+		taskHandler:  taskHandler,
+		metricsScope: tally.NoopScope,
+		logger:       zap.NewNop(),
+		tracer:       tracer,
+	}
+
+	// the decision task isn't failed on the server as it has no attempt, its processing failed nonetheless
+	err := poller.processWorkflowTask(&workflowTask{task: createWorkflowTask(testEvents, 3, "HelloWorld_Workflow")})
+	t.NoError(err)
+	spans := tracer.FinishedSpans()
+	t.Len(spans, 1)
+	t.Equal(tracingOperationDecisionTask, spans[0].OperationName)
+	t.Equal(true, spans[0].Tag("error"))
+}
+
 func (t *TaskHandlersTestSuite) TestWorkflowTask_NondeterministicDetection() {
 	taskList := "taskList"
 	testEvents := []*s.HistoryEvent{
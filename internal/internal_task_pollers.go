@@ -28,6 +28,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	s "go.uber.org/cadence/.gen/go/shared"
@@ -64,6 +65,7 @@ type (
 		taskHandler  WorkflowTaskHandler
 		metricsScope tally.Scope
 		logger       *zap.Logger
+		tracer       opentracing.Tracer
 
 		disableStickyExecution       bool
 		StickyScheduleToStartTimeout time.Duration
@@ -104,10 +106,12 @@ type (
 	}
 
 	localActivityTaskHandler struct {
-		userContext   context.Context
-		metricsScope  *metrics.TaggedScope
-		logger        *zap.Logger
-		dataConverter encoded.DataConverter
+		userContext        context.Context
+		metricsScope       *metrics.TaggedScope
+		logger             *zap.Logger
+		dataConverter      encoded.DataConverter
+		interceptors       []ActivityInterceptor
+		contextPropagators []ContextPropagator
 	}
 
 	localActivityResult struct {
@@ -150,6 +154,7 @@ func newWorkflowTaskPoller(taskHandler WorkflowTaskHandler, service workflowserv
 		taskHandler:  taskHandler,
 		metricsScope: params.MetricsScope,
 		logger:       params.Logger,
+		tracer:       params.Tracer,
 
 		disableStickyExecution:       params.DisableStickyExecution,
 		StickyScheduleToStartTimeout: params.StickyScheduleToStartTimeout,
@@ -180,7 +185,7 @@ func (wtp *workflowTaskPoller) ProcessTask(task interface{}) error {
 	return nil
 }
 
-func (wtp *workflowTaskPoller) processWorkflowTask(workflowTask *workflowTask) error {
+func (wtp *workflowTaskPoller) processWorkflowTask(workflowTask *workflowTask) (err error) {
 	if workflowTask.task == nil {
 		// We didn't have task, poll might have time out.
 		traceLog(func() {
@@ -193,13 +198,26 @@ func (wtp *workflowTaskPoller) processWorkflowTask(workflowTask *workflowTask) e
 	laResultCh := make(chan *localActivityResult)
 	// close doneCh so local activity worker won't get blocked forever when trying to send back result to laResultCh.
 	defer close(doneCh)
+	// the span of the decision task is failed with the error of its processing, or the error returned if it has none
+	var span opentracing.Span
+	var taskErr error
+	defer func() {
+		if taskErr == nil {
+			taskErr = err
+		}
+		finishSpan(span, taskErr)
+	}()
 
 process_WorkflowTask_Loop:
 	for {
+		// every decision task has its own span, including the ones returned when a decision task is completed
+		finishSpan(span, taskErr)
+		span = startDecisionTaskSpan(wtp.tracer, workflowTask.task)
 		startTime := time.Now()
 		workflowTask.doneCh = doneCh
 		workflowTask.laResultCh = laResultCh
 		completedRequest, wc, err := wtp.taskHandler.ProcessWorkflowTask(workflowTask)
+		taskErr = err
 		if err == nil && completedRequest == nil {
 			// decision task cannot complete because it is waiting for local activity to finish
 			// we need a timer to force complete it to avoid the decision task timeout on server.
@@ -229,6 +247,7 @@ process_WorkflowTask_Loop:
 					if _, ok := err.(*workflowContextAlreadyDestroyedError); ok {
 						return nil
 					}
+					taskErr = err
 
 					if err == nil && completedRequest == nil {
 						// decision task is not done yet, still waiting for more local activities
@@ -414,10 +433,12 @@ func (wtp *workflowTaskPoller) RespondTaskCompleted(completedRequest interface{}
 
 func newLocalActivityPoller(params workerExecutionParameters, laTunnel *localActivityTunnel) *localActivityTaskPoller {
 	handler := &localActivityTaskHandler{
-		userContext:   params.UserContext,
-		metricsScope:  metrics.NewTaggedScope(params.MetricsScope),
-		logger:        params.Logger,
-		dataConverter: params.DataConverter,
+		userContext:        params.UserContext,
+		metricsScope:       metrics.NewTaggedScope(params.MetricsScope),
+		logger:             params.Logger,
+		dataConverter:      params.DataConverter,
+		interceptors:       params.ActivityInterceptors,
+		contextPropagators: params.ContextPropagators,
 	}
 	return &localActivityTaskPoller{
 		handler:      handler,
@@ -470,7 +491,14 @@ func (lath *localActivityTaskHandler) executeLocalActivityTask(task *localActivi
 		isLocalActivity:   true,
 		dataConverter:     lath.dataConverter,
 		attempt:           task.attempt,
+		interceptors:      lath.interceptors,
 	})
+	if len(task.params.Header) > 0 {
+		var err error
+		if ctx, err = extractContext(ctx, task.params.Header, lath.contextPropagators); err != nil {
+			return &localActivityResult{err: err, task: task}
+		}
+	}
 
 	// panic handler
 	defer func() {
@@ -0,0 +1,322 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/encoded"
+)
+
+const (
+	tracingOperationStartWorkflow           = "StartWorkflow"
+	tracingOperationSignalWorkflow          = "SignalWorkflow"
+	tracingOperationSignalWithStartWorkflow = "SignalWithStartWorkflow"
+	tracingOperationQueryWorkflow           = "QueryWorkflow"
+	tracingOperationDecisionTask            = "ProcessDecisionTask"
+	tracingOperationExecuteActivity         = "ExecuteActivity"
+	tracingOperationExecuteLocalActivity    = "ExecuteLocalActivity"
+	tracingOperationExecuteChildWorkflow    = "ExecuteChildWorkflow"
+	tracingOperationRunActivity             = "RunActivity"
+	tracingOperationRunLocalActivity        = "RunLocalActivity"
+)
+
+type (
+	// tracingContextKey is the key of the span context propagated to workflows and activities.
+	tracingContextKey struct{}
+
+	// tracingContextPropagator propagates the span of the client call to the workflow, and the spans of the workflow
+	// calls to the activities and child workflows.
+	tracingContextPropagator struct {
+		tracer opentracing.Tracer
+	}
+
+	// tracingHeaderWriter adapts a HeaderWriter to the opentracing TextMapWriter.
+	tracingHeaderWriter struct {
+		writer HeaderWriter
+	}
+
+	// tracingHeaderReader adapts a HeaderReader to the opentracing TextMapReader.
+	tracingHeaderReader struct {
+		reader HeaderReader
+	}
+
+	// tracingClientInterceptor emits a span for every workflow started, signaled or queried by the client.
+	tracingClientInterceptor struct {
+		tracer opentracing.Tracer
+	}
+
+	tracingClient struct {
+		Client
+		tracer opentracing.Tracer
+	}
+
+	// tracingWorkflowInterceptor emits a span for every activity, local activity and child workflow scheduled by a
+	// workflow. Nothing is emitted while the workflow is replayed, the spans were emitted when the calls were made.
+	// The spans are finished once the calls are scheduled rather than when their results are ready: the cached state
+	// of the workflow can be evicted before then, and the replayed workflow that gets the results has no spans.
+	tracingWorkflowInterceptor struct {
+		tracer opentracing.Tracer
+	}
+
+	tracingWorkflowOperations struct {
+		WorkflowOperations
+		tracer opentracing.Tracer
+	}
+
+	// tracingActivityInterceptor emits a span for every activity and local activity execution.
+	tracingActivityInterceptor struct {
+		tracer opentracing.Tracer
+	}
+)
+
+func (w tracingHeaderWriter) Set(key, value string) {
+	w.writer.Set(key, []byte(value))
+}
+
+func (r tracingHeaderReader) ForeachKey(handler func(key, value string) error) error {
+	return r.reader.ForEachKey(func(key string, value []byte) error {
+		return handler(key, string(value))
+	})
+}
+
+func (p *tracingContextPropagator) Inject(ctx context.Context, writer HeaderWriter) error {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	return p.tracer.Inject(span.Context(), opentracing.TextMap, tracingHeaderWriter{writer: writer})
+}
+
+func (p *tracingContextPropagator) Extract(ctx context.Context, reader HeaderReader) (context.Context, error) {
+	spanContext, err := p.extract(reader)
+	if err != nil || spanContext == nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, tracingContextKey{}, spanContext), nil
+}
+
+func (p *tracingContextPropagator) InjectFromWorkflow(ctx Context, writer HeaderWriter) error {
+	spanContext, ok := ctx.Value(tracingContextKey{}).(opentracing.SpanContext)
+	if !ok {
+		return nil
+	}
+	return p.tracer.Inject(spanContext, opentracing.TextMap, tracingHeaderWriter{writer: writer})
+}
+
+func (p *tracingContextPropagator) ExtractToWorkflow(ctx Context, reader HeaderReader) (Context, error) {
+	spanContext, err := p.extract(reader)
+	if err != nil || spanContext == nil {
+		return ctx, err
+	}
+	return WithValue(ctx, tracingContextKey{}, spanContext), nil
+}
+
+func (p *tracingContextPropagator) extract(reader HeaderReader) (opentracing.SpanContext, error) {
+	spanContext, err := p.tracer.Extract(opentracing.TextMap, tracingHeaderReader{reader: reader})
+	if err == opentracing.ErrSpanContextNotFound {
+		return nil, nil
+	}
+	return spanContext, err
+}
+
+func (i *tracingClientInterceptor) InterceptClient(next Client) Client {
+	return &tracingClient{Client: next, tracer: i.tracer}
+}
+
+func (c *tracingClient) StartWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{},
+	args ...interface{}) (*WorkflowExecution, error) {
+	span, ctx := c.startSpan(ctx, tracingOperationStartWorkflow)
+	execution, err := c.Client.StartWorkflow(ctx, options, workflow, args...)
+	if execution != nil {
+		tagSpanWithExecution(span, execution.ID, execution.RunID)
+	}
+	finishSpan(span, err)
+	return execution, err
+}
+
+func (c *tracingClient) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string,
+	arg interface{}) error {
+	span, ctx := c.startSpan(ctx, tracingOperationSignalWorkflow)
+	tagSpanWithExecution(span, workflowID, runID)
+	err := c.Client.SignalWorkflow(ctx, workflowID, runID, signalName, arg)
+	finishSpan(span, err)
+	return err
+}
+
+func (c *tracingClient) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string,
+	signalArg interface{}, options StartWorkflowOptions, workflow interface{},
+	workflowArgs ...interface{}) (*WorkflowExecution, error) {
+	span, ctx := c.startSpan(ctx, tracingOperationSignalWithStartWorkflow)
+	execution, err := c.Client.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, options, workflow,
+		workflowArgs...)
+	if execution != nil {
+		tagSpanWithExecution(span, execution.ID, execution.RunID)
+	} else {
+		tagSpanWithExecution(span, workflowID, "")
+	}
+	finishSpan(span, err)
+	return execution, err
+}
+
+func (c *tracingClient) QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string,
+	args ...interface{}) (encoded.Value, error) {
+	span, ctx := c.startSpan(ctx, tracingOperationQueryWorkflow)
+	tagSpanWithExecution(span, workflowID, runID)
+	result, err := c.Client.QueryWorkflow(ctx, workflowID, runID, queryType, args...)
+	finishSpan(span, err)
+	return result, err
+}
+
+// startSpan starts a span that is a child of the span of ctx if any, and returns a ctx that carries the new span.
+func (c *tracingClient) startSpan(ctx context.Context, operationName string) (opentracing.Span, context.Context) {
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := c.tracer.StartSpan(operationName, opts...)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+func (i *tracingWorkflowInterceptor) InterceptWorkflow(info *WorkflowInfo, next WorkflowOperations) WorkflowOperations {
+	return &tracingWorkflowOperations{WorkflowOperations: next, tracer: i.tracer}
+}
+
+func (o *tracingWorkflowOperations) ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	span, ctx := o.startSpan(ctx, tracingOperationExecuteActivity, tagActivityType, activity)
+	future := o.WorkflowOperations.ExecuteActivity(ctx, activity, args...)
+	finishSpan(span, nil)
+	return future
+}
+
+func (o *tracingWorkflowOperations) ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	span, ctx := o.startSpan(ctx, tracingOperationExecuteLocalActivity, tagLocalActivityType, activity)
+	future := o.WorkflowOperations.ExecuteLocalActivity(ctx, activity, args...)
+	finishSpan(span, nil)
+	return future
+}
+
+func (o *tracingWorkflowOperations) ExecuteChildWorkflow(ctx Context, childWorkflow interface{},
+	args ...interface{}) ChildWorkflowFuture {
+	span, ctx := o.startSpan(ctx, tracingOperationExecuteChildWorkflow, tagChildWorkflowType, childWorkflow)
+	future := o.WorkflowOperations.ExecuteChildWorkflow(ctx, childWorkflow, args...)
+	finishSpan(span, nil)
+	return future
+}
+
+// startSpan starts a span that is a child of the span propagated to the workflow, and returns a ctx that propagates
+// the new span to the scheduled activity or child workflow. It returns a nil span while the workflow is replayed.
+func (o *tracingWorkflowOperations) startSpan(ctx Context, operationName string, typeTag string,
+	typeOrFunc interface{}) (opentracing.Span, Context) {
+	if IsReplaying(ctx) {
+		return nil, ctx
+	}
+	info := GetWorkflowInfo(ctx)
+	opts := []opentracing.StartSpanOption{
+		opentracing.Tag{Key: typeTag, Value: getTypeOrFunctionName(typeOrFunc)},
+		opentracing.Tag{Key: tagWorkflowType, Value: info.WorkflowType.Name},
+		opentracing.Tag{Key: tagWorkflowID, Value: info.WorkflowExecution.ID},
+		opentracing.Tag{Key: tagRunID, Value: info.WorkflowExecution.RunID},
+	}
+	if parent, ok := ctx.Value(tracingContextKey{}).(opentracing.SpanContext); ok {
+		opts = append(opts, opentracing.ChildOf(parent))
+	}
+	span := o.tracer.StartSpan(operationName, opts...)
+	return span, WithValue(ctx, tracingContextKey{}, span.Context())
+}
+
+func (i *tracingActivityInterceptor) InterceptActivity(ctx context.Context, args []interface{},
+	next ActivityHandler) (interface{}, error) {
+	operationName, typeTag := tracingOperationRunActivity, tagActivityType
+	if getActivityEnv(ctx).isLocalActivity {
+		operationName, typeTag = tracingOperationRunLocalActivity, tagLocalActivityType
+	}
+	info := GetActivityInfo(ctx)
+	opts := []opentracing.StartSpanOption{
+		opentracing.Tag{Key: typeTag, Value: info.ActivityType.Name},
+		opentracing.Tag{Key: tagActivityID, Value: info.ActivityID},
+		opentracing.Tag{Key: tagWorkflowID, Value: info.WorkflowExecution.ID},
+		opentracing.Tag{Key: tagRunID, Value: info.WorkflowExecution.RunID},
+	}
+	if parent, ok := ctx.Value(tracingContextKey{}).(opentracing.SpanContext); ok {
+		opts = append(opts, opentracing.ChildOf(parent))
+	}
+	span := i.tracer.StartSpan(operationName, opts...)
+	result, err := next(opentracing.ContextWithSpan(ctx, span), args)
+	finishSpan(span, err)
+	return result, err
+}
+
+// addTracing makes the worker emit the spans of its decision tasks, workflow calls and activities, and propagate
+// them. The tracing interceptors are the outermost ones, so the interceptors of the user run within the spans.
+func addTracing(params *workerExecutionParameters, tracer opentracing.Tracer) {
+	params.Tracer = tracer
+	params.WorkflowInterceptors = append([]WorkflowInterceptor{&tracingWorkflowInterceptor{tracer: tracer}},
+		params.WorkflowInterceptors...)
+	params.ActivityInterceptors = append([]ActivityInterceptor{&tracingActivityInterceptor{tracer: tracer}},
+		params.ActivityInterceptors...)
+	params.ContextPropagators = append(append([]ContextPropagator(nil), params.ContextPropagators...),
+		&tracingContextPropagator{tracer: tracer})
+}
+
+// startDecisionTaskSpan starts the span of a decision task, it returns nil if there is no tracer.
+func startDecisionTaskSpan(tracer opentracing.Tracer, task *s.PollForDecisionTaskResponse) opentracing.Span {
+	if tracer == nil || task == nil {
+		return nil
+	}
+	span := tracer.StartSpan(tracingOperationDecisionTask,
+		opentracing.Tag{Key: tagWorkflowType, Value: task.WorkflowType.GetName()})
+	tagSpanWithExecution(span, task.WorkflowExecution.GetWorkflowId(), task.WorkflowExecution.GetRunId())
+	return span
+}
+
+func tagSpanWithExecution(span opentracing.Span, workflowID, runID string) {
+	if workflowID != "" {
+		span.SetTag(tagWorkflowID, workflowID)
+	}
+	if runID != "" {
+		span.SetTag(tagRunID, runID)
+	}
+}
+
+// finishSpan marks span as failed if err is not nil and finishes it. It does nothing if span is nil.
+func finishSpan(span opentracing.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+	span.Finish()
+}
+
+// getTypeOrFunctionName returns the name of an activity or workflow given by type name or function.
+func getTypeOrFunctionName(typeOrFunc interface{}) string {
+	if name, ok := typeOrFunc.(string); ok {
+		return name
+	}
+	return getFunctionName(typeOrFunc)
+}
@@ -40,6 +40,7 @@ import (
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/opentracing/opentracing-go"
 	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shared"
@@ -159,6 +160,21 @@ type (
 		NonDeterministicWorkflowPolicy NonDeterministicWorkflowPolicy
 
 		DataConverter encoded.DataConverter
+
+		// Heartbeat on behalf of activities that have a heartbeat timeout.
+		AutoHeartBeat bool
+
+		// WorkflowInterceptors wrap the calls of every workflow execution.
+		WorkflowInterceptors []WorkflowInterceptor
+
+		// ActivityInterceptors wrap every activity and local activity execution.
+		ActivityInterceptors []ActivityInterceptor
+
+		// ContextPropagators propagate context values to workflows, activities and child workflows.
+		ContextPropagators []ContextPropagator
+
+		// Tracer emits the spans of decision tasks, workflow calls and activities.
+		Tracer opentracing.Tracer
 	}
 
 	// defaultDataConverter uses thrift encoder/decoder when possible, for everything else use json.
@@ -172,7 +188,7 @@ func newWorkflowWorker(
 	params workerExecutionParameters,
 	ppMgr pressurePointMgr,
 	hostEnv *hostEnvImpl,
-) Worker {
+) *workflowWorker {
 	return newWorkflowWorkerInternal(service, domain, params, ppMgr, nil, hostEnv)
 }
 
@@ -239,7 +255,7 @@ func newWorkflowWorkerInternal(
 	ppMgr pressurePointMgr,
 	overrides *workerOverrides,
 	hostEnv *hostEnvImpl,
-) Worker {
+) *workflowWorker {
 	// Get a workflow task handler.
 	ensureRequiredParams(&params)
 	var taskHandler WorkflowTaskHandler
@@ -256,7 +272,7 @@ func newWorkflowTaskWorkerInternal(
 	service workflowserviceclient.Interface,
 	domain string,
 	params workerExecutionParameters,
-) Worker {
+) *workflowWorker {
 	ensureRequiredParams(&params)
 	poller := newWorkflowTaskPoller(
 		taskHandler,
@@ -347,7 +363,7 @@ func newActivityWorker(
 	params workerExecutionParameters,
 	overrides *workerOverrides,
 	env *hostEnvImpl,
-) Worker {
+) *activityWorker {
 	ensureRequiredParams(&params)
 	// Get a activity task handler.
 	var taskHandler ActivityTaskHandler
@@ -364,7 +380,7 @@ func newActivityTaskWorker(
 	service workflowserviceclient.Interface,
 	domain string,
 	workerParams workerExecutionParameters,
-) (worker Worker) {
+) *activityWorker {
 	ensureRequiredParams(&workerParams)
 
 	poller := newActivityTaskPoller(
@@ -423,13 +439,17 @@ func (aw *activityWorker) Stop() {
 	aw.worker.Stop()
 }
 
-// hostEnvImpl is the implementation of hostEnv
+// hostEnvImpl is the implementation of hostEnv. Each worker has its own hostEnvImpl that falls back to the global
+// one, so that registrations done through the package level functions are visible to every worker.
 type hostEnvImpl struct {
 	sync.Mutex
 	workflowFuncMap  map[string]interface{}
 	workflowAliasMap map[string]string
 	activityFuncMap  map[string]activity
 	activityAliasMap map[string]string
+	dynamicWorkflow  DynamicWorkflowFunc
+	dynamicActivity  DynamicActivityFunc
+	fallback         *hostEnvImpl
 }
 
 func (th *hostEnvImpl) RegisterWorkflow(af interface{}) error {
@@ -451,8 +471,8 @@ func (th *hostEnvImpl) RegisterWorkflowWithOptions(
 	if len(alias) > 0 {
 		registerName = alias
 	}
-	// Check if already registered
-	if _, ok := th.getWorkflowFn(registerName); ok {
+	// Check if already registered, a worker is allowed to override a registration of the global registry
+	if _, ok := th.getLocalWorkflowFn(registerName); ok {
 		return fmt.Errorf("workflow name \"%v\" is already registered", registerName)
 	}
 	th.addWorkflowFn(registerName, af)
@@ -470,8 +490,11 @@ func (th *hostEnvImpl) RegisterActivityWithOptions(
 	af interface{},
 	options RegisterActivityOptions,
 ) error {
-	// Validate that it is a function
 	fnType := reflect.TypeOf(af)
+	if fnType != nil && fnType.Kind() == reflect.Ptr && fnType.Elem().Kind() == reflect.Struct {
+		return th.registerActivityStructWithOptions(af, options)
+	}
+	// Validate that it is a function
 	if err := validateFnFormat(fnType, false); err != nil {
 		return err
 	}
@@ -481,8 +504,8 @@ func (th *hostEnvImpl) RegisterActivityWithOptions(
 	if len(alias) > 0 {
 		registerName = alias
 	}
-	// Check if already registered
-	if _, ok := th.getActivityFn(registerName); ok {
+	// Check if already registered, a worker is allowed to override a registration of the global registry
+	if _, ok := th.getLocalActivity(registerName); ok {
 		return fmt.Errorf("activity type \"%v\" is already registered", registerName)
 	}
 	th.addActivityFn(registerName, af)
@@ -492,6 +515,55 @@ func (th *hostEnvImpl) RegisterActivityWithOptions(
 	return nil
 }
 
+// registerActivityStructWithOptions registers the exported methods of a struct that comply with the activity function
+// format. The activities are named after the struct unless options.Name provides another prefix. A struct type can be
+// registered only once per registry, as method values passed to ExecuteActivity resolve to a single activity type.
+func (th *hostEnvImpl) registerActivityStructWithOptions(aStruct interface{}, options RegisterActivityOptions) error {
+	structValue := reflect.ValueOf(aStruct)
+	structType := structValue.Type()
+	prefix := options.Name
+	if len(prefix) == 0 {
+		prefix = structType.Elem().Name() + "."
+	}
+
+	type structActivity struct {
+		registerName string
+		method       reflect.Method
+		fn           interface{}
+	}
+	var activities []structActivity
+	for i := 0; i < structValue.NumMethod(); i++ {
+		methodValue := structValue.Method(i)
+		if err := validateFnFormat(methodValue.Type(), false); err != nil {
+			// not every method of the struct has to be an activity
+			continue
+		}
+		method := structType.Method(i)
+		if _, ok := th.getLocalActivityAlias(getFunctionName(method.Func.Interface()) + "-fm"); ok {
+			return fmt.Errorf("activities of %v are already registered", structType)
+		}
+		registerName := prefix + method.Name
+		if _, ok := th.getLocalActivity(registerName); ok {
+			return fmt.Errorf("activity type \"%v\" is already registered", registerName)
+		}
+		activities = append(activities, structActivity{registerName, method, methodValue.Interface()})
+	}
+	if len(activities) == 0 {
+		return fmt.Errorf("no activities (exported methods) found in %v", structType)
+	}
+
+	for _, a := range activities {
+		th.addActivityFn(a.registerName, a.fn)
+		// Method values passed to ExecuteActivity are named after the receiver type the method is declared on, with
+		// a "-fm" suffix.
+		th.addActivityAlias(getFunctionName(a.method.Func.Interface())+"-fm", a.registerName)
+		if m, ok := structType.Elem().MethodByName(a.method.Name); ok {
+			th.addActivityAlias(getFunctionName(m.Func.Interface())+"-fm", a.registerName)
+		}
+	}
+	return nil
+}
+
 func (th *hostEnvImpl) addWorkflowAlias(fnName string, alias string) {
 	th.Lock()
 	defer th.Unlock()
@@ -500,8 +572,11 @@ func (th *hostEnvImpl) addWorkflowAlias(fnName string, alias string) {
 
 func (th *hostEnvImpl) getWorkflowAlias(fnName string) (string, bool) {
 	th.Lock()
-	defer th.Unlock()
 	alias, ok := th.workflowAliasMap[fnName]
+	th.Unlock()
+	if !ok && th.fallback != nil {
+		return th.fallback.getWorkflowAlias(fnName)
+	}
 	return alias, ok
 }
 
@@ -512,6 +587,14 @@ func (th *hostEnvImpl) addWorkflowFn(fnName string, wf interface{}) {
 }
 
 func (th *hostEnvImpl) getWorkflowFn(fnName string) (interface{}, bool) {
+	fn, ok := th.getLocalWorkflowFn(fnName)
+	if !ok && th.fallback != nil {
+		return th.fallback.getWorkflowFn(fnName)
+	}
+	return fn, ok
+}
+
+func (th *hostEnvImpl) getLocalWorkflowFn(fnName string) (interface{}, bool) {
 	th.Lock()
 	defer th.Unlock()
 	fn, ok := th.workflowFuncMap[fnName]
@@ -519,9 +602,16 @@ func (th *hostEnvImpl) getWorkflowFn(fnName string) (interface{}, bool) {
 }
 
 func (th *hostEnvImpl) getRegisteredWorkflowTypes() []string {
+	var r []string
+	if th.fallback != nil {
+		for _, t := range th.fallback.getRegisteredWorkflowTypes() {
+			if _, ok := th.getLocalWorkflowFn(t); !ok {
+				r = append(r, t)
+			}
+		}
+	}
 	th.Lock()
 	defer th.Unlock()
-	var r []string
 	for t := range th.workflowFuncMap {
 		r = append(r, t)
 	}
@@ -535,6 +625,16 @@ func (th *hostEnvImpl) addActivityAlias(fnName string, alias string) {
 }
 
 func (th *hostEnvImpl) getActivityAlias(fnName string) (string, bool) {
+	th.Lock()
+	alias, ok := th.activityAliasMap[fnName]
+	th.Unlock()
+	if !ok && th.fallback != nil {
+		return th.fallback.getActivityAlias(fnName)
+	}
+	return alias, ok
+}
+
+func (th *hostEnvImpl) getLocalActivityAlias(fnName string) (string, bool) {
 	th.Lock()
 	defer th.Unlock()
 	alias, ok := th.activityAliasMap[fnName]
@@ -552,6 +652,14 @@ func (th *hostEnvImpl) addActivityFn(fnName string, af interface{}) {
 }
 
 func (th *hostEnvImpl) getActivity(fnName string) (activity, bool) {
+	a, ok := th.getLocalActivity(fnName)
+	if !ok && th.fallback != nil {
+		return th.fallback.getActivity(fnName)
+	}
+	return a, ok
+}
+
+func (th *hostEnvImpl) getLocalActivity(fnName string) (activity, bool) {
 	th.Lock()
 	defer th.Unlock()
 	a, ok := th.activityFuncMap[fnName]
@@ -566,7 +674,16 @@ func (th *hostEnvImpl) getActivityFn(fnName string) (interface{}, bool) {
 }
 
 func (th *hostEnvImpl) getRegisteredActivities() []activity {
-	activities := make([]activity, 0, len(th.activityFuncMap))
+	var activities []activity
+	if th.fallback != nil {
+		for _, a := range th.fallback.getRegisteredActivities() {
+			if _, ok := th.getLocalActivity(a.ActivityType().Name); !ok {
+				activities = append(activities, a)
+			}
+		}
+	}
+	th.Lock()
+	defer th.Unlock()
 	for _, a := range th.activityFuncMap {
 		activities = append(activities, a)
 	}
@@ -606,6 +723,52 @@ func isUseThriftDecoding(objs []interface{}) bool {
 	return true
 }
 
+func (th *hostEnvImpl) RegisterDynamicWorkflow(wf DynamicWorkflowFunc) error {
+	if wf == nil {
+		return errors.New("dynamic workflow function is nil")
+	}
+	th.Lock()
+	defer th.Unlock()
+	if th.dynamicWorkflow != nil {
+		return errors.New("dynamic workflow function is already registered")
+	}
+	th.dynamicWorkflow = wf
+	return nil
+}
+
+func (th *hostEnvImpl) RegisterDynamicActivity(af DynamicActivityFunc) error {
+	if af == nil {
+		return errors.New("dynamic activity function is nil")
+	}
+	th.Lock()
+	defer th.Unlock()
+	if th.dynamicActivity != nil {
+		return errors.New("dynamic activity function is already registered")
+	}
+	th.dynamicActivity = af
+	return nil
+}
+
+func (th *hostEnvImpl) getDynamicWorkflow() DynamicWorkflowFunc {
+	th.Lock()
+	wf := th.dynamicWorkflow
+	th.Unlock()
+	if wf == nil && th.fallback != nil {
+		return th.fallback.getDynamicWorkflow()
+	}
+	return wf
+}
+
+func (th *hostEnvImpl) getDynamicActivity() DynamicActivityFunc {
+	th.Lock()
+	af := th.dynamicActivity
+	th.Unlock()
+	if af == nil && th.fallback != nil {
+		return th.fallback.getDynamicActivity()
+	}
+	return af
+}
+
 func (th *hostEnvImpl) getWorkflowDefinition(wt WorkflowType) (workflowDefinition, error) {
 	lookup := wt.Name
 	if alias, ok := th.getWorkflowAlias(lookup); ok {
@@ -613,6 +776,9 @@ func (th *hostEnvImpl) getWorkflowDefinition(wt WorkflowType) (workflowDefinitio
 	}
 	wf, ok := th.getWorkflowFn(lookup)
 	if !ok {
+		if dwf := th.getDynamicWorkflow(); dwf != nil {
+			return newWorkflowDefinition(&dynamicWorkflowExecutor{name: wt.Name, fn: dwf}), nil
+		}
 		supported := strings.Join(th.getRegisteredWorkflowTypes(), ", ")
 		return nil, fmt.Errorf("unable to find workflow type: %v. Supported types: [%v]", lookup, supported)
 	}
@@ -758,6 +924,21 @@ func getHostEnvironment() *hostEnvImpl {
 	return thImpl
 }
 
+// newWorkerHostEnvironment creates the registry of a worker, which falls back to the global one.
+func newWorkerHostEnvironment() *hostEnvImpl {
+	env := newHostEnvironment()
+	env.fallback = getHostEnvironment()
+	return env
+}
+
+// getWorkerRegistry returns the registry of a worker created by NewWorker, or the global one for other workers.
+func getWorkerRegistry(w Worker) *hostEnvImpl {
+	if aw, ok := w.(*aggregatedWorker); ok {
+		return aw.hostEnv
+	}
+	return getHostEnvironment()
+}
+
 // Wrapper to execute workflow functions.
 type workflowExecutor struct {
 	name string
@@ -806,16 +987,11 @@ func (ae *activityExecutor) GetFunction() interface{} {
 
 func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte, error) {
 	fnType := reflect.TypeOf(ae.fn)
-	args := []reflect.Value{}
+	var args []interface{}
 	dataConverter := getDataConverterFromActivityCtx(ctx)
 
-	// activities optionally might not take context.
-	if fnType.NumIn() > 0 && isActivityContext(fnType.In(0)) {
-		args = append(args, reflect.ValueOf(ctx))
-	}
-
 	if fnType.NumIn() == 1 && isTypeByteSlice(fnType.In(0)) {
-		args = append(args, reflect.ValueOf(input))
+		args = append(args, input)
 	} else {
 		decoded, err := decodeArgs(dataConverter, fnType, input)
 		if err != nil {
@@ -823,19 +999,21 @@ func (ae *activityExecutor) Execute(ctx context.Context, input []byte) ([]byte,
 				"unable to decode the activity function input bytes with error: %v for function name: %v",
 				err, ae.name)
 		}
-		args = append(args, decoded...)
+		for _, arg := range decoded {
+			args = append(args, arg.Interface())
+		}
 	}
 
-	fnValue := reflect.ValueOf(ae.fn)
-	retValues := fnValue.Call(args)
-	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
+	return ae.ExecuteWithActualArgs(ctx, args)
 }
 
 func (ae *activityExecutor) ExecuteWithActualArgs(ctx context.Context, actualArgs []interface{}) ([]byte, error) {
-	retValues := ae.executeWithActualArgsWithoutParseResult(ctx, actualArgs)
 	dataConverter := getDataConverterFromActivityCtx(ctx)
-
-	return validateFunctionAndGetResults(ae.fn, retValues, dataConverter)
+	result, err := interceptActivity(ctx, actualArgs, func(ctx context.Context, args []interface{}) (interface{}, error) {
+		retValues := ae.executeWithActualArgsWithoutParseResult(ctx, args)
+		return getFunctionResults(ae.fn, retValues)
+	})
+	return encodeFunctionResult(result, err, dataConverter)
 }
 
 func (ae *activityExecutor) executeWithActualArgsWithoutParseResult(ctx context.Context, actualArgs []interface{}) []reflect.Value {
@@ -862,6 +1040,41 @@ func (ae *activityExecutor) executeWithActualArgsWithoutParseResult(ctx context.
 	return retValues
 }
 
+// dynamicWorkflowExecutor runs the dynamic workflow function for a workflow type that has no registration.
+type dynamicWorkflowExecutor struct {
+	name string
+	fn   DynamicWorkflowFunc
+}
+
+func (we *dynamicWorkflowExecutor) Execute(ctx Context, input []byte) ([]byte, error) {
+	dataConverter := getWorkflowEnvOptions(ctx).dataConverter
+	result, err := we.fn(ctx, we.name, newEncodedValues(input, dataConverter))
+	return encodeFunctionResult(result, err, dataConverter)
+}
+
+// dynamicActivityExecutor runs the dynamic activity function for an activity type that has no registration.
+type dynamicActivityExecutor struct {
+	name string
+	fn   DynamicActivityFunc
+}
+
+func (ae *dynamicActivityExecutor) ActivityType() ActivityType {
+	return ActivityType{Name: ae.name}
+}
+
+func (ae *dynamicActivityExecutor) GetFunction() interface{} {
+	return ae.fn
+}
+
+func (ae *dynamicActivityExecutor) Execute(ctx context.Context, input []byte) ([]byte, error) {
+	dataConverter := getDataConverterFromActivityCtx(ctx)
+	args := []interface{}{newEncodedValues(input, dataConverter)}
+	result, err := interceptActivity(ctx, args, func(ctx context.Context, args []interface{}) (interface{}, error) {
+		return ae.fn(ctx, ae.name, args[0].(encoded.Values))
+	})
+	return encodeFunctionResult(result, err, dataConverter)
+}
+
 func getDataConverterFromActivityCtx(ctx context.Context) encoded.DataConverter {
 	if ctx == nil || ctx.Value(activityEnvContextKey) == nil {
 		return getDefaultDataConverter()
@@ -875,19 +1088,51 @@ func getDataConverterFromActivityCtx(ctx context.Context) encoded.DataConverter
 
 // aggregatedWorker combines management of both workflowWorker and activityWorker worker lifecycle.
 type aggregatedWorker struct {
-	workflowWorker Worker
-	activityWorker Worker
+	workflowWorker *workflowWorker
+	activityWorker *activityWorker
 	logger         *zap.Logger
 	hostEnv        *hostEnvImpl
 }
 
+func (aw *aggregatedWorker) RegisterWorkflow(w interface{}) {
+	aw.RegisterWorkflowWithOptions(w, RegisterWorkflowOptions{})
+}
+
+func (aw *aggregatedWorker) RegisterWorkflowWithOptions(w interface{}, options RegisterWorkflowOptions) {
+	if err := aw.hostEnv.RegisterWorkflowWithOptions(w, options); err != nil {
+		panic(err)
+	}
+}
+
+func (aw *aggregatedWorker) RegisterDynamicWorkflow(w DynamicWorkflowFunc) {
+	if err := aw.hostEnv.RegisterDynamicWorkflow(w); err != nil {
+		panic(err)
+	}
+}
+
+func (aw *aggregatedWorker) RegisterActivity(a interface{}) {
+	aw.RegisterActivityWithOptions(a, RegisterActivityOptions{})
+}
+
+func (aw *aggregatedWorker) RegisterActivityWithOptions(a interface{}, options RegisterActivityOptions) {
+	if err := aw.hostEnv.RegisterActivityWithOptions(a, options); err != nil {
+		panic(err)
+	}
+}
+
+func (aw *aggregatedWorker) RegisterDynamicActivity(a DynamicActivityFunc) {
+	if err := aw.hostEnv.RegisterDynamicActivity(a); err != nil {
+		panic(err)
+	}
+}
+
 func (aw *aggregatedWorker) Start() error {
 	if err := initBinaryChecksum(); err != nil {
 		return fmt.Errorf("failed to get executable checksum: %v", err)
 	}
 
 	if !isInterfaceNil(aw.workflowWorker) {
-		if len(aw.hostEnv.getRegisteredWorkflowTypes()) == 0 {
+		if len(aw.hostEnv.getRegisteredWorkflowTypes()) == 0 && aw.hostEnv.getDynamicWorkflow() == nil {
 			aw.logger.Warn(
 				"Starting worker without any workflows. Workflows must be registered before start.",
 			)
@@ -897,7 +1142,7 @@ func (aw *aggregatedWorker) Start() error {
 		}
 	}
 	if !isInterfaceNil(aw.activityWorker) {
-		if len(aw.hostEnv.getRegisteredActivities()) == 0 {
+		if len(aw.hostEnv.getRegisteredActivities()) == 0 && aw.hostEnv.getDynamicActivity() == nil {
 			aw.logger.Warn(
 				"Starting worker without any activities. Activities must be registered before start.",
 			)
@@ -1004,6 +1249,13 @@ func newAggregatedWorker(
 		TaskListActivitiesPerSecond:          wOptions.TaskListActivitiesPerSecond,
 		NonDeterministicWorkflowPolicy:       wOptions.NonDeterministicWorkflowPolicy,
 		DataConverter:                        wOptions.DataConverter,
+		AutoHeartBeat:                        wOptions.AutoHeartBeat,
+		WorkflowInterceptors:                 wOptions.WorkflowInterceptors,
+		ActivityInterceptors:                 wOptions.ActivityInterceptors,
+		ContextPropagators:                   wOptions.ContextPropagators,
+	}
+	if wOptions.Tracer != nil {
+		addTracing(&workerParams, wOptions.Tracer)
 	}
 
 	ensureRequiredParams(&workerParams)
@@ -1018,9 +1270,9 @@ func newAggregatedWorker(
 
 	processTestTags(&wOptions, &workerParams)
 
-	hostEnv := getHostEnvironment()
+	hostEnv := newWorkerHostEnvironment()
 	// workflow factory.
-	var workflowWorker Worker
+	var workflowWorker *workflowWorker
 	if !wOptions.DisableWorkflowWorker {
 		testTags := getTestTags(wOptions.BackgroundActivityContext)
 		if testTags != nil && len(testTags) > 0 {
@@ -1043,7 +1295,7 @@ func newAggregatedWorker(
 	}
 
 	// activity types.
-	var activityWorker Worker
+	var activityWorker *activityWorker
 
 	if !wOptions.DisableActivityWorker {
 		activityWorker = newActivityWorker(
@@ -81,6 +81,9 @@ type (
 		IsReplaying() bool
 		MutableSideEffect(id string, f func() interface{}, equals func(a, b interface{}) bool) encoded.Value
 		GetDataConverter() encoded.DataConverter
+		GetRegistry() *hostEnvImpl
+		GetWorkflowInterceptors() []WorkflowInterceptor
+		GetContextPropagators() []ContextPropagator
 	}
 
 	// WorkflowDefinition wraps the code that can execute a workflow.
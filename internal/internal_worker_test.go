@@ -164,6 +164,35 @@ func (s *internalWorkerTestSuite) TestReplayWorkflowHistoryFromFile() {
 	require.NoError(s.T(), err)
 }
 
+func (s *internalWorkerTestSuite) TestReplayWorkerWorkflowHistory() {
+	taskList := "taskList1"
+	testEvents := []*shared.HistoryEvent{
+		createTestEventWorkflowExecutionStarted(1, &shared.WorkflowExecutionStartedEventAttributes{
+			WorkflowType: &shared.WorkflowType{Name: common.StringPtr("workerOnlyReplayWorkflow")},
+			TaskList:     &shared.TaskList{Name: common.StringPtr(taskList)},
+			Input:        testEncodeFunctionArgs(nil, testReplayWorkflow),
+		}),
+		createTestEventDecisionTaskScheduled(2, &shared.DecisionTaskScheduledEventAttributes{}),
+		createTestEventDecisionTaskStarted(3),
+		createTestEventDecisionTaskCompleted(4, &shared.DecisionTaskCompletedEventAttributes{}),
+		createTestEventActivityTaskScheduled(5, &shared.ActivityTaskScheduledEventAttributes{
+			ActivityId:   common.StringPtr("0"),
+			ActivityType: &shared.ActivityType{Name: common.StringPtr("testActivity")},
+			TaskList:     &shared.TaskList{Name: &taskList},
+		}),
+		createTestEventActivityTaskStarted(6, &shared.ActivityTaskStartedEventAttributes{}),
+	}
+	history := &shared.History{Events: testEvents}
+	logger := getLogger()
+
+	// the workflow type is only registered with the worker
+	require.Error(s.T(), ReplayWorkflowHistory(logger, history))
+
+	worker := &aggregatedWorker{hostEnv: newWorkerHostEnvironment()}
+	worker.RegisterWorkflowWithOptions(testReplayWorkflow, RegisterWorkflowOptions{Name: "workerOnlyReplayWorkflow"})
+	require.NoError(s.T(), ReplayWorkerWorkflowHistory(worker, logger, history))
+}
+
 func (s *internalWorkerTestSuite) testDecisionTaskHandlerHelper(params workerExecutionParameters) {
 	taskList := "taskList1"
 	testEvents := []*shared.HistoryEvent{
@@ -276,6 +305,52 @@ func (s *internalWorkerTestSuite) TestNoActivitiesOrWorkflows() {
 	assert.NoError(t, aw.Start())
 }
 
+func (s *internalWorkerTestSuite) TestWorkerRegistry() {
+	t := s.T()
+	workflowFn1 := func(ctx Context) error { return nil }
+	workflowFn2 := func(ctx Context) error { return nil }
+	activityFn1 := func() error { return nil }
+	activityFn2 := func() error { return nil }
+
+	w1 := createWorker(s.service)
+	w1.RegisterWorkflowWithOptions(workflowFn1, RegisterWorkflowOptions{Name: "perWorkerWorkflow"})
+	w1.RegisterActivityWithOptions(activityFn1, RegisterActivityOptions{Name: "perWorkerActivity"})
+	w2 := createWorker(s.service)
+	w2.RegisterWorkflowWithOptions(workflowFn2, RegisterWorkflowOptions{Name: "perWorkerWorkflow"})
+	w2.RegisterActivityWithOptions(activityFn2, RegisterActivityOptions{Name: "perWorkerActivity"})
+	// a worker registration overrides a global one
+	w2.RegisterActivityWithOptions(activityFn2, RegisterActivityOptions{Name: "testActivity"})
+
+	env1 := w1.(*aggregatedWorker).hostEnv
+	env2 := w2.(*aggregatedWorker).hostEnv
+	fn, ok := env1.getWorkflowFn("perWorkerWorkflow")
+	assert.True(t, ok)
+	assert.Equal(t, getFunctionName(workflowFn1), getFunctionName(fn))
+	fn, ok = env2.getWorkflowFn("perWorkerWorkflow")
+	assert.True(t, ok)
+	assert.Equal(t, getFunctionName(workflowFn2), getFunctionName(fn))
+	fn, ok = env1.getActivityFn("perWorkerActivity")
+	assert.True(t, ok)
+	assert.Equal(t, getFunctionName(activityFn1), getFunctionName(fn))
+	fn, ok = env2.getActivityFn("testActivity")
+	assert.True(t, ok)
+	assert.Equal(t, getFunctionName(activityFn2), getFunctionName(fn))
+
+	// global registrations are visible to every worker, worker registrations are not visible globally
+	_, ok = env1.getWorkflowFn("sampleWorkflowExecute")
+	assert.True(t, ok)
+	fn, ok = env1.getActivityFn("testActivity")
+	assert.True(t, ok)
+	assert.Equal(t, getFunctionName(testActivity), getFunctionName(fn))
+	assert.Contains(t, env1.getRegisteredWorkflowTypes(), "sampleWorkflowExecute")
+	_, ok = getHostEnvironment().getWorkflowFn("perWorkerWorkflow")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		w1.RegisterWorkflowWithOptions(workflowFn2, RegisterWorkflowOptions{Name: "perWorkerWorkflow"})
+	})
+}
+
 func (s *internalWorkerTestSuite) TestWorkerStartFailsWithInvalidDomain() {
 	t := s.T()
 	testCases := []struct {
@@ -770,7 +845,7 @@ func testActivityErrorWithDetailsHelper(ctx context.Context, t *testing.T, dataC
 		}}
 
 	encResult, e := a1.Execute(ctx, testEncodeFunctionArgs(dataConverter, a1.fn, 1))
-	err := deSerializeFunctionResult(a1.fn, encResult, nil, dataConverter)
+	err := deSerializeFunctionResult(a1.fn, encResult, nil, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Error(t, e)
 	errWD := e.(*CustomError)
@@ -785,7 +860,7 @@ func testActivityErrorWithDetailsHelper(ctx context.Context, t *testing.T, dataC
 			return NewCustomError("testReason", testErrorDetails{T: "testErrorStack"})
 		}}
 	encResult, e = a2.Execute(ctx, testEncodeFunctionArgs(dataConverter, a2.fn, 1))
-	err = deSerializeFunctionResult(a2.fn, encResult, nil, dataConverter)
+	err = deSerializeFunctionResult(a2.fn, encResult, nil, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Error(t, e)
 	errWD = e.(*CustomError)
@@ -801,7 +876,7 @@ func testActivityErrorWithDetailsHelper(ctx context.Context, t *testing.T, dataC
 		}}
 	encResult, e = a3.Execute(ctx, testEncodeFunctionArgs(dataConverter, a3.fn, 1))
 	var result string
-	err = deSerializeFunctionResult(a3.fn, encResult, &result, dataConverter)
+	err = deSerializeFunctionResult(a3.fn, encResult, &result, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, "testResult", result)
 	require.Error(t, e)
@@ -816,7 +891,7 @@ func testActivityErrorWithDetailsHelper(ctx context.Context, t *testing.T, dataC
 			return "testResult4", NewCustomError("testReason", "testMultipleString", testErrorDetails{T: "testErrorStack4"})
 		}}
 	encResult, e = a4.Execute(ctx, testEncodeFunctionArgs(dataConverter, a4.fn, 1))
-	err = deSerializeFunctionResult(a3.fn, encResult, &result, dataConverter)
+	err = deSerializeFunctionResult(a3.fn, encResult, &result, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, "testResult4", result)
 	require.Error(t, e)
@@ -845,7 +920,7 @@ func testActivityCancelledErrorHelper(ctx context.Context, t *testing.T, dataCon
 			return NewCanceledError("testCancelStringDetails")
 		}}
 	encResult, e := a1.Execute(ctx, testEncodeFunctionArgs(dataConverter, a1.fn, 1))
-	err := deSerializeFunctionResult(a1.fn, encResult, nil, dataConverter)
+	err := deSerializeFunctionResult(a1.fn, encResult, nil, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Error(t, e)
 	errWD := e.(*CanceledError)
@@ -859,7 +934,7 @@ func testActivityCancelledErrorHelper(ctx context.Context, t *testing.T, dataCon
 			return NewCanceledError(testErrorDetails{T: "testCancelErrorStack"})
 		}}
 	encResult, e = a2.Execute(ctx, testEncodeFunctionArgs(dataConverter, a2.fn, 1))
-	err = deSerializeFunctionResult(a2.fn, encResult, nil, dataConverter)
+	err = deSerializeFunctionResult(a2.fn, encResult, nil, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Error(t, e)
 	errWD = e.(*CanceledError)
@@ -874,7 +949,7 @@ func testActivityCancelledErrorHelper(ctx context.Context, t *testing.T, dataCon
 		}}
 	encResult, e = a3.Execute(ctx, testEncodeFunctionArgs(dataConverter, a2.fn, 1))
 	var r string
-	err = deSerializeFunctionResult(a3.fn, encResult, &r, dataConverter)
+	err = deSerializeFunctionResult(a3.fn, encResult, &r, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, "testResult", r)
 	require.Error(t, e)
@@ -888,7 +963,7 @@ func testActivityCancelledErrorHelper(ctx context.Context, t *testing.T, dataCon
 			return "testResult4", NewCanceledError("testMultipleString", testErrorDetails{T: "testErrorStack4"})
 		}}
 	encResult, e = a4.Execute(ctx, testEncodeFunctionArgs(dataConverter, a2.fn, 1))
-	err = deSerializeFunctionResult(a3.fn, encResult, &r, dataConverter)
+	err = deSerializeFunctionResult(a3.fn, encResult, &r, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, "testResult4", r)
 	require.Error(t, e)
@@ -917,7 +992,7 @@ func testActivityExecutionVariousTypesHelper(ctx context.Context, t *testing.T,
 	encResult, e := a1.Execute(ctx, testEncodeFunctionArgs(dataConverter, a1.fn, "test"))
 	require.NoError(t, e)
 	var r *testWorkflowResult
-	err := deSerializeFunctionResult(a1.fn, encResult, &r, dataConverter)
+	err := deSerializeFunctionResult(a1.fn, encResult, &r, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, 1, r.V)
 
@@ -927,7 +1002,7 @@ func testActivityExecutionVariousTypesHelper(ctx context.Context, t *testing.T,
 		}}
 	encResult, e = a2.Execute(ctx, testEncodeFunctionArgs(dataConverter, a2.fn, r))
 	require.NoError(t, e)
-	err = deSerializeFunctionResult(a2.fn, encResult, &r, dataConverter)
+	err = deSerializeFunctionResult(a2.fn, encResult, &r, dataConverter, getHostEnvironment())
 	require.NoError(t, err)
 	require.Equal(t, 2, r.V)
 }
@@ -949,6 +1024,226 @@ type encodingTest struct {
 	input    []interface{}
 }
 
+type testActivityStruct struct {
+	greeting string
+}
+
+func (a *testActivityStruct) Greet(name string) (string, error) {
+	return a.greeting + " " + name, nil
+}
+
+func (a testActivityStruct) Echo(ctx context.Context, s string) (string, error) {
+	return s, nil
+}
+
+// Helper is not an activity as it doesn't return an error.
+func (a *testActivityStruct) Helper() int {
+	return 0
+}
+
+func TestRegisterActivityStruct(t *testing.T) {
+	env := newHostEnvironment()
+	activities := &testActivityStruct{greeting: "Hello"}
+	require.NoError(t, env.RegisterActivityWithOptions(activities, RegisterActivityOptions{Name: "prefix_"}))
+	// a struct type is registered only once, whatever the prefix or the instance
+	err := env.RegisterActivity(activities)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "activities of *internal.testActivityStruct are already registered")
+	require.Error(t, env.RegisterActivityWithOptions(&testActivityStruct{}, RegisterActivityOptions{Name: "other_"}))
+	require.Error(t, env.RegisterActivity(&struct{}{}))
+
+	var names []string
+	for _, a := range env.getRegisteredActivities() {
+		names = append(names, a.ActivityType().Name)
+	}
+	require.ElementsMatch(t, []string{"prefix_Greet", "prefix_Echo"}, names)
+
+	// method values resolve to the activity types of the struct
+	activityType, input, err := getValidatedActivityFunction(activities.Greet, []interface{}{"Cadence"}, nil, env)
+	require.NoError(t, err)
+	require.Equal(t, "prefix_Greet", activityType.Name)
+	activityType, _, err = getValidatedActivityFunction(activities.Echo, []interface{}{"echo"}, nil, env)
+	require.NoError(t, err)
+	require.Equal(t, "prefix_Echo", activityType.Name)
+
+	a, ok := env.getActivity("prefix_Greet")
+	require.True(t, ok)
+	result, err := a.Execute(context.Background(), input)
+	require.NoError(t, err)
+	var greeting string
+	require.NoError(t, decodeArg(nil, result, &greeting))
+	require.Equal(t, "Hello Cadence", greeting)
+}
+
+func TestDynamicRegistration(t *testing.T) {
+	env := newWorkerHostEnvironment()
+	_, err := env.getWorkflowDefinition(WorkflowType{Name: "unregisteredWorkflow"})
+	require.Error(t, err)
+
+	dynamicWorkflow := func(ctx Context, workflowType string, args encoded.Values) (interface{}, error) {
+		return nil, nil
+	}
+	dynamicActivity := func(ctx context.Context, activityType string, args encoded.Values) (interface{}, error) {
+		var name string
+		if err := args.Get(&name); err != nil {
+			return nil, err
+		}
+		return activityType + " " + name, nil
+	}
+	require.NoError(t, env.RegisterDynamicWorkflow(dynamicWorkflow))
+	require.Error(t, env.RegisterDynamicWorkflow(dynamicWorkflow))
+	require.NoError(t, env.RegisterDynamicActivity(dynamicActivity))
+	require.Error(t, env.RegisterDynamicActivity(dynamicActivity))
+
+	wd, err := env.getWorkflowDefinition(WorkflowType{Name: "unregisteredWorkflow"})
+	require.NoError(t, err)
+	require.NotNil(t, wd)
+
+	ath := &activityTaskHandlerImpl{hostEnv: env}
+	a := ath.getActivity("unregisteredActivity")
+	require.NotNil(t, a)
+	require.Equal(t, "unregisteredActivity", a.ActivityType().Name)
+	input, err := encodeArg(nil, "Cadence")
+	require.NoError(t, err)
+	result, err := a.Execute(context.Background(), input)
+	require.NoError(t, err)
+	var output string
+	require.NoError(t, decodeArg(nil, result, &output))
+	require.Equal(t, "unregisteredActivity Cadence", output)
+
+	// an exact registration takes precedence over the dynamic one
+	_, ok := ath.getActivity("testActivity").(*dynamicActivityExecutor)
+	require.False(t, ok)
+
+	// the test environments run the unregistered types through the dynamic functions
+	var testSuite WorkflowTestSuite
+	testEnv := testSuite.NewTestWorkflowEnvironment()
+	testEnv.RegisterDynamicWorkflow(func(ctx Context, workflowType string, args encoded.Values) (interface{}, error) {
+		var name string
+		var count int
+		if err := args.Get(&name, &count); err != nil {
+			return nil, err
+		}
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var greeting string
+		if err := ExecuteActivity(ctx, "unregisteredActivity", name).Get(ctx, &greeting); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%v: %v x%v", workflowType, greeting, count), nil
+	})
+	testEnv.RegisterDynamicActivity(dynamicActivity)
+	testEnv.ExecuteWorkflow("unregisteredWorkflow", "Cadence", 2)
+	require.True(t, testEnv.IsWorkflowCompleted())
+	require.NoError(t, testEnv.GetWorkflowError())
+	var workflowResult string
+	require.NoError(t, testEnv.GetWorkflowResult(&workflowResult))
+	require.Equal(t, "unregisteredWorkflow: unregisteredActivity Cadence x2", workflowResult)
+
+	activityEnv := testSuite.NewTestActivityEnvironment()
+	activityEnv.RegisterDynamicActivity(dynamicActivity)
+	value, err := activityEnv.ExecuteActivity("otherActivity", "Cadence")
+	require.NoError(t, err)
+	require.NoError(t, value.Get(&output))
+	require.Equal(t, "otherActivity Cadence", output)
+}
+
+type testActivityInterceptor struct {
+	name string
+	args [][]interface{}
+}
+
+func (i *testActivityInterceptor) InterceptActivity(ctx context.Context, args []interface{}, next ActivityHandler) (interface{}, error) {
+	i.args = append(i.args, args)
+	result, err := next(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%v(%v)", i.name, result), nil
+}
+
+func TestActivityInterceptors(t *testing.T) {
+	outer := &testActivityInterceptor{name: "outer"}
+	inner := &testActivityInterceptor{name: "inner"}
+	ctx := context.WithValue(context.Background(), activityEnvContextKey, &activityEnvironment{
+		interceptors: []ActivityInterceptor{outer, inner},
+	})
+	activityFn := func(ctx context.Context, name string, count int) (string, error) {
+		return fmt.Sprintf("%v-%v", name, count), nil
+	}
+	ae := &activityExecutor{name: "testInterceptedActivity", fn: activityFn}
+
+	input, err := encodeArgs(nil, []interface{}{"activity", 1})
+	require.NoError(t, err)
+	result, err := ae.Execute(ctx, input)
+	require.NoError(t, err)
+	var output string
+	require.NoError(t, decodeArg(nil, result, &output))
+	require.Equal(t, "outer(inner(activity-1))", output)
+
+	// local activities are executed with their actual arguments
+	result, err = ae.ExecuteWithActualArgs(ctx, []interface{}{"local", 2})
+	require.NoError(t, err)
+	require.NoError(t, decodeArg(nil, result, &output))
+	require.Equal(t, "outer(inner(local-2))", output)
+
+	require.Equal(t, [][]interface{}{{"activity", 1}, {"local", 2}}, outer.args)
+	require.Equal(t, outer.args, inner.args)
+}
+
+func TestActivityInterceptors_TestEnvironment(t *testing.T) {
+	activityFn := func(ctx context.Context, name string, count int) (string, error) {
+		return fmt.Sprintf("%v-%v", name, count), nil
+	}
+	workflowFn := func(ctx Context) ([]string, error) {
+		ctx = WithActivityOptions(ctx, ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		})
+		var result, localResult string
+		if err := ExecuteActivity(ctx, "testInterceptedActivity", "activity", 1).Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		ctx = WithLocalActivityOptions(ctx, LocalActivityOptions{ScheduleToCloseTimeout: time.Minute})
+		if err := ExecuteLocalActivity(ctx, activityFn, "local", 2).Get(ctx, &localResult); err != nil {
+			return nil, err
+		}
+		return []string{result, localResult}, nil
+	}
+
+	var testSuite WorkflowTestSuite
+	outer := &testActivityInterceptor{name: "outer"}
+	inner := &testActivityInterceptor{name: "inner"}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{ActivityInterceptors: []ActivityInterceptor{outer, inner}})
+	env.RegisterWorkflow(workflowFn)
+	env.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "testInterceptedActivity"})
+	env.ExecuteWorkflow(workflowFn)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var results []string
+	require.NoError(t, env.GetWorkflowResult(&results))
+	require.Equal(t, []string{"outer(inner(activity-1))", "outer(inner(local-2))"}, results)
+	require.Equal(t, [][]interface{}{{"activity", 1}, {"local", 2}}, outer.args)
+	require.Equal(t, outer.args, inner.args)
+
+	interceptor := &testActivityInterceptor{name: "interceptor"}
+	activityEnv := testSuite.NewTestActivityEnvironment()
+	activityEnv.SetWorkerOptions(WorkerOptions{ActivityInterceptors: []ActivityInterceptor{interceptor}})
+	activityEnv.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "testInterceptedActivity"})
+	value, err := activityEnv.ExecuteActivity("testInterceptedActivity", "activity", 3)
+	require.NoError(t, err)
+	var output string
+	require.NoError(t, value.Get(&output))
+	require.Equal(t, "interceptor(activity-3)", output)
+	value, err = activityEnv.ExecuteLocalActivity(activityFn, "local", 4)
+	require.NoError(t, err)
+	require.NoError(t, value.Get(&output))
+	require.Equal(t, "interceptor(local-4)", output)
+}
+
 func TestActivityNilArgs(t *testing.T) {
 	nilErr := errors.New("nils")
 	activityFn := func(name string, idx int, strptr *string) error {
@@ -959,7 +1254,7 @@ func TestActivityNilArgs(t *testing.T) {
 	}
 
 	args := []interface{}{nil, nil, nil}
-	_, input, err := getValidatedActivityFunction(activityFn, args, nil)
+	_, input, err := getValidatedActivityFunction(activityFn, args, nil, getHostEnvironment())
 	require.NoError(t, err)
 
 	reflectArgs, err := decodeArgs(nil, reflect.TypeOf(activityFn), input)
@@ -979,7 +1274,7 @@ func TestActivityNilArgs_WithDataConverter(t *testing.T) {
 	}
 
 	args := []interface{}{nil, nil, nil}
-	_, _, err := getValidatedActivityFunction(activityFn, args, newTestDataConverter())
+	_, _, err := getValidatedActivityFunction(activityFn, args, newTestDataConverter(), getHostEnvironment())
 	require.Error(t, err) // testDataConverter cannot encode nil value
 }
 
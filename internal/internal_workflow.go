@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -131,6 +132,40 @@ type (
 		defaultFunc *func()       // default case
 	}
 
+	// Implements WaitGroup interface
+	waitGroupImpl struct {
+		name    string
+		counter int
+	}
+
+	// Implements Semaphore interface, the permits are handed over to the waiters in FIFO order.
+	semaphoreImpl struct {
+		name     string
+		size     int                // number of permits
+		acquired int                // number of acquired permits
+		waiters  []*semaphoreWaiter // waiting for a permit, in the order they started waiting
+	}
+
+	semaphoreWaiter struct {
+		acquired bool // set when a permit is handed over to the waiter
+	}
+
+	// Implements Mutex interface with a semaphore of one permit
+	mutexImpl struct {
+		semaphore semaphoreImpl
+	}
+
+	// Implements Group interface
+	groupImpl struct {
+		name    string
+		ctx     Context
+		cancel  CancelFunc
+		running WaitGroup
+		limit   Semaphore // nil when there is no limit
+		count   int       // number of functions started and not returned yet
+		err     error     // first error returned by a function
+	}
+
 	// unblockFunc is passed evaluated by a coroutine yield. When it returns false the yield returns to a caller.
 	// stackDepth is the depth of stack from the last blocking call relevant to user.
 	// Used to truncate internal stack frames from thread stack.
@@ -151,6 +186,7 @@ type (
 		sequence         int
 		channelSequence  int // used to name channels
 		selectorSequence int // used to name channels
+		syncSequence     int // used to name wait groups, mutexes and semaphores
 		coroutines       []*coroutineState
 		executing        bool       // currently running ExecuteUntilAllBlocked. Used to avoid recursive calls to it.
 		mutex            sync.Mutex // used to synchronize executing
@@ -168,6 +204,7 @@ type (
 		childPolicy                         ChildWorkflowPolicy
 		waitForCancellation                 bool
 		signalChannels                      map[string]Channel
+		signalHandlers                      map[string]bool // names of the signals that have a handler
 		queryHandlers                       map[string]func([]byte) ([]byte, error)
 		workflowIDReusePolicy               WorkflowIDReusePolicy
 		dataConverter                       encoded.DataConverter
@@ -220,6 +257,12 @@ type (
 		queryType     string
 		dataConverter encoded.DataConverter
 	}
+
+	signalHandler struct {
+		fn            interface{}
+		signalName    string
+		dataConverter encoded.DataConverter
+	}
 )
 
 const (
@@ -227,6 +270,7 @@ const (
 	workflowResultContextKey      = "workflowResult"
 	coroutinesContextKey          = "coroutines"
 	workflowEnvOptionsContextKey  = "wfEnvOptions"
+	workflowOperationsContextKey  = "workflowOperations"
 )
 
 // Assert that structs do indeed implement the interfaces
@@ -364,6 +408,71 @@ func (f *futureImpl) GetValueAndError() (interface{}, error) {
 	return f.value, f.err
 }
 
+func (g *groupImpl) Go(f func(ctx Context) error) {
+	g.count++
+	g.running.Add(1)
+	limit := g.limit
+	Go(g.ctx, func(ctx Context) {
+		defer func() {
+			g.count--
+			g.running.Done()
+		}()
+		if limit != nil {
+			if err := limit.Acquire(ctx); err != nil {
+				g.fail(err)
+				return
+			}
+			defer limit.Release()
+		}
+		if err := f(ctx); err != nil {
+			g.fail(err)
+		}
+	})
+}
+
+func (g *groupImpl) SetLimit(n int) {
+	if g.count > 0 {
+		panic(fmt.Sprintf("cannot change the limit of %s while %v functions are running", g.name, g.count))
+	}
+	g.limit = nil
+	if n > 0 {
+		g.limit = NewNamedSemaphore(g.ctx, g.name, n)
+	}
+}
+
+func (g *groupImpl) Wait(ctx Context) error {
+	if err := g.running.Wait(ctx); err != nil {
+		return err
+	}
+	g.cancel()
+	return g.err
+}
+
+// fail records the first error and cancels the context of the group.
+func (g *groupImpl) fail(err error) {
+	if g.err == nil {
+		g.err = err
+		g.cancel()
+	}
+}
+
+// onReady calls fn with the value and the error of future once it is ready, right away if it already is.
+func onReady(future Future, fn func(v interface{}, err error)) {
+	f, ok := future.(asyncFuture)
+	if !ok {
+		panic("cannot wait for Future that wasn't created with workflow.NewFuture")
+	}
+	callback := &receiveCallback{
+		fn: func(v interface{}, more bool) bool {
+			fn(f.GetValueAndError())
+			return true
+		},
+	}
+	if v, ready, err := f.GetAsync(callback); ready {
+		fn(v, err)
+	}
+}
+
 func (f *childWorkflowFutureImpl) GetChildWorkflowExecution() Future {
 	return f.executionFuture
 }
@@ -397,8 +506,63 @@ func newWorkflowContext(env workflowEnvironment) Context {
 	return rootCtx
 }
 
+// workflowOperationsImpl is the innermost WorkflowOperations of the interceptor chain, it performs the calls.
+type workflowOperationsImpl struct {
+	workflow workflow
+}
+
+func newWorkflowOperations(env workflowEnvironment, workflow workflow) WorkflowOperations {
+	var ops WorkflowOperations = &workflowOperationsImpl{workflow: workflow}
+	interceptors := env.GetWorkflowInterceptors()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ops = interceptors[i].InterceptWorkflow(env.WorkflowInfo(), ops)
+	}
+	return ops
+}
+
+func getWorkflowOperations(ctx Context) WorkflowOperations {
+	if ops, ok := ctx.Value(workflowOperationsContextKey).(WorkflowOperations); ok {
+		return ops
+	}
+	return &workflowOperationsImpl{}
+}
+
+func (o *workflowOperationsImpl) ExecuteWorkflow(ctx Context, workflowType string, input []byte) ([]byte, error) {
+	return o.workflow.Execute(ctx, input)
+}
+
+func (o *workflowOperationsImpl) ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	return executeActivity(ctx, activity, args...)
+}
+
+func (o *workflowOperationsImpl) ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	return executeLocalActivity(ctx, activity, args...)
+}
+
+func (o *workflowOperationsImpl) ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interface{}) ChildWorkflowFuture {
+	return executeChildWorkflow(ctx, childWorkflow, args...)
+}
+
+func (o *workflowOperationsImpl) NewTimer(ctx Context, d time.Duration) Future {
+	return newTimer(ctx, d)
+}
+
+func (o *workflowOperationsImpl) SideEffect(ctx Context, f func(ctx Context) interface{}) encoded.Value {
+	return sideEffect(ctx, f)
+}
+
+func (o *workflowOperationsImpl) SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future {
+	childWorkflowOnly := false // this means we are not limited to child workflow
+	return signalExternalWorkflow(ctx, workflowID, runID, signalName, arg, childWorkflowOnly)
+}
+
+func (o *workflowOperationsImpl) GetVersion(ctx Context, changeID string, minSupported, maxSupported Version) Version {
+	return getWorkflowEnvironment(ctx).GetVersion(changeID, minSupported, maxSupported)
+}
+
 func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, input []byte) {
-	dispatcher, rootCtx := newDispatcher(newWorkflowContext(env), func(ctx Context) {
+	workflowCtx := WithValue(newWorkflowContext(env), workflowOperationsContextKey, newWorkflowOperations(env, d.workflow))
+	dispatcher, rootCtx := newDispatcher(workflowCtx, func(ctx Context) {
 		r := &workflowResult{}
 
 		// We want to execute the user workflow definition from the first decision task started,
@@ -407,7 +571,20 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, input []byte)
 		state := getState(d.rootCtx)
 		state.yield("yield before executing to setup state")
 
-		r.workflowResult, r.error = d.workflow.Execute(d.rootCtx, input)
+		workflowType := env.WorkflowInfo().WorkflowType.Name
+		workflowCtx, payload, err := unwrapWorkflowContextEnvelope(d.rootCtx, input)
+		if err != nil {
+			r.error = err
+		} else {
+			r.workflowResult, r.error = getWorkflowOperations(workflowCtx).ExecuteWorkflow(workflowCtx, workflowType, payload)
+		}
+		if contErr, ok := r.error.(*ContinueAsNewError); ok && contErr.carrySignals {
+			// the signals are drained only once the workflow returned, the workflow keeps the signals of a
+			// ContinueAsNewError it doesn't return.
+			if err := carryUnhandledSignals(d.rootCtx, contErr); err != nil {
+				r.workflowResult, r.error = nil, err
+			}
+		}
 		rpp := getWorkflowResultPointerPointer(ctx)
 		*rpp = r
 	})
@@ -420,6 +597,15 @@ func (d *syncWorkflowDefinition) Execute(env workflowEnvironment, input []byte)
 		d.cancel()
 	})
 
+	// The signals carried over by ContinueAsNewWithSignals are received before the ones sent to this run. Decoding errors
+	// are returned by the root coroutine.
+	if envelope, err := decodeContextEnvelope(input); err == nil {
+		eo := getWorkflowEnvOptions(d.rootCtx)
+		for _, s := range envelope.Signals {
+			eo.getSignalChannel(d.rootCtx, s.Name).(*channelImpl).SendAsync(s.Input)
+		}
+	}
+
 	getWorkflowEnvironment(d.rootCtx).RegisterSignalHandler(func(name string, result []byte) {
 		eo := getWorkflowEnvOptions(d.rootCtx)
 		// We don't want this code to be blocked ever, using sendAsync().
@@ -1049,12 +1235,106 @@ func (s *selectorImpl) Select(ctx Context) {
 	}
 }
 
+func (wg *waitGroupImpl) Add(delta int) {
+	wg.counter += delta
+	if wg.counter < 0 {
+		panic(fmt.Sprintf("negative counter of %s", wg.name))
+	}
+}
+
+func (wg *waitGroupImpl) Done() {
+	wg.Add(-1)
+}
+
+func (wg *waitGroupImpl) Wait(ctx Context) error {
+	state := getState(ctx)
+	defer state.unblocked()
+	for wg.counter > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		state.yield(fmt.Sprintf("blocked on %s.Wait", wg.name))
+	}
+	return nil
+}
+
+func (s *semaphoreImpl) Acquire(ctx Context) error {
+	return s.acquire(ctx, "Acquire")
+}
+
+func (s *semaphoreImpl) TryAcquire() bool {
+	if s.acquired == s.size || len(s.waiters) > 0 {
+		return false
+	}
+	s.acquired++
+	return true
+}
+
+func (s *semaphoreImpl) Release() {
+	s.release("semaphore " + s.name + " released more than acquired")
+}
+
+// acquire waits for a permit in the queue of waiters, operation is only used in stack traces.
+func (s *semaphoreImpl) acquire(ctx Context, operation string) error {
+	if s.TryAcquire() {
+		return nil
+	}
+	state := getState(ctx)
+	defer state.unblocked()
+	w := &semaphoreWaiter{}
+	s.waiters = append(s.waiters, w)
+	for !w.acquired {
+		if err := ctx.Err(); err != nil {
+			s.removeWaiter(w)
+			return err
+		}
+		state.yield(fmt.Sprintf("blocked on %s.%s", s.name, operation))
+	}
+	return nil
+}
+
+// release hands the permit over to the first waiter if any, it panics with message if no permit is acquired.
+func (s *semaphoreImpl) release(message string) {
+	if s.acquired == 0 {
+		panic(message)
+	}
+	if len(s.waiters) == 0 {
+		s.acquired--
+		return
+	}
+	w := s.waiters[0]
+	s.waiters[0] = nil
+	s.waiters = s.waiters[1:]
+	w.acquired = true
+}
+
+func (s *semaphoreImpl) removeWaiter(w *semaphoreWaiter) {
+	for i, waiter := range s.waiters {
+		if waiter == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *mutexImpl) Lock(ctx Context) error {
+	return m.semaphore.acquire(ctx, "Lock")
+}
+
+func (m *mutexImpl) TryLock() bool {
+	return m.semaphore.TryAcquire()
+}
+
+func (m *mutexImpl) Unlock() {
+	m.semaphore.release("unlock of unlocked mutex " + m.semaphore.name)
+}
+
 // NewWorkflowDefinition creates a WorkflowDefinition from a Workflow
 func newWorkflowDefinition(workflow workflow) workflowDefinition {
 	return &syncWorkflowDefinition{workflow: workflow}
 }
 
-func getValidatedWorkflowFunction(workflowFunc interface{}, args []interface{}, dataConverter encoded.DataConverter) (*WorkflowType, []byte, error) {
+func getValidatedWorkflowFunction(workflowFunc interface{}, args []interface{}, dataConverter encoded.DataConverter, registry *hostEnvImpl) (*WorkflowType, []byte, error) {
 	fnName := ""
 	fType := reflect.TypeOf(workflowFunc)
 	switch getKind(fType) {
@@ -1066,7 +1346,7 @@ func getValidatedWorkflowFunction(workflowFunc interface{}, args []interface{},
 			return nil, nil, err
 		}
 		fnName = getFunctionName(workflowFunc)
-		if alias, ok := getHostEnvironment().getWorkflowAlias(fnName); ok {
+		if alias, ok := registry.getWorkflowAlias(fnName); ok {
 			fnName = alias
 		}
 
@@ -1144,6 +1424,7 @@ func setWorkflowEnvOptionsIfNotExist(ctx Context) Context {
 		newOptions = *options
 	} else {
 		newOptions.signalChannels = make(map[string]Channel)
+		newOptions.signalHandlers = make(map[string]bool)
 		newOptions.queryHandlers = make(map[string]func([]byte) ([]byte, error))
 	}
 	if newOptions.dataConverter == nil {
@@ -1184,6 +1465,50 @@ func (w *workflowOptions) getUnhandledSignals() []string {
 	return unhandledSignals
 }
 
+// drainUnhandledSignals removes the signals that have data to be consumed from their channels, and returns them
+// sorted by name and in the order they were received.
+func (w *workflowOptions) drainUnhandledSignals() ([]carriedSignal, error) {
+	names := make([]string, 0, len(w.signalChannels))
+	for k := range w.signalChannels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var signals []carriedSignal
+	for _, name := range names {
+		ch := w.signalChannels[name].(*channelImpl)
+		for {
+			v, ok, _ := ch.receiveAsyncImpl(nil)
+			if !ok {
+				break
+			}
+			input, isBytes := v.([]byte)
+			if !isBytes {
+				var err error
+				if input, err = encodeArg(w.dataConverter, v); err != nil {
+					return nil, err
+				}
+			}
+			signals = append(signals, carriedSignal{Name: name, Input: input})
+		}
+	}
+	return signals, nil
+}
+
+// carryUnhandledSignals adds the signals the workflow has not handled to the input of the next run of err.
+func carryUnhandledSignals(ctx Context, contErr *ContinueAsNewError) error {
+	signals, err := getWorkflowEnvOptions(ctx).drainUnhandledSignals()
+	if err != nil || len(signals) == 0 {
+		return err
+	}
+	input, err := wrapContextEnvelopeSignals(contErr.params.input, signals)
+	if err != nil {
+		return err
+	}
+	contErr.params.input = input
+	return nil
+}
+
 func (d *decodeFutureImpl) Get(ctx Context, value interface{}) error {
 	more := d.futureImpl.channel.Receive(ctx, nil)
 	if more {
@@ -1200,7 +1525,7 @@ func (d *decodeFutureImpl) Get(ctx Context, value interface{}) error {
 		return errors.New("value parameter is not a pointer")
 	}
 
-	err := deSerializeFunctionResult(d.fn, d.futureImpl.value.([]byte), value, getDataConverterFromWorkflowContext(ctx))
+	err := deSerializeFunctionResult(d.fn, d.futureImpl.value.([]byte), value, getDataConverterFromWorkflowContext(ctx), getWorkflowEnvironment(ctx).GetRegistry())
 	if err != nil {
 		return err
 	}
@@ -1267,6 +1592,70 @@ func (h *queryHandler) validateHandlerFn() error {
 	return nil
 }
 
+func setSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	sh := &signalHandler{fn: handler, signalName: signalName, dataConverter: getDataConverterFromWorkflowContext(ctx)}
+	if err := sh.validateHandlerFn(); err != nil {
+		return err
+	}
+	eo := getWorkflowEnvOptions(ctx)
+	if eo.signalHandlers[signalName] {
+		return fmt.Errorf("signal handler already set for signal: %v", signalName)
+	}
+	eo.signalHandlers[signalName] = true
+
+	ch := eo.getSignalChannel(ctx, signalName).(*channelImpl)
+	GoNamed(ctx, "signal-handler-"+signalName, func(ctx Context) {
+		state := getState(ctx)
+		for {
+			// the raw input is received, it is decoded for the arguments of the handler
+			v, ok, _ := ch.receiveAsyncImpl(nil)
+			if !ok {
+				state.yield(fmt.Sprintf("blocked on %s signal handler", signalName))
+				continue
+			}
+			state.unblocked()
+			input, _ := v.([]byte)
+			sh.execute(ctx, input)
+		}
+	})
+	return nil
+}
+
+func (h *signalHandler) validateHandlerFn() error {
+	fnType := reflect.TypeOf(h.fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return fmt.Errorf("signal handler must be function but was %v", fnType)
+	}
+	if fnType.NumIn() < 1 || !isWorkflowContext(fnType.In(0)) {
+		return fmt.Errorf("first parameter of signal handler must be workflow.Context")
+	}
+	if fnType.NumOut() != 0 {
+		return fmt.Errorf("signal handler must not return values, but found %d return values", fnType.NumOut())
+	}
+	return nil
+}
+
+// execute decodes the input of the signal and calls the handler with it. A signal that can't be decoded is dropped,
+// like a corrupt signal received from a signal channel.
+func (h *signalHandler) execute(ctx Context, input []byte) {
+	fnType := reflect.TypeOf(h.fn)
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+
+	if fnType.NumIn() == 2 && isTypeByteSlice(fnType.In(1)) {
+		args = append(args, reflect.ValueOf(input))
+	} else {
+		decoded, err := decodeArgs(h.dataConverter, fnType, input)
+		if err != nil {
+			env := getWorkflowEnvironment(ctx)
+			env.GetLogger().Error(fmt.Sprintf("Corrupt signal %s received. Error deserializing", h.signalName), zap.Error(err))
+			env.GetMetricsScope().Counter(metrics.CorruptedSignalsCounter).Inc(1)
+			return
+		}
+		args = append(args, decoded...)
+	}
+	reflect.ValueOf(h.fn).Call(args)
+}
+
 func (h *queryHandler) execute(input []byte) (result []byte, err error) {
 	// if query handler panic, convert it to error
 	defer func() {
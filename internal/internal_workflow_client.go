@@ -45,16 +45,24 @@ var _ DomainClient = (*domainClient)(nil)
 const (
 	defaultDecisionTaskTimeoutInSecs = 10
 	defaultGetHistoryTimeoutInSecs   = 25
+
+	// queryFromHistoryTaskList is the task list of the decision task that replays a workflow queried from history.
+	queryFromHistoryTaskList = "QueryTaskList"
 )
 
 type (
 	// workflowClient is the client for starting a workflow execution.
 	workflowClient struct {
-		workflowService workflowserviceclient.Interface
-		domain          string
-		metricsScope    *metrics.TaggedScope
-		identity        string
-		dataConverter   encoded.DataConverter
+		workflowService    workflowserviceclient.Interface
+		domain             string
+		metricsScope       *metrics.TaggedScope
+		identity           string
+		dataConverter      encoded.DataConverter
+		contextPropagators []ContextPropagator
+		registry           *hostEnvImpl
+		// interceptedClient is the Client wrapped by ClientOptions.Interceptors, through which the methods calling
+		// other methods of the Client make these calls.
+		interceptedClient Client
 	}
 
 	// domainClient is the client for managing domains.
@@ -94,6 +102,7 @@ type (
 		currentRunID  string
 		iterFn        func(ctx context.Context, runID string) HistoryEventIterator
 		dataConverter encoded.DataConverter
+		registry      *hostEnvImpl
 	}
 
 	// HistoryEventIterator represents the interface for
@@ -123,6 +132,62 @@ type (
 		// func which use a next token to get next page of history events
 		paginate func(nexttoken []byte) (*s.GetWorkflowExecutionHistoryResponse, error)
 	}
+
+	// WorkflowExecutionIterator represents the interface for
+	// workflow execution iterator
+	WorkflowExecutionIterator interface {
+		// HasNext return whether this iterator has next value
+		HasNext() bool
+		// Next returns the next workflow execution and error
+		// The errors it can return:
+		//	- EntityNotExistsError
+		//	- BadRequestError
+		//	- InternalServiceError
+		Next() (*s.WorkflowExecutionInfo, error)
+	}
+
+	// ListWorkflowFilter is the filter of the workflow executions returned by a WorkflowExecutionIterator.
+	// Cadence server accepts only one of WorkflowType, WorkflowID and CloseStatus per listing.
+	ListWorkflowFilter struct {
+		// WorkflowType - Only return executions of this workflow type.
+		// Optional: default to all workflow types.
+		WorkflowType string
+
+		// WorkflowID - Only return executions with this workflow ID.
+		// Optional: default to all workflow IDs.
+		WorkflowID string
+
+		// CloseStatus - Only return executions closed with this status. Only valid for closed executions.
+		// Optional: default to all close statuses.
+		CloseStatus *s.WorkflowExecutionCloseStatus
+
+		// EarliestStartTime - Only return executions started at or after this time.
+		// Optional: default to no lower bound.
+		EarliestStartTime time.Time
+
+		// LatestStartTime - Only return executions started at or before this time.
+		// Optional: default to the time the iterator is created.
+		LatestStartTime time.Time
+
+		// PageSize - The maximum number of executions fetched from server per call.
+		// Optional: default to server side default.
+		PageSize int32
+	}
+
+	// workflowExecutionIteratorImpl is the implementation of WorkflowExecutionIterator
+	workflowExecutionIteratorImpl struct {
+		// whether this iterator is initialized
+		initialized bool
+		// local cached workflow executions and corresponding comsuming index
+		nextExecutionIndex int
+		executions         []*s.WorkflowExecutionInfo
+		// token to get next page of workflow executions
+		nexttoken []byte
+		// err when getting next page of workflow executions
+		err error
+		// func which use a next token to get next page of workflow executions
+		paginate func(nexttoken []byte) ([]*s.WorkflowExecutionInfo, []byte, error)
+	}
 )
 
 // StartWorkflow starts a workflow execution
@@ -162,10 +227,13 @@ func (wc *workflowClient) StartWorkflow(
 	}
 
 	// Validate type and its arguments.
-	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, args, wc.dataConverter)
+	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, args, wc.dataConverter, wc.registry)
 	if err != nil {
 		return nil, err
 	}
+	if input, err = wc.wrapContextEnvelope(ctx, input); err != nil {
+		return nil, err
+	}
 
 	startRequest := &s.StartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
@@ -225,7 +293,7 @@ func (wc *workflowClient) ExecuteWorkflow(ctx context.Context, options StartWork
 	// start the workflow execution
 	var runID string
 	var workflowID string
-	executionInfo, err := wc.StartWorkflow(ctx, options, workflow, args...)
+	executionInfo, err := wc.intercepted().StartWorkflow(ctx, options, workflow, args...)
 	if err != nil {
 		if alreadyStartedErr, ok := err.(*s.WorkflowExecutionAlreadyStartedError); ok {
 			runID = alreadyStartedErr.GetRunId()
@@ -241,7 +309,7 @@ func (wc *workflowClient) ExecuteWorkflow(ctx context.Context, options StartWork
 	}
 
 	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
-		return wc.GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, s.HistoryEventFilterTypeCloseEvent)
+		return wc.intercepted().GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, s.HistoryEventFilterTypeCloseEvent)
 	}
 
 	return &workflowRunImpl{
@@ -251,9 +319,29 @@ func (wc *workflowClient) ExecuteWorkflow(ctx context.Context, options StartWork
 		currentRunID:  runID,
 		iterFn:        iterFn,
 		dataConverter: wc.dataConverter,
+		registry:      wc.registry,
 	}, nil
 }
 
+// GetWorkflow returns a WorkflowRun attached to an existing workflow execution, the last run of workflowID if runID
+// is empty. WorkflowRun.Get waits for the run to close, and follows the runs started by ContinueAsNewError until one
+// closes otherwise. No request is made before WorkflowRun.Get is called, ctx isn't used and is accepted for
+// consistency with the other Client methods.
+func (wc *workflowClient) GetWorkflow(ctx context.Context, workflowID string, runID string) WorkflowRun {
+	iterFn := func(fnCtx context.Context, fnRunID string) HistoryEventIterator {
+		return wc.intercepted().GetWorkflowHistory(fnCtx, workflowID, fnRunID, true, s.HistoryEventFilterTypeCloseEvent)
+	}
+
+	return &workflowRunImpl{
+		workflowID:    workflowID,
+		firstRunID:    runID,
+		currentRunID:  runID,
+		iterFn:        iterFn,
+		dataConverter: wc.dataConverter,
+		registry:      wc.registry,
+	}
+}
+
 // SignalWorkflow signals a workflow in execution.
 func (wc *workflowClient) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
 	input, err := encodeArg(wc.dataConverter, arg)
@@ -312,10 +400,13 @@ func (wc *workflowClient) SignalWithStartWorkflow(ctx context.Context, workflowI
 	}
 
 	// Validate type and its arguments.
-	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, workflowArgs, wc.dataConverter)
+	workflowType, input, err := getValidatedWorkflowFunction(workflowFunc, workflowArgs, wc.dataConverter, wc.registry)
 	if err != nil {
 		return nil, err
 	}
+	if input, err = wc.wrapContextEnvelope(ctx, input); err != nil {
+		return nil, err
+	}
 
 	signalWithStartRequest := &s.SignalWithStartWorkflowExecutionRequest{
 		Domain:                              common.StringPtr(wc.domain),
@@ -644,6 +735,86 @@ func (wc *workflowClient) QueryWorkflow(ctx context.Context, workflowID string,
 	return newEncodedValue(resp.QueryResult, wc.dataConverter), nil
 }
 
+// QueryWorkflowFromHistory queries a given workflow execution by replaying its full history locally with the
+// registered workflow definition and running the query handler against the final state. The workflow definition is
+// resolved through the registrations of ClientOptions.Worker, if any, and the global ones.
+// - workflowID is required.
+// - runID can be default(empty string). if empty string then it will pick the last execution of that workflow ID.
+// - queryType is the type of the query.
+// - args... are the optional query parameters.
+// The errors it can return:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+//  - QueryFailError
+func (wc *workflowClient) QueryWorkflowFromHistory(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error) {
+	var input []byte
+	if len(args) > 0 {
+		var err error
+		if input, err = encodeArgs(wc.dataConverter, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if runID == "" {
+		// resolve the last run, so that the replayed workflow sees the same execution info as the original one
+		response, err := wc.intercepted().DescribeWorkflowExecution(ctx, workflowID, "")
+		if err != nil {
+			return nil, err
+		}
+		runID = response.WorkflowExecutionInfo.Execution.GetRunId()
+	}
+
+	history := &s.History{}
+	err := iterateWorkflowHistory(ctx, wc.intercepted(), workflowID, runID, func(event *s.HistoryEvent) bool {
+		history.Events = append(history.Events, event)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	execution := &s.WorkflowExecution{
+		WorkflowId: common.StringPtr(workflowID),
+		RunId:      common.StringPtr(runID),
+	}
+	params := workerExecutionParameters{
+		TaskList:           queryFromHistoryTaskList,
+		Identity:           wc.identity,
+		DataConverter:      wc.dataConverter,
+		ContextPropagators: wc.contextPropagators,
+	}
+	query := &s.WorkflowQuery{
+		QueryType: common.StringPtr(queryType),
+		QueryArgs: input,
+	}
+	result, err := queryWorkflowHistory(wc.workflowService, wc.domain, params, execution, history, query, wc.registry)
+	if err != nil {
+		return nil, err
+	}
+	return newEncodedValue(result, wc.dataConverter), nil
+}
+
+// intercepted returns the Client wrapped by the interceptors, or wc if it was not created by NewClient.
+func (wc *workflowClient) intercepted() Client {
+	if wc.interceptedClient == nil {
+		return wc
+	}
+	return wc.interceptedClient
+}
+
+// wrapContextEnvelope returns the workflow input with the values of ctx propagated by the context propagators.
+func (wc *workflowClient) wrapContextEnvelope(ctx context.Context, input []byte) ([]byte, error) {
+	if len(wc.contextPropagators) == 0 {
+		return input, nil
+	}
+	header, err := injectContext(ctx, wc.contextPropagators)
+	if err != nil {
+		return nil, err
+	}
+	return wrapContextEnvelope(header, input)
+}
+
 // DescribeTaskList returns information about the target tasklist, right now this API returns the
 // pollers which polled this tasklist in last few minutes.
 // - tasklist name of tasklist
@@ -675,6 +846,38 @@ func (wc *workflowClient) DescribeTaskList(ctx context.Context, tasklist string,
 	return resp, nil
 }
 
+// ResetWorkflow resets a workflow execution to the point right after the given decision finish event.
+// The errors it can return:
+//  - BadRequestError
+//  - InternalServiceError
+//  - EntityNotExistError
+func (wc *workflowClient) ResetWorkflow(ctx context.Context, request *s.ResetWorkflowExecutionRequest) (*s.ResetWorkflowExecutionResponse, error) {
+	// the defaults are set on a copy, a request reused by the caller gets a new request ID
+	r := *request
+	request = &r
+	if len(request.GetDomain()) == 0 {
+		request.Domain = common.StringPtr(wc.domain)
+	}
+	if len(request.GetRequestId()) == 0 {
+		// set once so that retries are deduped by server
+		request.RequestId = common.StringPtr(uuid.New())
+	}
+
+	var response *s.ResetWorkflowExecutionResponse
+	err := backoff.Retry(ctx,
+		func() error {
+			tchCtx, cancel, opt := newChannelContext(ctx)
+			defer cancel()
+			var err error
+			response, err = wc.workflowService.ResetWorkflowExecution(tchCtx, request, opt...)
+			return err
+		}, createDynamicServiceRetryPolicy(ctx), isServiceTransientError)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 // Register a domain with cadence server
 // The errors it can throw:
 //	- DomainAlreadyExistsError
@@ -740,6 +943,21 @@ func getRunID(runID string) *string {
 	return common.StringPtr(runID)
 }
 
+// iterateWorkflowHistory calls fn on each history event of the given workflow execution until fn returns false.
+func iterateWorkflowHistory(ctx context.Context, c Client, workflowID, runID string, fn func(event *s.HistoryEvent) bool) error {
+	iter := c.GetWorkflowHistory(ctx, workflowID, runID, false, s.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if !fn(event) {
+			return nil
+		}
+	}
+	return nil
+}
+
 func (iter *historyEventIteratorImpl) HasNext() bool {
 	if iter.nextEventIndex < len(iter.events) || iter.err != nil {
 		return true
@@ -788,6 +1006,143 @@ func (iter *historyEventIteratorImpl) Next() (*s.HistoryEvent, error) {
 	panic("HistoryEventIterator Next() should return either a history event or a err")
 }
 
+func newOpenWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	// The default latest start time is the time the iterator is created, for every page.
+	startTimeFilter := filter.toStartTimeFilter()
+	return &workflowExecutionIteratorImpl{
+		paginate: func(nexttoken []byte) ([]*s.WorkflowExecutionInfo, []byte, error) {
+			if filter.CloseStatus != nil {
+				return nil, nil, errors.New("CloseStatus filter is only valid for closed workflow executions")
+			}
+			request := &s.ListOpenWorkflowExecutionsRequest{
+				NextPageToken:   nexttoken,
+				StartTimeFilter: startTimeFilter,
+			}
+			if filter.PageSize > 0 {
+				request.MaximumPageSize = common.Int32Ptr(filter.PageSize)
+			}
+			var err error
+			request.ExecutionFilter, request.TypeFilter, _, err = filter.toThriftFilters()
+			if err != nil {
+				return nil, nil, err
+			}
+			response, err := c.ListOpenWorkflow(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Executions, response.NextPageToken, nil
+		},
+	}
+}
+
+func newClosedWorkflowExecutionIterator(ctx context.Context, c Client, filter ListWorkflowFilter) WorkflowExecutionIterator {
+	// The default latest start time is the time the iterator is created, for every page.
+	startTimeFilter := filter.toStartTimeFilter()
+	return &workflowExecutionIteratorImpl{
+		paginate: func(nexttoken []byte) ([]*s.WorkflowExecutionInfo, []byte, error) {
+			request := &s.ListClosedWorkflowExecutionsRequest{
+				NextPageToken:   nexttoken,
+				StartTimeFilter: startTimeFilter,
+			}
+			if filter.PageSize > 0 {
+				request.MaximumPageSize = common.Int32Ptr(filter.PageSize)
+			}
+			var err error
+			request.ExecutionFilter, request.TypeFilter, request.StatusFilter, err = filter.toThriftFilters()
+			if err != nil {
+				return nil, nil, err
+			}
+			response, err := c.ListClosedWorkflow(ctx, request)
+			if err != nil {
+				return nil, nil, err
+			}
+			return response.Executions, response.NextPageToken, nil
+		},
+	}
+}
+
+func (f ListWorkflowFilter) toStartTimeFilter() *s.StartTimeFilter {
+	filter := &s.StartTimeFilter{
+		EarliestTime: common.Int64Ptr(0),
+		LatestTime:   common.Int64Ptr(time.Now().UnixNano()),
+	}
+	if !f.EarliestStartTime.IsZero() {
+		filter.EarliestTime = common.Int64Ptr(f.EarliestStartTime.UnixNano())
+	}
+	if !f.LatestStartTime.IsZero() {
+		filter.LatestTime = common.Int64Ptr(f.LatestStartTime.UnixNano())
+	}
+	return filter
+}
+
+func (f ListWorkflowFilter) toThriftFilters() (*s.WorkflowExecutionFilter, *s.WorkflowTypeFilter, *s.WorkflowExecutionCloseStatus, error) {
+	var executionFilter *s.WorkflowExecutionFilter
+	var typeFilter *s.WorkflowTypeFilter
+	count := 0
+	if f.WorkflowID != "" {
+		executionFilter = &s.WorkflowExecutionFilter{WorkflowId: common.StringPtr(f.WorkflowID)}
+		count++
+	}
+	if f.WorkflowType != "" {
+		typeFilter = &s.WorkflowTypeFilter{Name: common.StringPtr(f.WorkflowType)}
+		count++
+	}
+	if f.CloseStatus != nil {
+		count++
+	}
+	if count > 1 {
+		return nil, nil, nil, errors.New("only one of WorkflowType, WorkflowID and CloseStatus filter is allowed")
+	}
+	return executionFilter, typeFilter, f.CloseStatus, nil
+}
+
+func (iter *workflowExecutionIteratorImpl) HasNext() bool {
+	if iter.nextExecutionIndex < len(iter.executions) || iter.err != nil {
+		return true
+	}
+	// server could return an empty page with a non empty next page token, keep paginating
+	for !iter.initialized || len(iter.nexttoken) != 0 {
+		iter.initialized = true
+		executions, nexttoken, err := iter.paginate(iter.nexttoken)
+		iter.nextExecutionIndex = 0
+		if err == nil {
+			iter.executions = executions
+			iter.nexttoken = nexttoken
+			iter.err = nil
+		} else {
+			iter.executions = nil
+			iter.nexttoken = nil
+			iter.err = err
+		}
+
+		if iter.nextExecutionIndex < len(iter.executions) || iter.err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (iter *workflowExecutionIteratorImpl) Next() (*s.WorkflowExecutionInfo, error) {
+	if !iter.HasNext() {
+		panic("WorkflowExecutionIterator Next() called without checking HasNext()")
+	}
+
+	// we have cached executions
+	if iter.nextExecutionIndex < len(iter.executions) {
+		index := iter.nextExecutionIndex
+		iter.nextExecutionIndex++
+		return iter.executions[index], nil
+	} else if iter.err != nil {
+		// we have err, clear that iter.err and return err
+		err := iter.err
+		iter.err = nil
+		return nil, err
+	}
+
+	panic("WorkflowExecutionIterator Next() should return either a workflow execution or a err")
+}
+
 func (workflowRun *workflowRunImpl) GetRunID() string {
 	return workflowRun.firstRunID
 }
@@ -817,7 +1172,7 @@ func (workflowRun *workflowRunImpl) Get(ctx context.Context, valuePtr interface{
 		if rf.Type().Kind() != reflect.Ptr {
 			return errors.New("value parameter is not a pointer")
 		}
-		err = deSerializeFunctionResult(workflowRun.workflowFn, attributes.Result, valuePtr, workflowRun.dataConverter)
+		err = deSerializeFunctionResult(workflowRun.workflowFn, attributes.Result, valuePtr, workflowRun.dataConverter, workflowRun.registry)
 	case s.EventTypeWorkflowExecutionFailed:
 		attributes := closeEvent.WorkflowExecutionFailedEventAttributes
 		err = constructError(attributes.GetReason(), attributes.Details, workflowRun.dataConverter)
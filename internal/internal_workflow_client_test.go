@@ -32,6 +32,7 @@ import (
 	"go.uber.org/cadence/internal/common"
 
 	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/cadence/internal/common/metrics"
@@ -625,6 +626,55 @@ func (s *workflowRunSuite) TestExecuteWorkflow_NoDup_ContinueAsNew() {
 	s.Equal(workflowResult, decodedResult)
 }
 
+func (s *workflowRunSuite) TestGetWorkflow() {
+	newRunID := "some other random run ID"
+	filterType := shared.HistoryEventFilterTypeCloseEvent
+	eventType1 := shared.EventTypeWorkflowExecutionContinuedAsNew
+	getRequest1 := getGetWorkflowExecutionHistoryRequest(filterType)
+	getResponse1 := &shared.GetWorkflowExecutionHistoryResponse{
+		History: &shared.History{
+			Events: []*shared.HistoryEvent{
+				{
+					EventType: &eventType1,
+					WorkflowExecutionContinuedAsNewEventAttributes: &shared.WorkflowExecutionContinuedAsNewEventAttributes{
+						NewExecutionRunId: common.StringPtr(newRunID),
+					},
+				},
+			},
+		},
+		NextPageToken: nil,
+	}
+	s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), getRequest1, gomock.Any(), gomock.Any(), gomock.Any()).Return(getResponse1, nil).Times(1)
+
+	workflowResult := time.Hour * 59
+	encodedResult, _ := encodeArg(getDefaultDataConverter(), workflowResult)
+	eventType2 := shared.EventTypeWorkflowExecutionCompleted
+	getRequest2 := getGetWorkflowExecutionHistoryRequest(filterType)
+	getRequest2.Execution.RunId = common.StringPtr(newRunID)
+	getResponse2 := &shared.GetWorkflowExecutionHistoryResponse{
+		History: &shared.History{
+			Events: []*shared.HistoryEvent{
+				{
+					EventType: &eventType2,
+					WorkflowExecutionCompletedEventAttributes: &shared.WorkflowExecutionCompletedEventAttributes{
+						Result: encodedResult,
+					},
+				},
+			},
+		},
+		NextPageToken: nil,
+	}
+	s.workflowServiceClient.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), getRequest2, gomock.Any(), gomock.Any(), gomock.Any()).Return(getResponse2, nil).Times(1)
+
+	workflowRun := s.workflowClient.GetWorkflow(context.Background(), workflowID, runID)
+	s.Equal(workflowRun.GetID(), workflowID)
+	s.Equal(workflowRun.GetRunID(), runID)
+	decodedResult := time.Minute
+	err := workflowRun.Get(context.Background(), &decodedResult)
+	s.Nil(err)
+	s.Equal(workflowResult, decodedResult)
+}
+
 func getGetWorkflowExecutionHistoryRequest(filterType shared.HistoryEventFilterType) *shared.GetWorkflowExecutionHistoryRequest {
 	isLongPoll := true
 
@@ -748,6 +798,32 @@ func (s *workflowClientTestSuite) TestStartWorkflow() {
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
 
+func (s *workflowClientTestSuite) TestStartWorkflow_WithWorkerRegistration() {
+	worker := &aggregatedWorker{hostEnv: newWorkerHostEnvironment()}
+	workflowFn := func(ctx Context) (string, error) {
+		return "result", nil
+	}
+	worker.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "workerOnlyWorkflow"})
+	s.client = NewClient(s.service, domain, &ClientOptions{Worker: worker})
+	options := StartWorkflowOptions{
+		ID:                              workflowID,
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+
+	createResponse := &shared.StartWorkflowExecutionResponse{
+		RunId: common.StringPtr(runID),
+	}
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(createResponse, nil).
+		Do(func(_ interface{}, req *shared.StartWorkflowExecutionRequest, _ ...interface{}) {
+			s.Equal("workerOnlyWorkflow", req.WorkflowType.GetName())
+		})
+
+	_, err := s.client.StartWorkflow(context.Background(), options, workflowFn)
+	s.NoError(err)
+}
+
 func (s *workflowClientTestSuite) TestStartWorkflow_WithDataConverter() {
 	dc := newTestDataConverter()
 	s.client = NewClient(s.service, domain, &ClientOptions{DataConverter: dc})
@@ -782,3 +858,553 @@ func (s *workflowClientTestSuite) TestStartWorkflow_WithDataConverter() {
 	s.Nil(err)
 	s.Equal(createResponse.GetRunId(), resp.RunID)
 }
+
+type testClientInterceptor struct {
+	name  string
+	calls *[]string
+}
+
+type testInterceptedClient struct {
+	Client
+	interceptor *testClientInterceptor
+}
+
+func (i *testClientInterceptor) InterceptClient(next Client) Client {
+	return &testInterceptedClient{Client: next, interceptor: i}
+}
+
+func (c *testInterceptedClient) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
+	*c.interceptor.calls = append(*c.interceptor.calls, c.interceptor.name+":"+signalName)
+	if signalName == "rejected" {
+		return errors.New("signal rejected by " + c.interceptor.name)
+	}
+	return c.Client.SignalWorkflow(ctx, workflowID, runID, signalName, arg)
+}
+
+func (c *testInterceptedClient) StartWorkflow(ctx context.Context, options StartWorkflowOptions, workflow interface{}, args ...interface{}) (*WorkflowExecution, error) {
+	*c.interceptor.calls = append(*c.interceptor.calls, c.interceptor.name+":start")
+	return c.Client.StartWorkflow(ctx, options, workflow, args...)
+}
+
+func (s *workflowClientTestSuite) TestInterceptors() {
+	var calls []string
+	client := NewClient(s.service, domain, &ClientOptions{
+		Interceptors: []ClientInterceptor{
+			&testClientInterceptor{name: "outer", calls: &calls},
+			&testClientInterceptor{name: "inner", calls: &calls},
+		},
+	})
+	s.service.EXPECT().SignalWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	err := client.SignalWorkflow(context.Background(), workflowID, runID, "accepted", nil)
+	s.NoError(err)
+	s.Equal([]string{"outer:accepted", "inner:accepted"}, calls)
+
+	calls = nil
+	err = client.SignalWorkflow(context.Background(), workflowID, runID, "rejected", nil)
+	s.EqualError(err, "signal rejected by outer")
+	s.Equal([]string{"outer:rejected"}, calls)
+}
+
+func (s *workflowClientTestSuite) TestInterceptors_ExecuteWorkflow() {
+	var calls []string
+	client := NewClient(s.service, domain, &ClientOptions{
+		Interceptors: []ClientInterceptor{&testClientInterceptor{name: "outer", calls: &calls}},
+	})
+	options := StartWorkflowOptions{
+		ID:                              workflowID,
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	createResponse := &shared.StartWorkflowExecutionResponse{
+		RunId: common.StringPtr(runID),
+	}
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(createResponse, nil).Times(1)
+
+	// ExecuteWorkflow starts the workflow through the intercepted StartWorkflow
+	run, err := client.ExecuteWorkflow(context.Background(), options, "workflowType")
+	s.NoError(err)
+	s.Equal(runID, run.GetRunID())
+	s.Equal([]string{"outer:start"}, calls)
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_WithContextPropagators() {
+	client := NewClient(s.service, domain, &ClientOptions{
+		ContextPropagators: []ContextPropagator{&testContextPropagator{}},
+	})
+	options := StartWorkflowOptions{
+		ID:                              workflowID,
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	createResponse := &shared.StartWorkflowExecutionResponse{
+		RunId: common.StringPtr(runID),
+	}
+	var input []byte
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *shared.StartWorkflowExecutionRequest, _ ...interface{}) (*shared.StartWorkflowExecutionResponse, error) {
+			input = req.Input
+			return createResponse, nil
+		})
+
+	ctx := context.WithValue(context.Background(), testPropagatedKey, "tenant-1")
+	_, err := client.StartWorkflow(ctx, options, "workflowType", "arg")
+	s.NoError(err)
+
+	header, payload, err := unwrapContextEnvelope(input)
+	s.NoError(err)
+	s.Equal(propagationHeader{"tenant": []byte("tenant-1")}, header)
+	var arg string
+	s.NoError(decodeArg(nil, payload, &arg))
+	s.Equal("arg", arg)
+}
+
+func (s *workflowClientTestSuite) TestStartWorkflow_WithTracer() {
+	tracer := mocktracer.New()
+	client := NewClient(s.service, domain, &ClientOptions{Tracer: tracer})
+	options := StartWorkflowOptions{
+		ID:                              workflowID,
+		TaskList:                        tasklist,
+		ExecutionStartToCloseTimeout:    timeoutInSeconds,
+		DecisionTaskStartToCloseTimeout: timeoutInSeconds,
+	}
+	createResponse := &shared.StartWorkflowExecutionResponse{
+		RunId: common.StringPtr(runID),
+	}
+	var input []byte
+	s.service.EXPECT().StartWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *shared.StartWorkflowExecutionRequest, _ ...interface{}) (*shared.StartWorkflowExecutionResponse, error) {
+			input = req.Input
+			return createResponse, nil
+		})
+
+	_, err := client.StartWorkflow(context.Background(), options, "workflowType", "arg")
+	s.NoError(err)
+
+	spans := tracer.FinishedSpans()
+	s.Len(spans, 1)
+	s.Equal("StartWorkflow", spans[0].OperationName)
+	s.Equal(workflowID, spans[0].Tag(tagWorkflowID))
+	s.Equal(runID, spans[0].Tag(tagRunID))
+
+	// the span is propagated to the workflow
+	header, _, err := unwrapContextEnvelope(input)
+	s.NoError(err)
+	propagator := &tracingContextPropagator{tracer: tracer}
+	ctx, err := propagator.Extract(context.Background(), header)
+	s.NoError(err)
+	spanContext, ok := ctx.Value(tracingContextKey{}).(mocktracer.MockSpanContext)
+	s.True(ok)
+	s.Equal(spans[0].SpanContext.SpanID, spanContext.SpanID)
+}
+
+func (s *workflowClientTestSuite) TestResetWorkflow() {
+	response := &shared.ResetWorkflowExecutionResponse{
+		RunId: common.StringPtr("new run ID"),
+	}
+	s.service.EXPECT().ResetWorkflowExecution(gomock.Any(), gomock.Any(), gomock.Any()).Return(response, nil).
+		Do(func(_ interface{}, req *shared.ResetWorkflowExecutionRequest, _ ...interface{}) {
+			s.Equal(domain, req.GetDomain())
+			s.NotEmpty(req.GetRequestId())
+			s.Equal(int64(4), req.GetDecisionFinishEventId())
+		})
+
+	request := &shared.ResetWorkflowExecutionRequest{
+		WorkflowExecution: &shared.WorkflowExecution{
+			WorkflowId: common.StringPtr(workflowID),
+			RunId:      common.StringPtr(runID),
+		},
+		Reason:                common.StringPtr("bad deployment"),
+		DecisionFinishEventId: common.Int64Ptr(4),
+	}
+	resp, err := s.client.ResetWorkflow(context.Background(), request)
+	s.Nil(err)
+	s.Equal("new run ID", resp.GetRunId())
+	// the request of the caller isn't modified, it can be reused for another reset
+	s.Nil(request.Domain)
+	s.Nil(request.RequestId)
+}
+
+func (s *workflowClientTestSuite) TestGetResetEventIDs() {
+	decisionCompleted := func(eventID int64, binaryChecksum string) *shared.HistoryEvent {
+		return &shared.HistoryEvent{
+			EventId:   common.Int64Ptr(eventID),
+			EventType: common.EventTypePtr(shared.EventTypeDecisionTaskCompleted),
+			DecisionTaskCompletedEventAttributes: &shared.DecisionTaskCompletedEventAttributes{
+				BinaryChecksum: common.StringPtr(binaryChecksum),
+			},
+		}
+	}
+	getResponse := &shared.GetWorkflowExecutionHistoryResponse{
+		History: &shared.History{
+			Events: []*shared.HistoryEvent{
+				{EventId: common.Int64Ptr(1), EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionStarted)},
+				{EventId: common.Int64Ptr(2), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskScheduled)},
+				{EventId: common.Int64Ptr(3), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskStarted)},
+				decisionCompleted(4, "good"),
+				{EventId: common.Int64Ptr(5), EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionSignaled)},
+				{EventId: common.Int64Ptr(6), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskScheduled)},
+				{EventId: common.Int64Ptr(7), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskStarted)},
+				decisionCompleted(8, "bad"),
+				{EventId: common.Int64Ptr(9), EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionSignaled)},
+				{EventId: common.Int64Ptr(10), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskScheduled)},
+				{EventId: common.Int64Ptr(11), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskStarted)},
+				decisionCompleted(12, "bad"),
+			},
+		},
+	}
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).Return(getResponse, nil).Times(6)
+	ctx := context.Background()
+
+	eventID, err := GetLastDecisionTaskCompletedEventID(ctx, s.client, workflowID, runID)
+	s.NoError(err)
+	s.Equal(int64(12), eventID)
+
+	eventID, err = GetFirstDecisionTaskCompletedEventIDByBinaryChecksum(ctx, s.client, workflowID, runID, "bad")
+	s.NoError(err)
+	s.Equal(int64(8), eventID)
+
+	_, err = GetFirstDecisionTaskCompletedEventIDByBinaryChecksum(ctx, s.client, workflowID, runID, "unknown")
+	s.Error(err)
+
+	eventID, err = GetDecisionFinishEventID(ctx, s.client, workflowID, runID, 4)
+	s.NoError(err)
+	s.Equal(int64(4), eventID)
+
+	_, err = GetDecisionFinishEventID(ctx, s.client, workflowID, runID, 5)
+	s.Error(err)
+
+	_, err = GetDecisionFinishEventID(ctx, s.client, workflowID, runID, 100)
+	s.Error(err)
+}
+
+func (s *workflowClientTestSuite) TestOpenWorkflowExecutionIterator() {
+	execution := func(id string) *shared.WorkflowExecutionInfo {
+		return &shared.WorkflowExecutionInfo{
+			Execution: &shared.WorkflowExecution{WorkflowId: common.StringPtr(id), RunId: common.StringPtr(runID)},
+		}
+	}
+	response1 := &shared.ListOpenWorkflowExecutionsResponse{
+		Executions:    []*shared.WorkflowExecutionInfo{execution("wid1"), execution("wid2")},
+		NextPageToken: []byte{1},
+	}
+	// empty page with next page token should not stop the iteration
+	response2 := &shared.ListOpenWorkflowExecutionsResponse{
+		NextPageToken: []byte{2},
+	}
+	response3 := &shared.ListOpenWorkflowExecutionsResponse{
+		Executions: []*shared.WorkflowExecutionInfo{execution("wid3")},
+	}
+	var tokens [][]byte
+	var latestTimes []int64
+	checkRequest := func(_ interface{}, req *shared.ListOpenWorkflowExecutionsRequest, _ ...interface{}) {
+		s.Equal(domain, req.GetDomain())
+		s.Equal(workflowType, req.TypeFilter.GetName())
+		s.Nil(req.ExecutionFilter)
+		s.Equal(int32(2), req.GetMaximumPageSize())
+		s.Equal(int64(0), req.StartTimeFilter.GetEarliestTime())
+		s.NotZero(req.StartTimeFilter.GetLatestTime())
+		tokens = append(tokens, req.NextPageToken)
+		latestTimes = append(latestTimes, req.StartTimeFilter.GetLatestTime())
+	}
+	gomock.InOrder(
+		s.service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).Do(checkRequest).Return(response1, nil),
+		s.service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).Do(checkRequest).Return(response2, nil),
+		s.service.EXPECT().ListOpenWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).Do(checkRequest).Return(response3, nil),
+	)
+
+	iter := NewOpenWorkflowExecutionIterator(context.Background(), s.client, ListWorkflowFilter{
+		WorkflowType: workflowType,
+		PageSize:     2,
+	})
+	var ids []string
+	for iter.HasNext() {
+		info, err := iter.Next()
+		s.NoError(err)
+		ids = append(ids, info.Execution.GetWorkflowId())
+	}
+	s.Equal([]string{"wid1", "wid2", "wid3"}, ids)
+	s.Equal([][]byte{nil, {1}, {2}}, tokens)
+	// the default latest start time doesn't change between pages
+	s.Equal([]int64{latestTimes[0], latestTimes[0], latestTimes[0]}, latestTimes)
+}
+
+func (s *workflowClientTestSuite) TestClosedWorkflowExecutionIterator() {
+	status := shared.WorkflowExecutionCloseStatusFailed
+	earliest := time.Unix(100, 0)
+	latest := time.Unix(200, 0)
+	response := &shared.ListClosedWorkflowExecutionsResponse{
+		Executions: []*shared.WorkflowExecutionInfo{{CloseStatus: &status}},
+	}
+	s.service.EXPECT().ListClosedWorkflowExecutions(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ interface{}, req *shared.ListClosedWorkflowExecutionsRequest, _ ...interface{}) {
+			s.Equal(status, req.GetStatusFilter())
+			s.Equal(earliest.UnixNano(), req.StartTimeFilter.GetEarliestTime())
+			s.Equal(latest.UnixNano(), req.StartTimeFilter.GetLatestTime())
+		}).
+		Return(response, nil).Times(1)
+
+	iter := NewClosedWorkflowExecutionIterator(context.Background(), s.client, ListWorkflowFilter{
+		CloseStatus:       &status,
+		EarliestStartTime: earliest,
+		LatestStartTime:   latest,
+	})
+	s.True(iter.HasNext())
+	info, err := iter.Next()
+	s.NoError(err)
+	s.Equal(status, info.GetCloseStatus())
+	s.False(iter.HasNext())
+}
+
+func (s *workflowClientTestSuite) TestWorkflowExecutionIterator_InvalidFilter() {
+	status := shared.WorkflowExecutionCloseStatusFailed
+	iter := NewOpenWorkflowExecutionIterator(context.Background(), s.client, ListWorkflowFilter{CloseStatus: &status})
+	s.True(iter.HasNext())
+	_, err := iter.Next()
+	s.Error(err)
+	s.False(iter.HasNext())
+
+	iter = NewClosedWorkflowExecutionIterator(context.Background(), s.client, ListWorkflowFilter{
+		WorkflowID:   workflowID,
+		WorkflowType: workflowType,
+	})
+	s.True(iter.HasNext())
+	_, err = iter.Next()
+	s.Error(err)
+}
+
+func (s *workflowClientTestSuite) TestWatchWorkflow() {
+	newRunID := "some other random run ID"
+	dc := getDefaultDataConverter()
+	input, _ := encodeArg(dc, "input")
+	activityResult, _ := encodeArg(dc, 42)
+	signalArg, _ := encodeArg(dc, "signal arg")
+	workflowResult, _ := encodeArg(dc, "done")
+	histories := map[string][]*shared.HistoryEvent{
+		runID: {
+			{
+				EventId:   common.Int64Ptr(1),
+				EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionStarted),
+				WorkflowExecutionStartedEventAttributes: &shared.WorkflowExecutionStartedEventAttributes{
+					WorkflowType: &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+					Input:        input,
+				},
+			},
+			{EventId: common.Int64Ptr(2), EventType: common.EventTypePtr(shared.EventTypeDecisionTaskScheduled)},
+			{
+				EventId:   common.Int64Ptr(5),
+				EventType: common.EventTypePtr(shared.EventTypeActivityTaskScheduled),
+				ActivityTaskScheduledEventAttributes: &shared.ActivityTaskScheduledEventAttributes{
+					ActivityId:   common.StringPtr("0"),
+					ActivityType: &shared.ActivityType{Name: common.StringPtr("my-activity")},
+				},
+			},
+			{
+				EventId:   common.Int64Ptr(7),
+				EventType: common.EventTypePtr(shared.EventTypeActivityTaskCompleted),
+				ActivityTaskCompletedEventAttributes: &shared.ActivityTaskCompletedEventAttributes{
+					ScheduledEventId: common.Int64Ptr(5),
+					Result:           activityResult,
+				},
+			},
+			{
+				EventId:   common.Int64Ptr(8),
+				EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionSignaled),
+				WorkflowExecutionSignaledEventAttributes: &shared.WorkflowExecutionSignaledEventAttributes{
+					SignalName: common.StringPtr("my-signal"),
+					Input:      signalArg,
+				},
+			},
+			{
+				EventId:   common.Int64Ptr(12),
+				EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionContinuedAsNew),
+				WorkflowExecutionContinuedAsNewEventAttributes: &shared.WorkflowExecutionContinuedAsNewEventAttributes{
+					NewExecutionRunId: common.StringPtr(newRunID),
+					WorkflowType:      &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+				},
+			},
+		},
+		newRunID: {
+			{
+				EventId:   common.Int64Ptr(1),
+				EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionStarted),
+				WorkflowExecutionStartedEventAttributes: &shared.WorkflowExecutionStartedEventAttributes{
+					WorkflowType: &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+				},
+			},
+			{
+				EventId:   common.Int64Ptr(5),
+				EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionCompleted),
+				WorkflowExecutionCompletedEventAttributes: &shared.WorkflowExecutionCompletedEventAttributes{
+					Result: workflowResult,
+				},
+			},
+		},
+	}
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *shared.GetWorkflowExecutionHistoryRequest, _ ...interface{}) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+			s.True(req.GetWaitForNewEvent())
+			return &shared.GetWorkflowExecutionHistoryResponse{
+				History: &shared.History{Events: histories[req.Execution.GetRunId()]},
+			}, nil
+		}).Times(2)
+
+	var events []WorkflowEvent
+	for event := range s.client.WatchWorkflow(context.Background(), workflowID, runID) {
+		events = append(events, event)
+	}
+
+	var types []WorkflowEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	s.Equal([]WorkflowEventType{
+		WorkflowEventTypeWorkflowStarted,
+		WorkflowEventTypeActivityScheduled,
+		WorkflowEventTypeActivityClosed,
+		WorkflowEventTypeSignalReceived,
+		WorkflowEventTypeWorkflowContinuedAsNew,
+		WorkflowEventTypeWorkflowStarted,
+		WorkflowEventTypeWorkflowClosed,
+	}, types)
+
+	var str string
+	s.NoError(events[0].Payload.Get(&str))
+	s.Equal("input", str)
+
+	var result int
+	s.Equal("my-activity", events[2].Name)
+	s.Equal("0", events[2].ID)
+	s.Nil(events[2].Err)
+	s.NoError(events[2].Payload.Get(&result))
+	s.Equal(42, result)
+
+	s.Equal("my-signal", events[3].Name)
+	s.NoError(events[3].Payload.Get(&str))
+	s.Equal("signal arg", str)
+
+	s.Equal(WorkflowExecution{ID: workflowID, RunID: newRunID}, events[6].Execution)
+	s.NoError(events[6].Payload.Get(&str))
+	s.Equal("done", str)
+}
+
+func (s *workflowClientTestSuite) TestWatchWorkflow_Error() {
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, &shared.EntityNotExistsError{}).Times(1)
+
+	var events []WorkflowEvent
+	for event := range s.client.WatchWorkflow(context.Background(), workflowID, runID) {
+		events = append(events, event)
+	}
+	s.Equal(1, len(events))
+	s.Equal(WorkflowEventTypeError, events[0].Type)
+	s.Equal(&shared.EntityNotExistsError{}, events[0].Err)
+}
+
+func (s *workflowClientTestSuite) TestWatchWorkflow_CanceledDuringPoll() {
+	ctx, cancel := context.WithCancel(context.Background())
+	polling := make(chan struct{})
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *shared.GetWorkflowExecutionHistoryRequest, _ ...interface{}) (*shared.GetWorkflowExecutionHistoryResponse, error) {
+			close(polling)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Times(1)
+
+	eventCh := s.client.WatchWorkflow(ctx, workflowID, runID)
+	<-polling
+	cancel()
+	var events []WorkflowEvent
+	for event := range eventCh {
+		events = append(events, event)
+	}
+	s.Empty(events)
+}
+
+func init() {
+	RegisterWorkflow(testQueryFromHistoryWorkflow)
+}
+
+func testQueryFromHistoryWorkflow(ctx Context) (string, error) {
+	state := "started"
+	err := SetQueryHandler(ctx, "state", func() (string, error) {
+		return state, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx = WithActivityOptions(ctx, ActivityOptions{
+		ScheduleToStartTimeout: time.Second,
+		StartToCloseTimeout:    time.Second,
+	})
+	if err := ExecuteActivity(ctx, "testActivity").Get(ctx, nil); err != nil {
+		return "", err
+	}
+	state = "done"
+	return state, nil
+}
+
+func newTestQueryFromHistoryResponse(workflowType string) *shared.GetWorkflowExecutionHistoryResponse {
+	return &shared.GetWorkflowExecutionHistoryResponse{
+		History: &shared.History{
+			Events: []*shared.HistoryEvent{
+				createTestEventWorkflowExecutionStarted(1, &shared.WorkflowExecutionStartedEventAttributes{
+					WorkflowType: &shared.WorkflowType{Name: common.StringPtr(workflowType)},
+					TaskList:     &shared.TaskList{Name: common.StringPtr(tasklist)},
+				}),
+				createTestEventDecisionTaskScheduled(2, &shared.DecisionTaskScheduledEventAttributes{}),
+				createTestEventDecisionTaskStarted(3),
+				createTestEventDecisionTaskCompleted(4, &shared.DecisionTaskCompletedEventAttributes{}),
+				createTestEventActivityTaskScheduled(5, &shared.ActivityTaskScheduledEventAttributes{
+					ActivityId:   common.StringPtr("0"),
+					ActivityType: &shared.ActivityType{Name: common.StringPtr("testActivity")},
+					TaskList:     &shared.TaskList{Name: common.StringPtr(tasklist)},
+				}),
+				createTestEventActivityTaskStarted(6, &shared.ActivityTaskStartedEventAttributes{ScheduledEventId: common.Int64Ptr(5)}),
+				createTestEventActivityTaskCompleted(7, &shared.ActivityTaskCompletedEventAttributes{ScheduledEventId: common.Int64Ptr(5)}),
+				createTestEventDecisionTaskScheduled(8, &shared.DecisionTaskScheduledEventAttributes{}),
+				createTestEventDecisionTaskStarted(9),
+				createTestEventDecisionTaskCompleted(10, &shared.DecisionTaskCompletedEventAttributes{}),
+				{
+					EventId:   common.Int64Ptr(11),
+					EventType: common.EventTypePtr(shared.EventTypeWorkflowExecutionCompleted),
+					WorkflowExecutionCompletedEventAttributes: &shared.WorkflowExecutionCompletedEventAttributes{
+						DecisionTaskCompletedEventId: common.Int64Ptr(10),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *workflowClientTestSuite) TestQueryWorkflowFromHistory() {
+	getResponse := newTestQueryFromHistoryResponse(getFunctionName(testQueryFromHistoryWorkflow))
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).Return(getResponse, nil).Times(2)
+
+	value, err := s.client.QueryWorkflowFromHistory(context.Background(), workflowID, runID, "state")
+	s.NoError(err)
+	var state string
+	s.NoError(value.Get(&state))
+	s.Equal("done", state)
+
+	_, err = s.client.QueryWorkflowFromHistory(context.Background(), workflowID, runID, "unknown")
+	s.Error(err)
+	s.IsType(&shared.QueryFailedError{}, err)
+}
+
+func (s *workflowClientTestSuite) TestQueryWorkflowFromHistory_WithWorkerRegistration() {
+	worker := &aggregatedWorker{hostEnv: newWorkerHostEnvironment()}
+	worker.RegisterWorkflowWithOptions(testQueryFromHistoryWorkflow, RegisterWorkflowOptions{Name: "workerOnlyQueryWorkflow"})
+	s.client = NewClient(s.service, domain, &ClientOptions{Worker: worker})
+	getResponse := newTestQueryFromHistoryResponse("workerOnlyQueryWorkflow")
+	s.service.EXPECT().GetWorkflowExecutionHistory(gomock.Any(), gomock.Any(), gomock.Any()).Return(getResponse, nil).Times(1)
+
+	value, err := s.client.QueryWorkflowFromHistory(context.Background(), workflowID, runID, "state")
+	s.NoError(err)
+	var state string
+	s.NoError(value.Get(&state))
+	s.Equal("done", state)
+}
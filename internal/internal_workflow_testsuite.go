@@ -88,12 +88,14 @@ type (
 
 	activityExecutorWrapper struct {
 		*activityExecutor
-		env *testWorkflowEnvironmentImpl
+		env     *testWorkflowEnvironmentImpl
+		dynamic *dynamicActivityExecutor // runs the activity when its type has no registration
 	}
 
 	workflowExecutorWrapper struct {
 		*workflowExecutor
-		env *testWorkflowEnvironmentImpl
+		env     *testWorkflowEnvironmentImpl
+		dynamic *dynamicWorkflowExecutor // runs the workflow when its type has no registration
 	}
 
 	mockWrapper struct {
@@ -113,6 +115,7 @@ type (
 	testWorkflowEnvironmentShared struct {
 		locker    sync.Mutex
 		testSuite *WorkflowTestSuite
+		registry  *hostEnvImpl
 
 		taskListSpecificActivities map[string]*taskListSpecificActivity
 
@@ -181,6 +184,7 @@ func newTestWorkflowEnvironmentImpl(s *WorkflowTestSuite) *testWorkflowEnvironme
 	env := &testWorkflowEnvironmentImpl{
 		testWorkflowEnvironmentShared: &testWorkflowEnvironmentShared{
 			testSuite:                  s,
+			registry:                   newWorkerHostEnvironment(),
 			taskListSpecificActivities: make(map[string]*taskListSpecificActivity),
 
 			logger:           s.logger,
@@ -360,6 +364,15 @@ func (env *testWorkflowEnvironmentImpl) setWorkerOptions(options WorkerOptions)
 	if options.DataConverter != nil {
 		env.workerOptions.DataConverter = options.DataConverter
 	}
+	if len(options.WorkflowInterceptors) > 0 {
+		env.workerOptions.WorkflowInterceptors = options.WorkflowInterceptors
+	}
+	if len(options.ContextPropagators) > 0 {
+		env.workerOptions.ContextPropagators = options.ContextPropagators
+	}
+	if len(options.ActivityInterceptors) > 0 {
+		env.workerOptions.ActivityInterceptors = options.ActivityInterceptors
+	}
 }
 
 func (env *testWorkflowEnvironmentImpl) setActivityTaskList(tasklist string, activityFns ...interface{}) {
@@ -375,7 +388,7 @@ func (env *testWorkflowEnvironmentImpl) setActivityTaskList(tasklist string, act
 }
 
 func (env *testWorkflowEnvironmentImpl) executeWorkflow(workflowFn interface{}, args ...interface{}) {
-	workflowType, input, err := getValidatedWorkflowFunction(workflowFn, args, env.GetDataConverter())
+	workflowType, input, err := getValidatedWorkflowFunction(workflowFn, args, env.GetDataConverter(), env.GetRegistry())
 	if err != nil {
 		panic(err)
 	}
@@ -427,10 +440,17 @@ func (env *testWorkflowEnvironmentImpl) executeWorkflowInternal(delayStart time.
 }
 
 func (env *testWorkflowEnvironmentImpl) getWorkflowDefinition(wt WorkflowType) (workflowDefinition, error) {
-	hostEnv := getHostEnvironment()
-	wf, ok := hostEnv.getWorkflowFn(wt.Name)
+	wf, ok := env.registry.getWorkflowFn(wt.Name)
 	if !ok {
-		supported := strings.Join(hostEnv.getRegisteredWorkflowTypes(), ", ")
+		if dwf := env.registry.getDynamicWorkflow(); dwf != nil {
+			wd := &workflowExecutorWrapper{
+				workflowExecutor: &workflowExecutor{name: wt.Name, fn: dwf},
+				env:              env,
+				dynamic:          &dynamicWorkflowExecutor{name: wt.Name, fn: dwf},
+			}
+			return newWorkflowDefinition(wd), nil
+		}
+		supported := strings.Join(env.registry.getRegisteredWorkflowTypes(), ", ")
 		return nil, fmt.Errorf("Unable to find workflow type: %v. Supported types: [%v]", wt.Name, supported)
 	}
 	wd := &workflowExecutorWrapper{
@@ -444,7 +464,7 @@ func (env *testWorkflowEnvironmentImpl) executeActivity(
 	activityFn interface{},
 	args ...interface{},
 ) (encoded.Value, error) {
-	activityType, input, err := getValidatedActivityFunction(activityFn, args, env.GetDataConverter())
+	activityType, input, err := getValidatedActivityFunction(activityFn, args, env.GetDataConverter(), env.GetRegistry())
 	if err != nil {
 		panic(err)
 	}
@@ -518,6 +538,7 @@ func (env *testWorkflowEnvironmentImpl) executeLocalActivity(
 		userContext:  env.workerOptions.BackgroundActivityContext,
 		metricsScope: env.metricsScope,
 		logger:       env.logger,
+		interceptors: env.workerOptions.ActivityInterceptors,
 	}
 
 	result := taskHandler.executeLocalActivityTask(task)
@@ -849,6 +870,18 @@ func (env *testWorkflowEnvironmentImpl) GetDataConverter() encoded.DataConverter
 	return env.workerOptions.DataConverter
 }
 
+func (env *testWorkflowEnvironmentImpl) GetRegistry() *hostEnvImpl {
+	return env.registry
+}
+
+func (env *testWorkflowEnvironmentImpl) GetWorkflowInterceptors() []WorkflowInterceptor {
+	return env.workerOptions.WorkflowInterceptors
+}
+
+func (env *testWorkflowEnvironmentImpl) GetContextPropagators() []ContextPropagator {
+	return env.workerOptions.ContextPropagators
+}
+
 func (env *testWorkflowEnvironmentImpl) ExecuteActivity(parameters executeActivityParams, callback resultHandler) *activityInfo {
 	var activityID string
 	if parameters.ActivityID == nil || *parameters.ActivityID == "" {
@@ -984,7 +1017,7 @@ func (env *testWorkflowEnvironmentImpl) ExecuteLocalActivity(params executeLocal
 	activityID := getStringID(env.nextID())
 	wOptions := fillWorkerOptionsDefaults(env.workerOptions)
 	ae := &activityExecutor{name: getFunctionName(params.ActivityFn), fn: params.ActivityFn}
-	if at, _, _ := getValidatedActivityFunction(params.ActivityFn, params.InputArgs, wOptions.DataConverter); at != nil {
+	if at, _, _ := getValidatedActivityFunction(params.ActivityFn, params.InputArgs, wOptions.DataConverter, env.GetRegistry()); at != nil {
 		// local activity could be registered, if so use the registered name. This name is only used to find a mock.
 		ae.name = at.Name
 	}
@@ -992,15 +1025,20 @@ func (env *testWorkflowEnvironmentImpl) ExecuteLocalActivity(params executeLocal
 
 	// substitute the local activity function so we could replace with mock if it is supplied.
 	params.ActivityFn = func(ctx context.Context, inputArgs ...interface{}) ([]byte, error) {
+		// the interceptors wrap the local activity function like on a worker, not this substitute
+		activityEnv := *getActivityEnv(ctx)
+		activityEnv.interceptors = wOptions.ActivityInterceptors
+		ctx = context.WithValue(ctx, activityEnvContextKey, &activityEnv)
 		return aew.ExecuteWithActualArgs(ctx, params.InputArgs)
 	}
 
 	task := newLocalActivityTask(params, callback, activityID)
 	taskHandler := localActivityTaskHandler{
-		userContext:   wOptions.BackgroundActivityContext,
-		metricsScope:  metrics.NewTaggedScope(wOptions.MetricsScope),
-		logger:        wOptions.Logger,
-		dataConverter: wOptions.DataConverter,
+		userContext:        wOptions.BackgroundActivityContext,
+		metricsScope:       metrics.NewTaggedScope(wOptions.MetricsScope),
+		logger:             wOptions.Logger,
+		dataConverter:      wOptions.DataConverter,
+		contextPropagators: wOptions.ContextPropagators,
 	}
 
 	env.localActivities[activityID] = task
@@ -1161,6 +1199,11 @@ func (a *activityExecutorWrapper) Execute(ctx context.Context, input []byte) ([]
 		<-waitCh // wait until listener returns
 	}
 
+	if a.dynamic != nil {
+		// the arguments of a dynamic activity can't be matched against its function, it isn't mocked
+		return a.dynamic.Execute(ctx, input)
+	}
+
 	m := &mockWrapper{env: a.env, name: a.name, fn: a.fn, isWorkflow: false, dataConverter: dc}
 	if mockRet := m.getMockReturn(ctx, input); mockRet != nil {
 		return m.executeMock(ctx, input, mockRet)
@@ -1214,7 +1257,11 @@ func (w *workflowExecutorWrapper) Execute(ctx Context, input []byte) (result []b
 		// getMockReturn could block if mock is configured to wait. The returned mockRet is what has been configured
 		// for the mock by using MockCallWrapper.Return(). The mockRet could be mock values or mock function. We process
 		// the returned mockRet by calling executeMock() later in the main thread after it is send over via mockReadyChannel.
-		mockRet := m.getMockReturn(ctxCopy, input)
+		var mockRet mock.Arguments
+		if w.dynamic == nil {
+			// the arguments of a dynamic workflow can't be matched against its function, it isn't mocked
+			mockRet = m.getMockReturn(ctxCopy, input)
+		}
 		env.postCallback(func() {
 			mockReadyChannel.SendAsync(mockRet)
 		}, true /* true to trigger the dispatcher for this workflow so it resume from mockReadyChannel block*/)
@@ -1251,6 +1298,9 @@ func (w *workflowExecutorWrapper) Execute(ctx Context, input []byte) (result []b
 	}
 
 	// no mock, so call the actual workflow
+	if w.dynamic != nil {
+		return w.dynamic.Execute(ctx, input)
+	}
 	return w.workflowExecutor.Execute(ctx, input)
 }
 
@@ -1408,16 +1458,18 @@ func (m *mockWrapper) executeMockWithActualArgs(ctx interface{}, inputArgs []int
 func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList string, dataConverter encoded.DataConverter) ActivityTaskHandler {
 	wOptions := fillWorkerOptionsDefaults(env.workerOptions)
 	params := workerExecutionParameters{
-		TaskList:      taskList,
-		Identity:      wOptions.Identity,
-		MetricsScope:  wOptions.MetricsScope,
-		Logger:        wOptions.Logger,
-		UserContext:   wOptions.BackgroundActivityContext,
-		DataConverter: dataConverter,
+		TaskList:             taskList,
+		Identity:             wOptions.Identity,
+		MetricsScope:         wOptions.MetricsScope,
+		Logger:               wOptions.Logger,
+		UserContext:          wOptions.BackgroundActivityContext,
+		DataConverter:        dataConverter,
+		ContextPropagators:   wOptions.ContextPropagators,
+		ActivityInterceptors: wOptions.ActivityInterceptors,
 	}
 	ensureRequiredParams(&params)
 
-	if len(getHostEnvironment().getRegisteredActivities()) == 0 {
+	if len(env.registry.getRegisteredActivities()) == 0 && env.registry.getDynamicActivity() == nil {
 		panic(fmt.Sprintf("no activity is registered for tasklist '%v'", taskList))
 	}
 
@@ -1431,15 +1483,19 @@ func (env *testWorkflowEnvironmentImpl) newTestActivityTaskHandler(taskList stri
 			}
 		}
 
-		activity, ok := getHostEnvironment().getActivity(name)
+		activity, ok := env.registry.getActivity(name)
 		if !ok {
+			if af := env.registry.getDynamicActivity(); af != nil {
+				ae := &activityExecutor{name: name, fn: af}
+				return &activityExecutorWrapper{activityExecutor: ae, env: env, dynamic: &dynamicActivityExecutor{name: name, fn: af}}
+			}
 			return nil
 		}
 		ae := &activityExecutor{name: activity.ActivityType().Name, fn: activity.GetFunction()}
 		return &activityExecutorWrapper{activityExecutor: ae, env: env}
 	}
 
-	taskHandler := newActivityTaskHandlerWithCustomProvider(env.service, params, getHostEnvironment(), getActivity)
+	taskHandler := newActivityTaskHandlerWithCustomProvider(env.service, params, env.registry, getActivity)
 	return taskHandler
 }
 
@@ -25,7 +25,9 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -337,6 +339,323 @@ func (s *WorkflowTestSuiteUnitTest) Test_WorkflowActivityCancellation() {
 	s.Equal(activityMap["slow"], cancelledActivityID)
 }
 
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowWithTimeout() {
+	workflowFn := func(ctx Context) (time.Duration, error) {
+		start := Now(ctx)
+		timeoutCtx, cancel := WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		// a later deadline doesn't extend the one of the parent
+		nestedCtx, nestedCancel := WithTimeout(timeoutCtx, time.Hour)
+		defer nestedCancel()
+		deadline, ok := nestedCtx.Deadline()
+		if !ok || !deadline.Equal(start.Add(time.Minute)) {
+			return 0, errors.New("unexpected deadline")
+		}
+
+		err := Sleep(nestedCtx, time.Hour)
+		if _, ok := err.(*CanceledError); !ok {
+			return 0, err
+		}
+		if timeoutCtx.Err() != ErrDeadlineExceeded || nestedCtx.Err() != ErrDeadlineExceeded {
+			return 0, errors.New("context didn't expire")
+		}
+
+		// a context canceled before its deadline isn't expired
+		canceledCtx, cancel := WithTimeout(ctx, time.Minute)
+		cancel()
+		if canceledCtx.Err() != ErrCanceled {
+			return 0, errors.New("context wasn't canceled")
+		}
+		return Now(ctx).Sub(start), nil
+	}
+
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var elapsed time.Duration
+	s.NoError(env.GetWorkflowResult(&elapsed))
+	s.Equal(time.Minute, elapsed)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_Await() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var results []string
+		var approved bool
+		Go(ctx, func(ctx Context) {
+			GetSignalChannel(ctx, "approve").Receive(ctx, &approved)
+		})
+
+		// the timeout elapses before the signal is received
+		ok, err := AwaitWithTimeout(ctx, time.Minute, func() bool { return approved })
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fmt.Sprintf("timed out: %v", !ok))
+
+		if err := Await(ctx, func() bool { return approved }); err != nil {
+			return nil, err
+		}
+		results = append(results, "approved")
+
+		// the condition is satisfied without blocking
+		ok, err = AwaitWithTimeout(ctx, time.Minute, func() bool { return approved })
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fmt.Sprintf("satisfied: %v", ok))
+
+		canceledCtx, cancel := WithCancel(ctx)
+		cancel()
+		err = Await(canceledCtx, func() bool { return false })
+		results = append(results, fmt.Sprintf("canceled: %v", err == ErrCanceled))
+		return results, nil
+	}
+
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approve", true)
+	}, time.Hour)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{"timed out: true", "approved", "satisfied: true", "canceled: true"}, results)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ExecuteInParallel() {
+	var running, maxRunning int32
+	parallelActivity := func(ctx context.Context, input string) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if input == "bad" {
+			return 0, errors.New("bad input")
+		}
+		return len(input), nil
+	}
+	workflowFn := func(ctx Context, policy ParallelFailurePolicy) ([]string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		options := ParallelOptions{MaxConcurrency: 2, FailurePolicy: policy}
+		var lengths []int
+		errs, err := ExecuteInParallel(ctx, options, parallelActivity, []string{"a", "bad", "ccc", "dddd"}, &lengths)
+		if len(errs) != 4 || len(lengths) != 4 {
+			return nil, fmt.Errorf("unexpected results: %v %v", errs, lengths)
+		}
+		results := []string{fmt.Sprintf("err: %v", err)}
+		for i, err := range errs {
+			if err != nil {
+				results = append(results, err.Error())
+			} else {
+				results = append(results, strconv.Itoa(lengths[i]))
+			}
+		}
+		return results, nil
+	}
+	RegisterWorkflow(workflowFn)
+	RegisterActivity(parallelActivity)
+
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(workflowFn, ParallelFailurePolicyCollectAll)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{"err: bad input", "1", "bad input", "3", "4"}, results)
+	s.True(maxRunning <= 2)
+
+	env = s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(workflowFn, ParallelFailurePolicyFailFast)
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal("err: bad input", results[0])
+	s.Equal("bad input", results[2])
+	s.Equal("CanceledError", results[4])
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_SignalHandler() {
+	workflowFn := func(ctx Context) ([]string, error) {
+		var items []string
+		err := SetSignalHandler(ctx, "add", func(ctx Context, item string) {
+			items = append(items, item)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := SetSignalHandler(ctx, "add", func(ctx Context) {}); err == nil {
+			return nil, errors.New("signal handler set twice")
+		}
+		if err := SetSignalHandler(ctx, "invalid", func(item string) {}); err == nil {
+			return nil, errors.New("signal handler without context")
+		}
+		if err := SetSignalHandler(ctx, "invalid", func(ctx Context) error { return nil }); err == nil {
+			return nil, errors.New("signal handler with result")
+		}
+
+		if err := Await(ctx, func() bool { return len(items) == 2 }); err != nil {
+			return nil, err
+		}
+		return append(items, GetUnhandledSignalNames(ctx)...), nil
+	}
+
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", "a")
+		env.SignalWorkflow("unhandled", "c")
+		env.SignalWorkflow("add", "b")
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result []string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal([]string{"a", "b", "unhandled"}, result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ContinueAsNewWithSignals() {
+	workflowFn := func(ctx Context) error {
+		var item string
+		GetSignalChannel(ctx, "add").Receive(ctx, &item)
+		// the signals are delivered in order, the other ones are buffered once "done" is received
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return ContinueAsNewWithSignals(ctx, "this-workflow-fn", item)
+	}
+
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", "a")
+		env.SignalWorkflow("remove", "b")
+		env.SignalWorkflow("add", "c")
+		env.SignalWorkflow("add", "d")
+		env.SignalWorkflow("done", nil)
+	}, time.Minute)
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	continueAsNewErr, ok := env.GetWorkflowError().(*ContinueAsNewError)
+	s.True(ok)
+	envelope, err := decodeContextEnvelope(continueAsNewErr.params.input)
+	s.NoError(err)
+	dc := getDefaultDataConverter()
+	encode := func(value string) []byte {
+		data, err := encodeArg(dc, value)
+		s.NoError(err)
+		return data
+	}
+	s.Equal(encode("a"), envelope.Payload)
+	s.Equal([]carriedSignal{
+		{Name: "add", Input: encode("c")},
+		{Name: "add", Input: encode("d")},
+		{Name: "remove", Input: encode("b")},
+	}, envelope.Signals)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ContinueAsNewWithSignals_NextRun() {
+	workflowFn := func(ctx Context) error {
+		var item string
+		GetSignalChannel(ctx, "add").Receive(ctx, &item)
+		GetSignalChannel(ctx, "done").Receive(ctx, nil)
+		return ContinueAsNewWithSignals(ctx, "nextRunWorkflow", item)
+	}
+	nextRunFn := func(ctx Context, item string) ([]string, error) {
+		// the signal sent to this run is buffered with the carried ones before the workflow receives any
+		if err := Sleep(ctx, time.Hour); err != nil {
+			return nil, err
+		}
+		received := []string{item}
+		add := GetSignalChannel(ctx, "add")
+		for i := 0; i < 3; i++ {
+			var v string
+			add.Receive(ctx, &v)
+			received = append(received, "add:"+v)
+		}
+		var v string
+		GetSignalChannel(ctx, "remove").Receive(ctx, &v)
+		return append(received, "remove:"+v), nil
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "continuedWorkflow"})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("add", "a")
+		env.SignalWorkflow("remove", "b")
+		env.SignalWorkflow("add", "c")
+		env.SignalWorkflow("add", "d")
+		env.SignalWorkflow("done", nil)
+	}, time.Minute)
+	env.ExecuteWorkflow("continuedWorkflow")
+	s.True(env.IsWorkflowCompleted())
+	continueAsNewErr, ok := env.GetWorkflowError().(*ContinueAsNewError)
+	s.True(ok)
+
+	// the next run gets the carried signals before the signal sent to it
+	nextEnv := s.NewTestWorkflowEnvironment()
+	nextEnv.RegisterWorkflowWithOptions(nextRunFn, RegisterWorkflowOptions{Name: "nextRunWorkflow"})
+	nextEnv.RegisterDelayedCallback(func() {
+		nextEnv.SignalWorkflow("add", "e")
+	}, time.Minute)
+	nextEnv.impl.executeWorkflowInternal(0, continueAsNewErr.params.workflowType.Name, continueAsNewErr.params.input)
+
+	s.True(nextEnv.IsWorkflowCompleted())
+	s.NoError(nextEnv.GetWorkflowError())
+	var result []string
+	s.NoError(nextEnv.GetWorkflowResult(&result))
+	s.Equal([]string{"a", "add:c", "add:d", "add:e", "remove:b"}, result)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_EnvironmentRegistration() {
+	activityFn := func(ctx context.Context, name string) (string, error) {
+		return "hello " + name, nil
+	}
+	workflowFn := func(ctx Context, name string) (string, error) {
+		ctx = WithActivityOptions(ctx, s.activityOptions)
+		var result string
+		err := ExecuteActivity(ctx, activityFn, name).Get(ctx, &result)
+		return result, err
+	}
+
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflowFn, RegisterWorkflowOptions{Name: "envOnlyWorkflow"})
+	env.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "envOnlyActivity"})
+	var activityType string
+	env.SetOnActivityStartedListener(func(activityInfo *ActivityInfo, ctx context.Context, args encoded.Values) {
+		activityType = activityInfo.ActivityType.Name
+	})
+	env.ExecuteWorkflow("envOnlyWorkflow", "cadence")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello cadence", result)
+	s.Equal("envOnlyActivity", activityType)
+	_, ok := getHostEnvironment().getWorkflowFn("envOnlyWorkflow")
+	s.False(ok)
+
+	activityEnv := s.NewTestActivityEnvironment()
+	activityEnv.RegisterActivityWithOptions(activityFn, RegisterActivityOptions{Name: "envOnlyActivity"})
+	value, err := activityEnv.ExecuteActivity("envOnlyActivity", "activity")
+	s.NoError(err)
+	s.NoError(value.Get(&result))
+	s.Equal("hello activity", result)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ActivityWithUserContext() {
 	testKey, testValue := testContextKey("test_key"), "test_value"
 	userCtx := context.WithValue(context.Background(), testKey, testValue)
@@ -512,6 +831,160 @@ func (s *WorkflowTestSuiteUnitTest) Test_SideEffect() {
 	s.Nil(env.GetWorkflowError())
 }
 
+type testWorkflowInterceptor struct {
+	calls []string
+}
+
+type testWorkflowOperations struct {
+	WorkflowOperations
+	interceptor *testWorkflowInterceptor
+	options     ActivityOptions
+}
+
+func (i *testWorkflowInterceptor) InterceptWorkflow(info *WorkflowInfo, next WorkflowOperations) WorkflowOperations {
+	return &testWorkflowOperations{
+		WorkflowOperations: next,
+		interceptor:        i,
+		options: ActivityOptions{
+			ScheduleToStartTimeout: time.Minute,
+			StartToCloseTimeout:    time.Minute,
+		},
+	}
+}
+
+func (o *testWorkflowOperations) ExecuteWorkflow(ctx Context, workflowType string, input []byte) ([]byte, error) {
+	o.interceptor.calls = append(o.interceptor.calls, "ExecuteWorkflow")
+	return o.WorkflowOperations.ExecuteWorkflow(ctx, workflowType, input)
+}
+
+func (o *testWorkflowOperations) ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	o.interceptor.calls = append(o.interceptor.calls, "ExecuteActivity")
+	// inject the default activity options
+	return o.WorkflowOperations.ExecuteActivity(WithActivityOptions(ctx, o.options), activity, args...)
+}
+
+func (o *testWorkflowOperations) SideEffect(ctx Context, f func(ctx Context) interface{}) encoded.Value {
+	o.interceptor.calls = append(o.interceptor.calls, "SideEffect")
+	return o.WorkflowOperations.SideEffect(ctx, f)
+}
+
+func (o *testWorkflowOperations) NewTimer(ctx Context, d time.Duration) Future {
+	o.interceptor.calls = append(o.interceptor.calls, "NewTimer")
+	return o.WorkflowOperations.NewTimer(ctx, d)
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_WorkflowInterceptor() {
+	workflowFn := func(ctx Context) (string, error) {
+		var v int
+		if err := SideEffect(ctx, func(ctx Context) interface{} { return 1 }).Get(&v); err != nil {
+			return "", err
+		}
+		if err := Sleep(ctx, time.Minute); err != nil {
+			return "", err
+		}
+		var result string
+		err := ExecuteActivity(ctx, testActivityHello, "interceptor").Get(ctx, &result)
+		return result, err
+	}
+
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	interceptor := &testWorkflowInterceptor{}
+	env.SetWorkerOptions(WorkerOptions{WorkflowInterceptors: []WorkflowInterceptor{interceptor}})
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var result string
+	s.NoError(env.GetWorkflowResult(&result))
+	s.Equal("hello_interceptor", result)
+	s.Equal([]string{"ExecuteWorkflow", "SideEffect", "NewTimer", "ExecuteActivity"}, interceptor.calls)
+}
+
+const testPropagatedKey = testContextKey("tenant")
+
+// testContextPropagator propagates the string value of testPropagatedKey.
+type testContextPropagator struct{}
+
+func (p *testContextPropagator) Inject(ctx context.Context, writer HeaderWriter) error {
+	if value, ok := ctx.Value(testPropagatedKey).(string); ok {
+		writer.Set(string(testPropagatedKey), []byte(value))
+	}
+	return nil
+}
+
+func (p *testContextPropagator) Extract(ctx context.Context, reader HeaderReader) (context.Context, error) {
+	err := reader.ForEachKey(func(key string, value []byte) error {
+		if key == string(testPropagatedKey) {
+			ctx = context.WithValue(ctx, testPropagatedKey, string(value))
+		}
+		return nil
+	})
+	return ctx, err
+}
+
+func (p *testContextPropagator) InjectFromWorkflow(ctx Context, writer HeaderWriter) error {
+	if value, ok := ctx.Value(testPropagatedKey).(string); ok {
+		writer.Set(string(testPropagatedKey), []byte(value))
+	}
+	return nil
+}
+
+func (p *testContextPropagator) ExtractToWorkflow(ctx Context, reader HeaderReader) (Context, error) {
+	err := reader.ForEachKey(func(key string, value []byte) error {
+		if key == string(testPropagatedKey) {
+			ctx = WithValue(ctx, testPropagatedKey, string(value))
+		}
+		return nil
+	})
+	return ctx, err
+}
+
+func (s *WorkflowTestSuiteUnitTest) Test_ContextPropagation() {
+	activityFn := func(ctx context.Context) (string, error) {
+		value, _ := ctx.Value(testPropagatedKey).(string)
+		return "activity:" + value, nil
+	}
+	childWorkflowFn := func(ctx Context) (string, error) {
+		value, _ := ctx.Value(testPropagatedKey).(string)
+		return "child:" + value, nil
+	}
+	workflowFn := func(ctx Context) ([]string, error) {
+		ctx = WithValue(ctx, testPropagatedKey, "tenant-1")
+		var results []string
+		var result string
+		ctx1 := WithActivityOptions(ctx, s.activityOptions)
+		if err := ExecuteActivity(ctx1, activityFn).Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+		ctx2 := WithLocalActivityOptions(ctx, s.localActivityOptions)
+		if err := ExecuteLocalActivity(ctx2, activityFn).Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, "local "+result)
+		ctx3 := WithChildWorkflowOptions(ctx, ChildWorkflowOptions{ExecutionStartToCloseTimeout: time.Minute})
+		if err := ExecuteChildWorkflow(ctx3, childWorkflowFn).Get(ctx, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+		return results, nil
+	}
+
+	RegisterActivity(activityFn)
+	RegisterWorkflow(childWorkflowFn)
+	RegisterWorkflow(workflowFn)
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(WorkerOptions{ContextPropagators: []ContextPropagator{&testContextPropagator{}}})
+	env.ExecuteWorkflow(workflowFn)
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	var results []string
+	s.NoError(env.GetWorkflowResult(&results))
+	s.Equal([]string{"activity:tenant-1", "local activity:tenant-1", "child:tenant-1"}, results)
+}
+
 func (s *WorkflowTestSuiteUnitTest) Test_ChildWorkflow_Basic() {
 	workflowFn := func(ctx Context) (string, error) {
 		ctx = WithActivityOptions(ctx, s.activityOptions)
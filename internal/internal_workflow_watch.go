@@ -0,0 +1,367 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	s "go.uber.org/cadence/.gen/go/shared"
+	"go.uber.org/cadence/encoded"
+	"go.uber.org/cadence/internal/common"
+)
+
+type (
+	// WorkflowEventType is the type of a WorkflowEvent.
+	WorkflowEventType int
+
+	// WorkflowEvent is a history event of a workflow execution, decoded by Client.WatchWorkflow.
+	WorkflowEvent struct {
+		// Type of the event.
+		Type WorkflowEventType
+
+		// Execution is the run of the workflow the event belongs to.
+		Execution WorkflowExecution
+
+		// EventID and Timestamp of the underlying history event.
+		EventID   int64
+		Timestamp time.Time
+
+		// Name is the activity type for activity events, the signal name for signal events and the
+		// workflow type for workflow and child workflow events.
+		Name string
+
+		// ID is the activity ID for activity events, the timer ID for timer events and the workflow ID of the
+		// child for child workflow events.
+		ID string
+
+		// Payload holds, decoded through the DataConverter of the client:
+		//  - the input of WorkflowStarted, ActivityScheduled, SignalReceived and ChildWorkflowInitiated events.
+		//  - the result of ActivityClosed, ChildWorkflowClosed and WorkflowClosed events if Err is nil.
+		// Payload is nil for other events.
+		Payload encoded.Values
+
+		// Err is the error the activity, child workflow or workflow closed with, nil if it completed successfully.
+		// For WorkflowEventTypeError events, Err is the error that stopped the watch.
+		Err error
+
+		// HistoryEvent is the raw history event, nil for WorkflowEventTypeError events.
+		HistoryEvent *s.HistoryEvent
+	}
+
+	// workflowWatcher delivers the decoded events of a workflow to the channel returned by Client.WatchWorkflow.
+	workflowWatcher struct {
+		client     *workflowClient
+		workflowID string
+		eventCh    chan WorkflowEvent
+	}
+)
+
+const (
+	// WorkflowEventTypeWorkflowStarted is delivered when a run of the workflow starts.
+	WorkflowEventTypeWorkflowStarted WorkflowEventType = iota
+	// WorkflowEventTypeActivityScheduled is delivered when the workflow schedules an activity.
+	WorkflowEventTypeActivityScheduled
+	// WorkflowEventTypeActivityClosed is delivered when an activity completes, fails, times out or is canceled.
+	WorkflowEventTypeActivityClosed
+	// WorkflowEventTypeSignalReceived is delivered when the workflow receives a signal.
+	WorkflowEventTypeSignalReceived
+	// WorkflowEventTypeTimerStarted is delivered when the workflow starts a timer.
+	WorkflowEventTypeTimerStarted
+	// WorkflowEventTypeTimerFired is delivered when a timer fires.
+	WorkflowEventTypeTimerFired
+	// WorkflowEventTypeTimerCanceled is delivered when a timer is canceled.
+	WorkflowEventTypeTimerCanceled
+	// WorkflowEventTypeChildWorkflowInitiated is delivered when the workflow requests a child workflow to start.
+	WorkflowEventTypeChildWorkflowInitiated
+	// WorkflowEventTypeChildWorkflowStarted is delivered when a child workflow is started.
+	WorkflowEventTypeChildWorkflowStarted
+	// WorkflowEventTypeChildWorkflowClosed is delivered when a child workflow fails to start, completes, fails,
+	// times out, is canceled or is terminated.
+	WorkflowEventTypeChildWorkflowClosed
+	// WorkflowEventTypeWorkflowContinuedAsNew is delivered when a run of the workflow continues as new. The watch
+	// goes on with the new run.
+	WorkflowEventTypeWorkflowContinuedAsNew
+	// WorkflowEventTypeWorkflowClosed is delivered when the workflow completes, fails, times out, is canceled or is
+	// terminated. It is the last event delivered.
+	WorkflowEventTypeWorkflowClosed
+	// WorkflowEventTypeError is delivered when the history of the workflow can not be fetched. It is the last
+	// event delivered.
+	WorkflowEventTypeError
+)
+
+func (t WorkflowEventType) String() string {
+	switch t {
+	case WorkflowEventTypeWorkflowStarted:
+		return "WorkflowStarted"
+	case WorkflowEventTypeActivityScheduled:
+		return "ActivityScheduled"
+	case WorkflowEventTypeActivityClosed:
+		return "ActivityClosed"
+	case WorkflowEventTypeSignalReceived:
+		return "SignalReceived"
+	case WorkflowEventTypeTimerStarted:
+		return "TimerStarted"
+	case WorkflowEventTypeTimerFired:
+		return "TimerFired"
+	case WorkflowEventTypeTimerCanceled:
+		return "TimerCanceled"
+	case WorkflowEventTypeChildWorkflowInitiated:
+		return "ChildWorkflowInitiated"
+	case WorkflowEventTypeChildWorkflowStarted:
+		return "ChildWorkflowStarted"
+	case WorkflowEventTypeChildWorkflowClosed:
+		return "ChildWorkflowClosed"
+	case WorkflowEventTypeWorkflowContinuedAsNew:
+		return "WorkflowContinuedAsNew"
+	case WorkflowEventTypeWorkflowClosed:
+		return "WorkflowClosed"
+	case WorkflowEventTypeError:
+		return "Error"
+	}
+	return fmt.Sprintf("WorkflowEventType(%d)", int(t))
+}
+
+// WatchWorkflow returns a channel of the decoded history events of a workflow.
+func (wc *workflowClient) WatchWorkflow(ctx context.Context, workflowID string, runID string) <-chan WorkflowEvent {
+	w := &workflowWatcher{
+		client:     wc,
+		workflowID: workflowID,
+		eventCh:    make(chan WorkflowEvent),
+	}
+	go w.watch(ctx, runID)
+	return w.eventCh
+}
+
+func (w *workflowWatcher) watch(ctx context.Context, runID string) {
+	defer close(w.eventCh)
+
+	if runID == "" {
+		// resolve the current run, so that the watch follows this very run through continue as new
+		response, err := w.client.intercepted().DescribeWorkflowExecution(ctx, w.workflowID, "")
+		if err != nil {
+			w.sendError(ctx, WorkflowExecution{ID: w.workflowID}, err)
+			return
+		}
+		runID = response.WorkflowExecutionInfo.Execution.GetRunId()
+	}
+
+	for runID != "" {
+		nextRunID, err := w.watchRun(ctx, runID)
+		if err != nil {
+			w.sendError(ctx, WorkflowExecution{ID: w.workflowID, RunID: runID}, err)
+			return
+		}
+		runID = nextRunID
+	}
+}
+
+// watchRun delivers the events of a single run and returns the ID of the run it continued as, if any.
+func (w *workflowWatcher) watchRun(ctx context.Context, runID string) (string, error) {
+	execution := WorkflowExecution{ID: w.workflowID, RunID: runID}
+	scheduledActivities := make(map[int64]*s.ActivityTaskScheduledEventAttributes)
+	var nextRunID string
+
+	iter := w.client.intercepted().GetWorkflowHistory(ctx, w.workflowID, runID, true, s.HistoryEventFilterTypeAllEvent)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return "", err
+		}
+		switch event.GetEventType() {
+		case s.EventTypeActivityTaskScheduled:
+			scheduledActivities[event.GetEventId()] = event.ActivityTaskScheduledEventAttributes
+		case s.EventTypeWorkflowExecutionContinuedAsNew:
+			nextRunID = event.WorkflowExecutionContinuedAsNewEventAttributes.GetNewExecutionRunId()
+		}
+
+		workflowEvent, ok := w.decode(execution, event, scheduledActivities)
+		if !ok {
+			continue
+		}
+		if !w.send(ctx, workflowEvent) {
+			return "", nil
+		}
+	}
+	return nextRunID, nil
+}
+
+func (w *workflowWatcher) send(ctx context.Context, event WorkflowEvent) bool {
+	select {
+	case w.eventCh <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendError delivers the error that stopped the watch, unless it stopped because ctx is done.
+func (w *workflowWatcher) sendError(ctx context.Context, execution WorkflowExecution, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	w.send(ctx, WorkflowEvent{Type: WorkflowEventTypeError, Execution: execution, Err: err})
+}
+
+// decode converts a history event into a WorkflowEvent, it returns false for events which are not delivered.
+func (w *workflowWatcher) decode(
+	execution WorkflowExecution,
+	event *s.HistoryEvent,
+	scheduledActivities map[int64]*s.ActivityTaskScheduledEventAttributes,
+) (WorkflowEvent, bool) {
+	dc := w.client.dataConverter
+	e := WorkflowEvent{
+		Execution:    execution,
+		EventID:      event.GetEventId(),
+		Timestamp:    time.Unix(0, event.GetTimestamp()),
+		HistoryEvent: event,
+	}
+	activityClosed := func(scheduledEventID int64) {
+		e.Type = WorkflowEventTypeActivityClosed
+		if scheduled, ok := scheduledActivities[scheduledEventID]; ok {
+			e.Name = scheduled.ActivityType.GetName()
+			e.ID = scheduled.GetActivityId()
+		}
+	}
+	childWorkflowClosed := func(childExecution *s.WorkflowExecution, workflowType *s.WorkflowType) {
+		e.Type = WorkflowEventTypeChildWorkflowClosed
+		e.Name = workflowType.GetName()
+		e.ID = childExecution.GetWorkflowId()
+	}
+
+	switch event.GetEventType() {
+	case s.EventTypeWorkflowExecutionStarted:
+		attributes := event.WorkflowExecutionStartedEventAttributes
+		e.Type = WorkflowEventTypeWorkflowStarted
+		e.Name = attributes.WorkflowType.GetName()
+		e.Payload = newEncodedValues(contextEnvelopePayload(attributes.Input), dc)
+
+	case s.EventTypeActivityTaskScheduled:
+		attributes := event.ActivityTaskScheduledEventAttributes
+		e.Type = WorkflowEventTypeActivityScheduled
+		e.Name = attributes.ActivityType.GetName()
+		e.ID = attributes.GetActivityId()
+		e.Payload = newEncodedValues(contextEnvelopePayload(attributes.Input), dc)
+	case s.EventTypeActivityTaskCompleted:
+		attributes := event.ActivityTaskCompletedEventAttributes
+		activityClosed(attributes.GetScheduledEventId())
+		e.Payload = newEncodedValues(attributes.Result, dc)
+	case s.EventTypeActivityTaskFailed:
+		attributes := event.ActivityTaskFailedEventAttributes
+		activityClosed(attributes.GetScheduledEventId())
+		e.Err = constructError(attributes.GetReason(), attributes.Details, dc)
+	case s.EventTypeActivityTaskTimedOut:
+		attributes := event.ActivityTaskTimedOutEventAttributes
+		activityClosed(attributes.GetScheduledEventId())
+		if attributes.GetTimeoutType() == s.TimeoutTypeHeartbeat {
+			e.Err = NewHeartbeatTimeoutError(newEncodedValues(attributes.Details, dc))
+		} else {
+			e.Err = NewTimeoutError(attributes.GetTimeoutType())
+		}
+	case s.EventTypeActivityTaskCanceled:
+		attributes := event.ActivityTaskCanceledEventAttributes
+		activityClosed(attributes.GetScheduledEventId())
+		e.Err = NewCanceledError(newEncodedValues(attributes.Details, dc))
+
+	case s.EventTypeWorkflowExecutionSignaled:
+		attributes := event.WorkflowExecutionSignaledEventAttributes
+		e.Type = WorkflowEventTypeSignalReceived
+		e.Name = attributes.GetSignalName()
+		e.Payload = newEncodedValues(attributes.Input, dc)
+
+	case s.EventTypeTimerStarted:
+		e.Type = WorkflowEventTypeTimerStarted
+		e.ID = event.TimerStartedEventAttributes.GetTimerId()
+	case s.EventTypeTimerFired:
+		e.Type = WorkflowEventTypeTimerFired
+		e.ID = event.TimerFiredEventAttributes.GetTimerId()
+	case s.EventTypeTimerCanceled:
+		e.Type = WorkflowEventTypeTimerCanceled
+		e.ID = event.TimerCanceledEventAttributes.GetTimerId()
+
+	case s.EventTypeStartChildWorkflowExecutionInitiated:
+		attributes := event.StartChildWorkflowExecutionInitiatedEventAttributes
+		e.Type = WorkflowEventTypeChildWorkflowInitiated
+		e.Name = attributes.WorkflowType.GetName()
+		e.ID = attributes.GetWorkflowId()
+		e.Payload = newEncodedValues(contextEnvelopePayload(attributes.Input), dc)
+	case s.EventTypeStartChildWorkflowExecutionFailed:
+		attributes := event.StartChildWorkflowExecutionFailedEventAttributes
+		e.Type = WorkflowEventTypeChildWorkflowClosed
+		e.Name = attributes.WorkflowType.GetName()
+		e.ID = attributes.GetWorkflowId()
+		e.Err = &s.WorkflowExecutionAlreadyStartedError{
+			Message: common.StringPtr("Workflow execution already started"),
+		}
+	case s.EventTypeChildWorkflowExecutionStarted:
+		attributes := event.ChildWorkflowExecutionStartedEventAttributes
+		e.Type = WorkflowEventTypeChildWorkflowStarted
+		e.Name = attributes.WorkflowType.GetName()
+		e.ID = attributes.WorkflowExecution.GetWorkflowId()
+	case s.EventTypeChildWorkflowExecutionCompleted:
+		attributes := event.ChildWorkflowExecutionCompletedEventAttributes
+		childWorkflowClosed(attributes.WorkflowExecution, attributes.WorkflowType)
+		e.Payload = newEncodedValues(attributes.Result, dc)
+	case s.EventTypeChildWorkflowExecutionFailed:
+		attributes := event.ChildWorkflowExecutionFailedEventAttributes
+		childWorkflowClosed(attributes.WorkflowExecution, attributes.WorkflowType)
+		e.Err = constructError(attributes.GetReason(), attributes.Details, dc)
+	case s.EventTypeChildWorkflowExecutionTimedOut:
+		attributes := event.ChildWorkflowExecutionTimedOutEventAttributes
+		childWorkflowClosed(attributes.WorkflowExecution, attributes.WorkflowType)
+		e.Err = NewTimeoutError(attributes.GetTimeoutType())
+	case s.EventTypeChildWorkflowExecutionCanceled:
+		attributes := event.ChildWorkflowExecutionCanceledEventAttributes
+		childWorkflowClosed(attributes.WorkflowExecution, attributes.WorkflowType)
+		e.Err = NewCanceledError(newEncodedValues(attributes.Details, dc))
+	case s.EventTypeChildWorkflowExecutionTerminated:
+		attributes := event.ChildWorkflowExecutionTerminatedEventAttributes
+		childWorkflowClosed(attributes.WorkflowExecution, attributes.WorkflowType)
+		e.Err = newTerminatedError()
+
+	case s.EventTypeWorkflowExecutionContinuedAsNew:
+		attributes := event.WorkflowExecutionContinuedAsNewEventAttributes
+		e.Type = WorkflowEventTypeWorkflowContinuedAsNew
+		e.Name = attributes.WorkflowType.GetName()
+		e.Payload = newEncodedValues(contextEnvelopePayload(attributes.Input), dc)
+	case s.EventTypeWorkflowExecutionCompleted:
+		e.Type = WorkflowEventTypeWorkflowClosed
+		e.Payload = newEncodedValues(event.WorkflowExecutionCompletedEventAttributes.Result, dc)
+	case s.EventTypeWorkflowExecutionFailed:
+		attributes := event.WorkflowExecutionFailedEventAttributes
+		e.Type = WorkflowEventTypeWorkflowClosed
+		e.Err = constructError(attributes.GetReason(), attributes.Details, dc)
+	case s.EventTypeWorkflowExecutionTimedOut:
+		e.Type = WorkflowEventTypeWorkflowClosed
+		e.Err = NewTimeoutError(event.WorkflowExecutionTimedOutEventAttributes.GetTimeoutType())
+	case s.EventTypeWorkflowExecutionCanceled:
+		e.Type = WorkflowEventTypeWorkflowClosed
+		e.Err = NewCanceledError(newEncodedValues(event.WorkflowExecutionCanceledEventAttributes.Details, dc))
+	case s.EventTypeWorkflowExecutionTerminated:
+		e.Type = WorkflowEventTypeWorkflowClosed
+		e.Err = newTerminatedError()
+
+	default:
+		return e, false
+	}
+	return e, true
+}
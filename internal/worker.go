@@ -28,6 +28,7 @@ import (
 
 	"encoding/json"
 	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
 	"github.com/uber-go/tally"
 	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
@@ -42,6 +43,26 @@ import (
 type (
 	// Worker represents objects that can be started and stopped.
 	Worker interface {
+		// RegisterWorkflow registers a workflow function with this worker only. Registrations done with the package
+		// level RegisterWorkflow are visible to all workers, a worker registration overrides a global one of the same
+		// name. This method calls panic if workflowFunc doesn't comply with the expected format or is already
+		// registered with this worker.
+		RegisterWorkflow(workflowFunc interface{})
+		// RegisterWorkflowWithOptions registers a workflow function with this worker only, see RegisterWorkflow.
+		RegisterWorkflowWithOptions(workflowFunc interface{}, options RegisterWorkflowOptions)
+		// RegisterActivity registers an activity function with this worker only. Registrations done with the package
+		// level RegisterActivity are visible to all workers, a worker registration overrides a global one of the same
+		// name. This method calls panic if activityFunc doesn't comply with the expected format or is already
+		// registered with this worker.
+		RegisterActivity(activityFunc interface{})
+		// RegisterActivityWithOptions registers an activity function with this worker only, see RegisterActivity.
+		RegisterActivityWithOptions(activityFunc interface{}, options RegisterActivityOptions)
+		// RegisterDynamicWorkflow registers a function that executes the workflow types this worker has no
+		// registration for. It overrides the one registered with the package level RegisterDynamicWorkflow.
+		RegisterDynamicWorkflow(workflowFunc DynamicWorkflowFunc)
+		// RegisterDynamicActivity registers a function that executes the activity types this worker has no
+		// registration for. It overrides the one registered with the package level RegisterDynamicActivity.
+		RegisterDynamicActivity(activityFunc DynamicActivityFunc)
 		// Start starts the worker in a non-blocking fashion
 		Start() error
 		// Run is a blocking start and cleans up resources when killed
@@ -101,7 +122,9 @@ type (
 		WorkerDecisionTasksPerSecond float64
 
 		// Optional: if the activities need auto heart beating for those activities
-		// by the framework
+		// by the framework. Activities with a HeartbeatTimeout are heartbeated with the details of their last
+		// heartbeat while they run, so long blocking calls do not time out. Cancellation is still delivered through
+		// the activity context.
 		// default: false not to heartbeat.
 		AutoHeartBeat bool
 
@@ -159,6 +182,29 @@ type (
 		// Optional: Sets DataConverter to customize serialization/deserialization of arguments in Cadence
 		// default: defaultDataConverter, an combination of thriftEncoder and jsonEncoder
 		DataConverter encoded.DataConverter
+
+		// Optional: WorkflowInterceptors wrap the workflow function and the calls it makes to the framework, see
+		// WorkflowInterceptor. The first interceptor is the outermost one, it sees a call first and its result last.
+		// default: no interceptors
+		WorkflowInterceptors []WorkflowInterceptor
+
+		// Optional: ActivityInterceptors wrap the execution of every activity and local activity of the worker, see
+		// ActivityInterceptor. The first interceptor is the outermost one, it sees a call first and its result last.
+		// default: no interceptors
+		ActivityInterceptors []ActivityInterceptor
+
+		// Optional: ContextPropagators restore the values propagated to workflows and activities, and propagate the
+		// values of workflows to their activities, local activities and child workflows, see ContextPropagator.
+		// default: no propagators
+		ContextPropagators []ContextPropagator
+
+		// Optional: Tracer emits a span for every decision task processed by the worker, for every activity, local
+		// activity and child workflow scheduled by its workflows, and for every activity execution. The spans are
+		// propagated, so the spans of an activity are children of the span of the workflow call that scheduled it.
+		// The span of a workflow call is finished once the call is scheduled, the span of the activity execution
+		// covers its run. Workflow calls are not traced again when a workflow is replayed.
+		// default: no tracing
+		Tracer opentracing.Tracer
 	}
 )
 
@@ -213,7 +259,7 @@ func ReplayWorkflowExecution(ctx context.Context, service workflowserviceclient.
 		return err
 	}
 
-	return replayWorkflowHistory(logger, service, domain, hResponse.History)
+	return replayWorkflowHistory(logger, service, domain, hResponse.History, getHostEnvironment())
 }
 
 // ReplayWorkflowHistory executes a single decision task for the given history.
@@ -231,7 +277,7 @@ func ReplayWorkflowHistory(logger *zap.Logger, history *shared.History) error {
 
 	domain := "ReplayDomain"
 
-	return replayWorkflowHistory(logger, service, domain, history)
+	return replayWorkflowHistory(logger, service, domain, history, getHostEnvironment())
 }
 
 // ReplayWorkflowHistoryFromJSONFile executes a single decision task for the given json history file.
@@ -255,60 +301,112 @@ func ReplayWorkflowHistoryFromJSONFile(logger *zap.Logger, jsonfileName string)
 
 	domain := "ReplayDomain"
 
-	return replayWorkflowHistory(logger, service, domain, history)
+	return replayWorkflowHistory(logger, service, domain, history, getHostEnvironment())
+}
+
+// ReplayWorkerWorkflowHistory executes a single decision task for the given history, like ReplayWorkflowHistory, with
+// the workflows registered with the worker in addition to the global registrations.
+// The logger is an optional parameter. Defaults to the noop logger.
+func ReplayWorkerWorkflowHistory(worker Worker, logger *zap.Logger, history *shared.History) error {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	testReporter := logger.Sugar()
+	controller := gomock.NewController(testReporter)
+	service := workflowservicetest.NewMockClient(controller)
+
+	domain := "ReplayDomain"
+
+	return replayWorkflowHistory(logger, service, domain, history, getWorkerRegistry(worker))
+}
+
+func replayWorkflowHistory(logger *zap.Logger, service workflowserviceclient.Interface, domain string, history *shared.History,
+	hostEnv *hostEnvImpl) error {
+	execution := &shared.WorkflowExecution{
+		RunId:      common.StringPtr(uuid.NewUUID().String()),
+		WorkflowId: common.StringPtr("ReplayId"),
+	}
+	task, err := newReplayDecisionTask(execution, history)
+	if err != nil {
+		return err
+	}
+	params := workerExecutionParameters{
+		TaskList: "ReplayTaskList",
+		Identity: "replayID",
+		Logger:   logger,
+	}
+	_, err = processReplayDecisionTask(service, domain, params, task, hostEnv)
+	return err
+}
+
+// queryWorkflowHistory replays the given history of a workflow execution and runs the query against the final state.
+// It returns the encoded query result, or QueryFailedError if the query handler failed.
+func queryWorkflowHistory(service workflowserviceclient.Interface, domain string, params workerExecutionParameters,
+	execution *shared.WorkflowExecution, history *shared.History, query *shared.WorkflowQuery, hostEnv *hostEnvImpl) ([]byte, error) {
+	task, err := newReplayDecisionTask(execution, history)
+	if err != nil {
+		return nil, err
+	}
+	task.Query = query
+	response, err := processReplayDecisionTask(service, domain, params, task, hostEnv)
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, ok := response.(*shared.RespondQueryTaskCompletedRequest)
+	if !ok {
+		return nil, errors.New("unexpected response when replaying workflow history for query")
+	}
+	if queryResponse.GetCompletedType() != shared.QueryTaskCompletedTypeCompleted {
+		return nil, &shared.QueryFailedError{Message: queryResponse.GetErrorMessage()}
+	}
+	return queryResponse.QueryResult, nil
 }
 
-func replayWorkflowHistory(logger *zap.Logger, service workflowserviceclient.Interface, domain string, history *shared.History) error {
-	taskList := "ReplayTaskList"
+func newReplayDecisionTask(execution *shared.WorkflowExecution, history *shared.History) (*shared.PollForDecisionTaskResponse, error) {
 	events := history.Events
 	if events == nil {
-		return errors.New("empty events")
+		return nil, errors.New("empty events")
 	}
 	if len(events) < 3 {
-		return errors.New("at least 3 events expected in the history")
+		return nil, errors.New("at least 3 events expected in the history")
 	}
 	first := events[0]
 	if first.GetEventType() != shared.EventTypeWorkflowExecutionStarted {
-		return errors.New("first event is not WorkflowExecutionStarted")
+		return nil, errors.New("first event is not WorkflowExecutionStarted")
 	}
 	attr := first.WorkflowExecutionStartedEventAttributes
 	if attr == nil {
-		return errors.New("corrupted WorkflowExecutionStarted")
-	}
-	workflowType := attr.WorkflowType
-	execution := &shared.WorkflowExecution{
-		RunId:      common.StringPtr(uuid.NewUUID().String()),
-		WorkflowId: common.StringPtr("ReplayId"),
+		return nil, errors.New("corrupted WorkflowExecutionStarted")
 	}
-	task := &shared.PollForDecisionTaskResponse{
+	return &shared.PollForDecisionTaskResponse{
 		Attempt:                common.Int64Ptr(0),
 		TaskToken:              []byte("ReplayTaskToken"),
-		WorkflowType:           workflowType,
+		WorkflowType:           attr.WorkflowType,
 		WorkflowExecution:      execution,
 		History:                history,
 		PreviousStartedEventId: common.Int64Ptr(math.MaxInt64),
-	}
-	if logger == nil {
-		logger = zap.NewNop()
+	}, nil
+}
+
+func processReplayDecisionTask(service workflowserviceclient.Interface, domain string, params workerExecutionParameters,
+	task *shared.PollForDecisionTaskResponse, hostEnv *hostEnvImpl) (interface{}, error) {
+	if params.Logger == nil {
+		params.Logger = zap.NewNop()
 	}
 
 	metricScope := tally.NoopScope
 	iterator := &historyIteratorImpl{
 		nextPageToken: task.NextPageToken,
 		execution:     task.WorkflowExecution,
-		domain:        "ReplayDomain",
+		domain:        domain,
 		service:       service,
 		metricsScope:  metricScope,
 		maxEventID:    task.GetStartedEventId(),
 	}
-	params := workerExecutionParameters{
-		TaskList: taskList,
-		Identity: "replayID",
-		Logger:   logger,
-	}
-	taskHandler := newWorkflowTaskHandler(domain, params, nil, getHostEnvironment())
-	_, _, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task, historyIterator: iterator})
-	return err
+	taskHandler := newWorkflowTaskHandler(domain, params, nil, hostEnv)
+	response, _, err := taskHandler.ProcessWorkflowTask(&workflowTask{task: task, historyIterator: iterator})
+	return response, err
 }
 
 func extractHistoryFromFile(jsonfileName string) (*shared.History, error) {
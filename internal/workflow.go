@@ -23,6 +23,7 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -80,6 +81,60 @@ type (
 		Select(ctx Context)
 	}
 
+	// WaitGroup must be used instead of sync.WaitGroup by workflow code to wait for a collection of workflow
+	// goroutines to finish. Use workflow.NewWaitGroup(ctx) method to create a WaitGroup instance.
+	WaitGroup interface {
+		// Add adds delta, which may be negative, to the counter. It panics if the counter becomes negative.
+		Add(delta int)
+		// Done decrements the counter by one.
+		Done()
+		// Wait blocks until the counter is zero. It returns ctx.Err() if ctx is canceled first.
+		Wait(ctx Context) error
+	}
+
+	// Mutex must be used instead of sync.Mutex by workflow code to make workflow goroutines take turns.
+	// Use workflow.NewMutex(ctx) method to create a Mutex instance. The goroutines waiting for a Mutex lock it in
+	// the order they called Lock.
+	Mutex interface {
+		// Lock blocks until the mutex is locked by the caller. It returns ctx.Err() if ctx is canceled first, the
+		// mutex isn't locked by the caller then.
+		Lock(ctx Context) error
+		// TryLock locks the mutex if it is unlocked and nobody waits for it, and returns whether it did.
+		TryLock() bool
+		// Unlock unlocks the mutex. It panics if the mutex is not locked.
+		Unlock()
+	}
+
+	// Semaphore must be used instead of a buffered channel by workflow code to bound the number of workflow
+	// goroutines doing something at the same time. Use workflow.NewSemaphore(ctx, n) method to create a Semaphore
+	// instance. The goroutines waiting for a Semaphore acquire it in the order they called Acquire.
+	Semaphore interface {
+		// Acquire blocks until one of the permits of the semaphore is acquired by the caller. It returns ctx.Err()
+		// if ctx is canceled first, no permit is acquired then.
+		Acquire(ctx Context) error
+		// TryAcquire acquires a permit if one is available and nobody waits for one, and returns whether it did.
+		TryAcquire() bool
+		// Release releases a permit. It panics if no permit is acquired.
+		Release()
+	}
+
+	// Group runs a collection of workflow goroutines working on subtasks of a common task, like errgroup.Group.
+	// Use workflow.NewGroup(ctx) method to create a Group instance.
+	Group interface {
+		// Go calls f in a new workflow goroutine with the context of the group. The context of the group is canceled
+		// the first time a function returns an error. With a limit, the goroutine waits for one of the running
+		// functions to return before calling f when the limit is reached.
+		Go(f func(ctx Context) error)
+
+		// SetLimit limits the number of functions running at the same time to n, a value of zero or less removes the
+		// limit. It must not be called while functions are running.
+		SetLimit(n int)
+
+		// Wait blocks until all the functions started with Go have returned, then cancels the context of the group
+		// and returns the first error they returned, if any. It returns ctx.Err() if ctx is canceled first.
+		Wait(ctx Context) error
+	}
+
 	// Future represents the result of an asynchronous computation.
 	Future interface {
 		// Get blocks until the future is ready. When ready it either returns non nil error or assigns result value to
@@ -221,6 +276,10 @@ type RegisterWorkflowOptions struct {
 	Name string
 }
 
+// DynamicWorkflowFunc is the function type of a dynamic workflow, see RegisterDynamicWorkflow.
+// It receives the name of the workflow type being executed and its arguments as encoded values.
+type DynamicWorkflowFunc func(ctx Context, workflowType string, args encoded.Values) (interface{}, error)
+
 // RegisterWorkflow - registers a workflow function with the framework.
 // A workflow takes a cadence context and input and returns a (result, error) or just error.
 // Examples:
@@ -255,6 +314,24 @@ func RegisterWorkflowWithOptions(workflowFunc interface{}, opts RegisterWorkflow
 	}
 }
 
+// RegisterDynamicWorkflow registers a function that executes every workflow type without an exact
+// registration, instead of failing the decision task. It is used as:
+//	func dynamicWorkflow(ctx workflow.Context, workflowType string, args encoded.Values) (interface{}, error) {
+//		var input string
+//		if err := args.Get(&input); err != nil {
+//			return nil, err
+//		}
+//		...
+//	}
+// Only one dynamic workflow can be registered, this method calls panic if one is already registered.
+func RegisterDynamicWorkflow(workflowFunc DynamicWorkflowFunc) {
+	thImpl := getHostEnvironment()
+	err := thImpl.RegisterDynamicWorkflow(workflowFunc)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // NewChannel create new Channel instance
 func NewChannel(ctx Context) Channel {
 	state := getState(ctx)
@@ -295,6 +372,66 @@ func NewNamedSelector(ctx Context, name string) Selector {
 	return &selectorImpl{name: name}
 }
 
+// NewWaitGroup creates a new WaitGroup instance.
+func NewWaitGroup(ctx Context) WaitGroup {
+	state := getState(ctx)
+	state.dispatcher.syncSequence++
+	return NewNamedWaitGroup(ctx, fmt.Sprintf("waitgroup-%v", state.dispatcher.syncSequence))
+}
+
+// NewNamedWaitGroup creates a new WaitGroup instance with a given human readable name.
+// Name appears in stack traces that are blocked on this WaitGroup.
+func NewNamedWaitGroup(ctx Context, name string) WaitGroup {
+	return &waitGroupImpl{name: name}
+}
+
+// NewMutex creates a new Mutex instance.
+func NewMutex(ctx Context) Mutex {
+	state := getState(ctx)
+	state.dispatcher.syncSequence++
+	return NewNamedMutex(ctx, fmt.Sprintf("mutex-%v", state.dispatcher.syncSequence))
+}
+
+// NewNamedMutex creates a new Mutex instance with a given human readable name.
+// Name appears in stack traces that are blocked on this Mutex.
+func NewNamedMutex(ctx Context, name string) Mutex {
+	return &mutexImpl{semaphore: semaphoreImpl{name: name, size: 1}}
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits. It panics if n is not positive.
+func NewSemaphore(ctx Context, n int) Semaphore {
+	state := getState(ctx)
+	state.dispatcher.syncSequence++
+	return NewNamedSemaphore(ctx, fmt.Sprintf("semaphore-%v", state.dispatcher.syncSequence), n)
+}
+
+// NewNamedSemaphore creates a new Semaphore instance with n permits and a given human readable name.
+// Name appears in stack traces that are blocked on this Semaphore. It panics if n is not positive.
+func NewNamedSemaphore(ctx Context, name string, n int) Semaphore {
+	if n <= 0 {
+		panic(fmt.Sprintf("semaphore %s: the number of permits must be positive, got %v", name, n))
+	}
+	return &semaphoreImpl{name: name, size: n}
+}
+
+// NewGroup creates a new Group instance. The context of the group is derived from ctx, it is canceled when a function
+// started with Group.Go fails or when Group.Wait returns.
+//  g := workflow.NewGroup(ctx)
+//  for _, file := range files {
+//  	file := file
+//  	g.Go(func(ctx workflow.Context) error {
+//  		return workflow.ExecuteActivity(ctx, ProcessFileActivity, file).Get(ctx, nil)
+//  	})
+//  }
+//  err := g.Wait(ctx)
+func NewGroup(ctx Context) Group {
+	state := getState(ctx)
+	state.dispatcher.syncSequence++
+	name := fmt.Sprintf("group-%v", state.dispatcher.syncSequence)
+	groupCtx, cancel := WithCancel(ctx)
+	return &groupImpl{name: name, ctx: groupCtx, cancel: cancel, running: NewNamedWaitGroup(ctx, name)}
+}
+
 // Go creates a new coroutine. It has similar semantic to goroutine in a context of the workflow.
 func Go(ctx Context, f func(ctx Context)) {
 	state := getState(ctx)
@@ -315,6 +452,70 @@ func NewFuture(ctx Context) (Future, Settable) {
 	return impl, impl
 }
 
+// AllOf returns a Future that is ready when all the futures are ready, or as soon as one of them fails. Its error is
+// the error of the first future that failed, and its value is nil: get the results from the futures themselves. The
+// futures still pending when one fails are not canceled, cancel their context to cancel them.
+//  err := workflow.AllOf(ctx, f1, f2, f3).Get(ctx, nil)
+func AllOf(ctx Context, futures ...Future) Future {
+	future, settable := NewFuture(ctx)
+	pending := len(futures)
+	if pending == 0 {
+		settable.Set(nil, nil)
+		return future
+	}
+	for _, f := range futures {
+		onReady(f, func(v interface{}, err error) {
+			if future.IsReady() {
+				return
+			}
+			pending--
+			if err != nil || pending == 0 {
+				settable.Set(nil, err)
+			}
+		})
+	}
+	return future
+}
+
+// AnyOf returns a Future that is ready as soon as one of the futures is ready, with the value and the error of that
+// future. Use IsReady on the futures to find out which one it is. The Future is ready immediately if there are no
+// futures.
+func AnyOf(ctx Context, futures ...Future) Future {
+	future, settable := NewFuture(ctx)
+	if len(futures) == 0 {
+		settable.Set(nil, nil)
+		return future
+	}
+	for _, f := range futures {
+		onReady(f, func(v interface{}, err error) {
+			if !future.IsReady() {
+				settable.Set(v, err)
+			}
+		})
+	}
+	return future
+}
+
+// Then returns a Future for the result of fn, which is called in a new workflow goroutine once future is ready. fn
+// gets the result of future with f.Get, which doesn't block then, and can map it to a new value or error, or make
+// more workflow calls.
+//  lengthFuture := workflow.Then(ctx, workflow.ExecuteActivity(ctx, DownloadActivity, url),
+//  	func(ctx workflow.Context, f workflow.Future) (interface{}, error) {
+//  		var content string
+//  		if err := f.Get(ctx, &content); err != nil {
+//  			return nil, err
+//  		}
+//  		return len(content), nil
+//  	})
+func Then(ctx Context, future Future, fn func(ctx Context, f Future) (interface{}, error)) Future {
+	result, settable := NewFuture(ctx)
+	Go(ctx, func(ctx Context) {
+		_ = future.Get(ctx, nil)
+		settable.Set(fn(ctx, future))
+	})
+	return result
+}
+
 // ExecuteActivity requests activity execution in the context of a workflow.
 // Context can be used to pass the settings for this activity.
 // For example: task list that this need to be routed, timeouts that need to be configured.
@@ -339,10 +540,17 @@ func NewFuture(ctx Context) (Future, Settable) {
 //
 // ExecuteActivity returns Future with activity result or failure.
 func ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	return getWorkflowOperations(ctx).ExecuteActivity(ctx, activity, args...)
+}
+
+func executeActivity(ctx Context, activity interface{}, args ...interface{}) Future {
 	// Validate type and its arguments.
 	dataConverter := getDataConverterFromWorkflowContext(ctx)
 	future, settable := newDecodeFuture(ctx, activity)
-	activityType, input, err := getValidatedActivityFunction(activity, args, dataConverter)
+	activityType, input, err := getValidatedActivityFunction(activity, args, dataConverter, getWorkflowEnvironment(ctx).GetRegistry())
+	if err == nil {
+		input, err = wrapWorkflowContextEnvelope(ctx, input)
+	}
 	if err != nil {
 		settable.Set(nil, err)
 		return future
@@ -419,6 +627,10 @@ func ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Fut
 //
 // ExecuteLocalActivity returns Future with local activity result or failure.
 func ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}) Future {
+	return getWorkflowOperations(ctx).ExecuteLocalActivity(ctx, activity, args...)
+}
+
+func executeLocalActivity(ctx Context, activity interface{}, args ...interface{}) Future {
 	future, settable := newDecodeFuture(ctx, activity)
 
 	if err := validateFunctionArgs(activity, args, false); err != nil {
@@ -430,6 +642,11 @@ func ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}
 		settable.Set(nil, err)
 		return future
 	}
+	header, err := injectWorkflowContext(ctx, getWorkflowEnvironment(ctx).GetContextPropagators())
+	if err != nil {
+		settable.Set(nil, err)
+		return future
+	}
 
 	params := &executeLocalActivityParams{
 		localActivityOptions: *options,
@@ -439,6 +656,7 @@ func ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}
 		WorkflowInfo:         GetWorkflowInfo(ctx),
 		DataConverter:        getDataConverterFromWorkflowContext(ctx),
 		ScheduledTime:        Now(ctx), // initial scheduled time
+		Header:               header,
 	}
 
 	Go(ctx, func(ctx Context) {
@@ -525,6 +743,10 @@ func scheduleLocalActivity(ctx Context, params *executeLocalActivityParams) Futu
 // error CanceledError.
 // ExecuteChildWorkflow returns ChildWorkflowFuture.
 func ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interface{}) ChildWorkflowFuture {
+	return getWorkflowOperations(ctx).ExecuteChildWorkflow(ctx, childWorkflow, args...)
+}
+
+func executeChildWorkflow(ctx Context, childWorkflow interface{}, args ...interface{}) ChildWorkflowFuture {
 	mainFuture, mainSettable := newDecodeFuture(ctx, childWorkflow)
 	executionFuture, executionSettable := NewFuture(ctx)
 	result := &childWorkflowFutureImpl{
@@ -532,7 +754,10 @@ func ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interf
 		executionFuture:  executionFuture.(*futureImpl),
 	}
 	dc := getWorkflowEnvOptions(ctx).dataConverter
-	wfType, input, err := getValidatedWorkflowFunction(childWorkflow, args, dc)
+	wfType, input, err := getValidatedWorkflowFunction(childWorkflow, args, dc, getWorkflowEnvironment(ctx).GetRegistry())
+	if err == nil {
+		input, err = wrapWorkflowContextEnvelope(ctx, input)
+	}
 	if err != nil {
 		executionSettable.Set(nil, err)
 		mainSettable.Set(nil, err)
@@ -637,6 +862,10 @@ func Now(ctx Context) time.Time {
 // The current timer resolution implementation is in seconds and uses math.Ceil(d.Seconds()) as the duration. But is
 // subjected to change in the future.
 func NewTimer(ctx Context, d time.Duration) Future {
+	return getWorkflowOperations(ctx).NewTimer(ctx, d)
+}
+
+func newTimer(ctx Context, d time.Duration) Future {
 	future, settable := NewFuture(ctx)
 	if d <= 0 {
 		settable.Set(true, nil)
@@ -682,6 +911,48 @@ func Sleep(ctx Context, d time.Duration) (err error) {
 	return
 }
 
+// Await blocks the calling workflow goroutine until condition returns true. The condition is evaluated again every
+// time a workflow goroutine makes progress, so it is satisfied as soon as the workflow state it reads changes, the
+// same way on every replay. The condition must only read the workflow state, it must not block nor have side effects.
+// Await returns ctx.Err() if ctx is canceled before the condition is satisfied.
+//  var approved bool
+//  Go(ctx, func(ctx Context) { GetSignalChannel(ctx, "approve").Receive(ctx, &approved) })
+//  err := Await(ctx, func() bool { return approved })
+func Await(ctx Context, condition func() bool) error {
+	state := getState(ctx)
+	defer state.unblocked()
+	for !condition() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		state.yield("blocked on Await")
+	}
+	return nil
+}
+
+// AwaitWithTimeout is like Await but gives up after timeout, measured by a durable timer. It returns true if the
+// condition was satisfied, false if the timeout elapsed first, and ctx.Err() if ctx is canceled first.
+func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool) (ok bool, err error) {
+	state := getState(ctx)
+	defer state.unblocked()
+	if condition() {
+		return true, nil
+	}
+	timerCtx, cancelTimer := WithCancel(ctx)
+	defer cancelTimer()
+	timer := NewTimer(timerCtx, timeout)
+	for !condition() {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if timer.IsReady() {
+			return false, nil
+		}
+		state.yield("blocked on AwaitWithTimeout")
+	}
+	return true, nil
+}
+
 // RequestCancelExternalWorkflow can be used to request cancellation of an external workflow.
 // Input workflowID is the workflow ID of target workflow.
 // Input runID indicates the instance of a workflow. Input runID is optional (default is ""). When runID is not specified,
@@ -728,8 +999,7 @@ func RequestCancelExternalWorkflow(ctx Context, workflowID, runID string) Future
 //	ctx := WithWorkflowDomain(ctx, "domain-name")
 // SignalExternalWorkflow return Future with failure or empty success result.
 func SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future {
-	childWorkflowOnly := false // this means we are not limited to child workflow
-	return signalExternalWorkflow(ctx, workflowID, runID, signalName, arg, childWorkflowOnly)
+	return getWorkflowOperations(ctx).SignalExternalWorkflow(ctx, workflowID, runID, signalName, arg)
 }
 
 func signalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}, childWorkflowOnly bool) Future {
@@ -851,6 +1121,28 @@ func GetSignalChannel(ctx Context, signalName string) Channel {
 	return getWorkflowEnvOptions(ctx).getSignalChannel(ctx, signalName)
 }
 
+// SetSignalHandler sets the handler of the signal signalName. The handler is a function that takes a workflow.Context
+// and the arguments of the signal, and returns nothing:
+//  err := workflow.SetSignalHandler(ctx, "add_item", func(ctx workflow.Context, item string) {
+//    items = append(items, item)
+//  })
+// The handler is called for every signal received, in the order they are received, by a workflow goroutine dedicated
+// to the signal. It is called with the signals received before it was set too. The handler may block, the next
+// signals are handled when it returns. Do not use GetSignalChannel for a signal that has a handler.
+// SetSignalHandler returns an error if handler is not a valid signal handler, or if the signal already has one.
+func SetSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	return setSignalHandler(ctx, signalName, handler)
+}
+
+// GetUnhandledSignalNames returns the sorted names of the signals received but neither handled by a signal handler
+// nor received from their signal channel yet. A workflow can check that there are none before it completes, the
+// signals it has not handled are lost when it completes.
+func GetUnhandledSignalNames(ctx Context) []string {
+	names := getWorkflowEnvOptions(ctx).getUnhandledSignals()
+	sort.Strings(names)
+	return names
+}
+
 func newEncodedValue(value []byte, dc encoded.DataConverter) encoded.Value {
 	if dc == nil {
 		dc = getDefaultDataConverter()
@@ -908,6 +1200,10 @@ func (b EncodedValue) HasValue() bool {
 //         ....
 //  }
 func SideEffect(ctx Context, f func(ctx Context) interface{}) encoded.Value {
+	return getWorkflowOperations(ctx).SideEffect(ctx, f)
+}
+
+func sideEffect(ctx Context, f func(ctx Context) interface{}) encoded.Value {
 	dc := getDataConverterFromWorkflowContext(ctx)
 	future, settable := NewFuture(ctx)
 	wrapperFunc := func() ([]byte, error) {
@@ -1008,7 +1304,7 @@ const DefaultVersion Version = -1
 //    err = workflow.ExecuteActivity(ctx, qux, data).Get(ctx, nil)
 //  }
 func GetVersion(ctx Context, changeID string, minSupported, maxSupported Version) Version {
-	return getWorkflowEnvironment(ctx).GetVersion(changeID, minSupported, maxSupported)
+	return getWorkflowOperations(ctx).GetVersion(ctx, changeID, minSupported, maxSupported)
 }
 
 // SetQueryHandler sets the query handler to handle workflow query. The queryType specify which query type this handler
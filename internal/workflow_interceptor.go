@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"time"
+
+	"go.uber.org/cadence/encoded"
+)
+
+type (
+	// WorkflowInterceptor intercepts the calls a workflow makes to the framework, to inject default options, enforce
+	// policies or add logging and metrics in one place for example. It is configured with
+	// WorkerOptions.WorkflowInterceptors.
+	WorkflowInterceptor interface {
+		// InterceptWorkflow returns the WorkflowOperations that wrap next. It is called once for every workflow
+		// execution started (or replayed) by the worker, before the workflow function runs.
+		// Implementations usually return a struct that embeds next and overrides the methods of interest only.
+		InterceptWorkflow(info *WorkflowInfo, next WorkflowOperations) WorkflowOperations
+	}
+
+	// WorkflowOperations are the workflow calls that a WorkflowInterceptor can wrap. Every method has the semantic of
+	// the workflow package function of the same name, and is invoked from the workflow goroutine that made the call.
+	// Use IsReplaying(ctx) to skip side effects like logging and metrics while the workflow is replayed.
+	WorkflowOperations interface {
+		// ExecuteWorkflow runs the workflow function with its encoded input and returns its encoded result.
+		ExecuteWorkflow(ctx Context, workflowType string, input []byte) ([]byte, error)
+		ExecuteActivity(ctx Context, activity interface{}, args ...interface{}) Future
+		ExecuteLocalActivity(ctx Context, activity interface{}, args ...interface{}) Future
+		ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interface{}) ChildWorkflowFuture
+		NewTimer(ctx Context, d time.Duration) Future
+		SideEffect(ctx Context, f func(ctx Context) interface{}) encoded.Value
+		SignalExternalWorkflow(ctx Context, workflowID, runID, signalName string, arg interface{}) Future
+		GetVersion(ctx Context, changeID string, minSupported, maxSupported Version) Version
+	}
+)
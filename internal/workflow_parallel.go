@@ -0,0 +1,149 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package internal
+
+import (
+	"errors"
+	"reflect"
+)
+
+type (
+	// ParallelOptions configure ExecuteInParallel.
+	ParallelOptions struct {
+		// Optional: MaxConcurrency is the maximum number of items executed at the same time.
+		// default: no limit, all the items are executed at once
+		MaxConcurrency int
+
+		// Optional: FailurePolicy decides what happens to the other items when the execution of an item fails.
+		// default: ParallelFailurePolicyCollectAll
+		FailurePolicy ParallelFailurePolicy
+
+		// Optional: ChildWorkflow executes the items with ExecuteChildWorkflow instead of ExecuteActivity.
+		// default: false, the items are executed with ExecuteActivity
+		ChildWorkflow bool
+	}
+
+	// ParallelFailurePolicy decides what ExecuteInParallel does when the execution of an item fails.
+	ParallelFailurePolicy int
+)
+
+const (
+	// ParallelFailurePolicyCollectAll executes all the items whatever the failures.
+	ParallelFailurePolicyCollectAll ParallelFailurePolicy = iota
+	// ParallelFailurePolicyFailFast stops at the first failure: the items not started yet are not executed, and the
+	// running ones are canceled.
+	ParallelFailurePolicyFailFast
+)
+
+var (
+	errParallelInputsNotSlice   = errors.New("inputs of ExecuteInParallel must be a slice")
+	errParallelValuePtrNotSlice = errors.New("valuePtr of ExecuteInParallel must be a pointer to a slice")
+)
+
+// ExecuteInParallel executes the activity, or the child workflow, fn once for every element of the slice inputs, which
+// is its single argument, with at most options.MaxConcurrency executions at the same time. The activity or child
+// workflow options are taken from ctx, like for ExecuteActivity and ExecuteChildWorkflow.
+// ExecuteInParallel blocks until all the executions are done. It decodes their results into the slice valuePtr points
+// to, in the order of the inputs, and returns their errors in the same order, nil for the executions that succeeded,
+// along with the first error of the executions if any. valuePtr can be nil if the results are not needed. The result of
+// a failed execution is left to the zero value. With ParallelFailurePolicyFailFast, the inputs that were not executed
+// get ErrCanceled.
+//  var files []string
+//  var sizes []int
+//  errs, err := workflow.ExecuteInParallel(ctx, workflow.ParallelOptions{MaxConcurrency: 10}, ProcessFile, files, &sizes)
+//  for i, err := range errs {
+//  	if err != nil {
+//  		workflow.GetLogger(ctx).Warn("failed to process file", zap.String("file", files[i]), zap.Error(err))
+//  	}
+//  }
+func ExecuteInParallel(ctx Context, options ParallelOptions, fn interface{}, inputs interface{},
+	valuePtr interface{}) ([]error, error) {
+	items := reflect.ValueOf(inputs)
+	if items.Kind() != reflect.Slice {
+		return nil, errParallelInputsNotSlice
+	}
+	count := items.Len()
+	var values reflect.Value
+	if valuePtr != nil {
+		v := reflect.ValueOf(valuePtr)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+			return nil, errParallelValuePtrNotSlice
+		}
+		values = reflect.MakeSlice(v.Elem().Type(), count, count)
+		v.Elem().Set(values)
+	}
+	limit := options.MaxConcurrency
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+	ctx, cancel := WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, count)
+	var firstErr error
+	next, running := 0, 0
+	selector := NewNamedSelector(ctx, "ExecuteInParallel")
+	startNext := func() {
+		i := next
+		next++
+		running++
+		var future Future
+		if options.ChildWorkflow {
+			future = ExecuteChildWorkflow(ctx, fn, items.Index(i).Interface())
+		} else {
+			future = ExecuteActivity(ctx, fn, items.Index(i).Interface())
+		}
+		selector.AddFuture(future, func(f Future) {
+			running--
+			var value interface{}
+			if values.IsValid() {
+				value = values.Index(i).Addr().Interface()
+			}
+			if err := f.Get(ctx, value); err != nil {
+				errs[i] = err
+				if firstErr == nil {
+					firstErr = err
+					if options.FailurePolicy == ParallelFailurePolicyFailFast {
+						cancel()
+					}
+				}
+			}
+		})
+	}
+
+	// ctx is canceled on the first failure with ParallelFailurePolicyFailFast
+	for next < count && running < limit && ctx.Err() == nil {
+		startNext()
+	}
+	for running > 0 {
+		selector.Select(ctx)
+		for next < count && running < limit && ctx.Err() == nil {
+			startNext()
+		}
+	}
+	for ; next < count; next++ {
+		errs[next] = ErrCanceled
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return errs, firstErr
+}
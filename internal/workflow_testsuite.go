@@ -28,6 +28,7 @@ import (
 
 	"github.com/stretchr/testify/mock"
 	"github.com/uber-go/tally"
+	"go.uber.org/cadence/.gen/go/cadence/workflowserviceclient"
 	"go.uber.org/cadence/.gen/go/shared"
 	"go.uber.org/cadence/encoded"
 	"go.uber.org/zap"
@@ -68,6 +69,26 @@ type (
 		runFn        func(args mock.Arguments)
 		waitDuration func() time.Duration
 	}
+
+	// TestServiceOptions configure the TestService returned by NewTestService.
+	TestServiceOptions struct {
+		// Optional: the maximum time a poll for tasks or a long poll for workflow history waits before it returns
+		// an empty response.
+		// default: 1 minute
+		LongPollTimeout time.Duration
+	}
+
+	// TestService is an in-memory implementation of the Cadence service. Pass it to worker.New and client.NewClient
+	// to run workflows and activities end to end within a test, without a Cadence server. The service runs on the
+	// wall clock, and does not support cron schedules, workflow retries, ResetWorkflowExecution or sticky task lists.
+	// Like the Cadence server, it doesn't apply ChildWorkflowOptions.ChildPolicy: the children of a workflow keep
+	// running when it closes.
+	TestService interface {
+		workflowserviceclient.Interface
+		// Close releases all pending polls and stops the timers of the service. Stop the workers and clients
+		// using the service before closing it.
+		Close()
+	}
 )
 
 func newEncodedValues(values []byte, dc encoded.DataConverter) encoded.Values {
@@ -109,6 +130,12 @@ func (b ErrorDetailsValues) HasValues() bool {
 	return b != nil && len(b) != 0
 }
 
+// NewTestService creates a new in-memory Cadence service. Register the domain used by the test on the service before
+// starting workers and clients on it.
+func NewTestService(options TestServiceOptions) TestService {
+	return newInMemoryService(options)
+}
+
 // NewTestWorkflowEnvironment creates a new instance of TestWorkflowEnvironment. Use the returned TestWorkflowEnvironment
 // to run your workflow in the test environment.
 func (s *WorkflowTestSuite) NewTestWorkflowEnvironment() *TestWorkflowEnvironment {
@@ -151,13 +178,36 @@ func (t *TestActivityEnvironment) ExecuteLocalActivity(activityFn interface{}, a
 }
 
 // SetWorkerOptions sets the WorkerOptions that will be use by TestActivityEnvironment. TestActivityEnvironment will
-// use options of Identity, MetricsScope and BackgroundActivityContext on the WorkerOptions. Other options are ignored.
+// use options of Identity, MetricsScope, BackgroundActivityContext, DataConverter, ActivityInterceptors and
+// ContextPropagators on the WorkerOptions. Other options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (t *TestActivityEnvironment) SetWorkerOptions(options WorkerOptions) *TestActivityEnvironment {
 	t.impl.setWorkerOptions(options)
 	return t
 }
 
+// RegisterActivity registers an activity function with this TestActivityEnvironment only, in addition to the global
+// registrations, like Worker.RegisterActivity does for a worker.
+func (t *TestActivityEnvironment) RegisterActivity(a interface{}) {
+	t.RegisterActivityWithOptions(a, RegisterActivityOptions{})
+}
+
+// RegisterActivityWithOptions registers an activity function with this TestActivityEnvironment only, see
+// RegisterActivity.
+func (t *TestActivityEnvironment) RegisterActivityWithOptions(a interface{}, options RegisterActivityOptions) {
+	if err := t.impl.registry.RegisterActivityWithOptions(a, options); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterDynamicActivity registers the dynamic activity function with this TestActivityEnvironment only, see
+// RegisterDynamicActivity of the activity package. It executes the activity types without an exact registration.
+func (t *TestActivityEnvironment) RegisterDynamicActivity(a DynamicActivityFunc) {
+	if err := t.impl.registry.RegisterDynamicActivity(a); err != nil {
+		panic(err)
+	}
+}
+
 // SetTestTimeout sets the wall clock timeout for this activity test run. When test timeout happen, it means activity is
 // taking too long.
 func (t *TestActivityEnvironment) SetTestTimeout(idleTimeout time.Duration) *TestActivityEnvironment {
@@ -170,6 +220,53 @@ func (t *TestActivityEnvironment) SetHeartbeatDetails(details interface{}) {
 	t.impl.setHeartbeatDetails(details)
 }
 
+// RegisterWorkflow registers a workflow function with this TestWorkflowEnvironment only, in addition to the global
+// registrations, like Worker.RegisterWorkflow does for a worker. Child workflows started by the tested workflow see
+// the registration too.
+func (t *TestWorkflowEnvironment) RegisterWorkflow(w interface{}) {
+	t.RegisterWorkflowWithOptions(w, RegisterWorkflowOptions{})
+}
+
+// RegisterWorkflowWithOptions registers a workflow function with this TestWorkflowEnvironment only, see
+// RegisterWorkflow.
+func (t *TestWorkflowEnvironment) RegisterWorkflowWithOptions(w interface{}, options RegisterWorkflowOptions) {
+	if err := t.impl.registry.RegisterWorkflowWithOptions(w, options); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterActivity registers an activity function with this TestWorkflowEnvironment only, in addition to the global
+// registrations, like Worker.RegisterActivity does for a worker.
+func (t *TestWorkflowEnvironment) RegisterActivity(a interface{}) {
+	t.RegisterActivityWithOptions(a, RegisterActivityOptions{})
+}
+
+// RegisterActivityWithOptions registers an activity function with this TestWorkflowEnvironment only, see
+// RegisterActivity.
+func (t *TestWorkflowEnvironment) RegisterActivityWithOptions(a interface{}, options RegisterActivityOptions) {
+	if err := t.impl.registry.RegisterActivityWithOptions(a, options); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterDynamicWorkflow registers the dynamic workflow function with this TestWorkflowEnvironment only, see
+// RegisterDynamicWorkflow of the workflow package. It executes the workflow types without an exact registration. The
+// workflow types it executes can't be mocked.
+func (t *TestWorkflowEnvironment) RegisterDynamicWorkflow(w DynamicWorkflowFunc) {
+	if err := t.impl.registry.RegisterDynamicWorkflow(w); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterDynamicActivity registers the dynamic activity function with this TestWorkflowEnvironment only, see
+// RegisterDynamicActivity of the activity package. It executes the activity types without an exact registration. The
+// activity types it executes can't be mocked.
+func (t *TestWorkflowEnvironment) RegisterDynamicActivity(a DynamicActivityFunc) {
+	if err := t.impl.registry.RegisterDynamicActivity(a); err != nil {
+		panic(err)
+	}
+}
+
 // SetStartTime sets the start time of the workflow. This is optional, default start time will be the wall clock time when
 // workflow starts. Start time is the workflow.Now(ctx) time at the beginning of the workflow.
 func (t *TestWorkflowEnvironment) SetStartTime(startTime time.Time) {
@@ -199,7 +296,7 @@ func (t *TestWorkflowEnvironment) OnActivity(activity interface{}, args ...inter
 			panic(err)
 		}
 		fnName := getFunctionName(activity)
-		if alias, ok := getHostEnvironment().getActivityAlias(fnName); ok {
+		if alias, ok := t.impl.registry.getActivityAlias(fnName); ok {
 			fnName = alias
 		}
 		call = t.Mock.On(fnName, args...)
@@ -242,7 +339,7 @@ func (t *TestWorkflowEnvironment) OnWorkflow(workflow interface{}, args ...inter
 			panic(err)
 		}
 		fnName := getFunctionName(workflow)
-		if alias, ok := getHostEnvironment().getWorkflowAlias(fnName); ok {
+		if alias, ok := t.impl.registry.getWorkflowAlias(fnName); ok {
 			fnName = alias
 		}
 		call = t.Mock.On(fnName, args...)
@@ -378,7 +475,8 @@ func (t *TestWorkflowEnvironment) Now() time.Time {
 }
 
 // SetWorkerOptions sets the WorkerOptions for TestWorkflowEnvironment. TestWorkflowEnvironment will use options set by
-// use options of Identity, MetricsScope and BackgroundActivityContext on the WorkerOptions. Other options are ignored.
+// use options of Identity, MetricsScope, BackgroundActivityContext, DataConverter, WorkflowInterceptors,
+// ActivityInterceptors and ContextPropagators on the WorkerOptions. Other options are ignored.
 // Note: WorkerOptions is defined in internal package, use public type worker.Options instead.
 func (t *TestWorkflowEnvironment) SetWorkerOptions(options WorkerOptions) *TestWorkflowEnvironment {
 	t.impl.setWorkerOptions(options)
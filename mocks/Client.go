@@ -150,6 +150,22 @@ func (_m *Client) ExecuteWorkflow(ctx context.Context, options client.StartWorkf
 	return r0, r1
 }
 
+// GetWorkflow provides a mock function with given fields: ctx, workflowID, runID
+func (_m *Client) GetWorkflow(ctx context.Context, workflowID string, runID string) client.WorkflowRun {
+	ret := _m.Called(ctx, workflowID, runID)
+
+	var r0 client.WorkflowRun
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) client.WorkflowRun); ok {
+		r0 = rf(ctx, workflowID, runID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(client.WorkflowRun)
+		}
+	}
+
+	return r0
+}
+
 // GetWorkflowHistory provides a mock function with given fields: ctx, workflowID, runID, isLongPoll, filterType
 func (_m *Client) GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType shared.HistoryEventFilterType) client.HistoryEventIterator {
 	ret := _m.Called(ctx, workflowID, runID, isLongPoll, filterType)
@@ -238,6 +254,32 @@ func (_m *Client) QueryWorkflow(ctx context.Context, workflowID string, runID st
 	return r0, r1
 }
 
+// QueryWorkflowFromHistory provides a mock function with given fields: ctx, workflowID, runID, queryType, args
+func (_m *Client) QueryWorkflowFromHistory(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (encoded.Value, error) {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, workflowID, runID, queryType)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	var r0 encoded.Value
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, ...interface{}) encoded.Value); ok {
+		r0 = rf(ctx, workflowID, runID, queryType, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(encoded.Value)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, ...interface{}) error); ok {
+		r1 = rf(ctx, workflowID, runID, queryType, args...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RecordActivityHeartbeat provides a mock function with given fields: ctx, taskToken, details
 func (_m *Client) RecordActivityHeartbeat(ctx context.Context, taskToken []byte, details ...interface{}) error {
 	var _ca []interface{}
@@ -272,6 +314,29 @@ func (_m *Client) RecordActivityHeartbeatByID(ctx context.Context, domain, workf
 	return r0
 }
 
+// ResetWorkflow provides a mock function with given fields: ctx, request
+func (_m *Client) ResetWorkflow(ctx context.Context, request *shared.ResetWorkflowExecutionRequest) (*shared.ResetWorkflowExecutionResponse, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *shared.ResetWorkflowExecutionResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *shared.ResetWorkflowExecutionRequest) *shared.ResetWorkflowExecutionResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*shared.ResetWorkflowExecutionResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *shared.ResetWorkflowExecutionRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SignalWorkflow provides a mock function with given fields: ctx, workflowID, runID, signalName, arg
 func (_m *Client) SignalWorkflow(ctx context.Context, workflowID string, runID string, signalName string, arg interface{}) error {
 	ret := _m.Called(ctx, workflowID, runID, signalName, arg)
@@ -354,3 +419,19 @@ func (_m *Client) TerminateWorkflow(ctx context.Context, workflowID string, runI
 
 	return r0
 }
+
+// WatchWorkflow provides a mock function with given fields: ctx, workflowID, runID
+func (_m *Client) WatchWorkflow(ctx context.Context, workflowID string, runID string) <-chan client.WorkflowEvent {
+	ret := _m.Called(ctx, workflowID, runID)
+
+	var r0 <-chan client.WorkflowEvent
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) <-chan client.WorkflowEvent); ok {
+		r0 = rf(ctx, workflowID, runID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan client.WorkflowEvent)
+		}
+	}
+
+	return r0
+}
@@ -37,7 +37,21 @@ type (
 
 	// MockCallWrapper is a wrapper to mock.Call. It offers the ability to wait on workflow's clock instead of wall clock.
 	MockCallWrapper = internal.MockCallWrapper
+
+	// TestServiceOptions configure the TestService returned by NewTestService.
+	TestServiceOptions = internal.TestServiceOptions
+
+	// TestService is an in-memory implementation of the Cadence service. Pass it to worker.New and client.NewClient
+	// to run workflows and activities end to end within a test, without a Cadence server. The service runs on the
+	// wall clock, and does not support cron schedules, workflow retries, ResetWorkflowExecution or sticky task lists.
+	TestService = internal.TestService
 )
 
 // ErrMockStartChildWorkflowFailed is special error used to indicate the mocked child workflow should fail to start.
 var ErrMockStartChildWorkflowFailed = internal.ErrMockStartChildWorkflowFailed
+
+// NewTestService creates a new in-memory Cadence service. Register the domain used by the test on the service before
+// starting workers and clients on it.
+func NewTestService(options TestServiceOptions) TestService {
+	return internal.NewTestService(options)
+}
@@ -32,7 +32,8 @@ import (
 )
 
 type (
-	// Worker represents objects that can be started and stopped.
+	// Worker represents objects that can be started and stopped, and that host the workflows and activities
+	// registered with them.
 	Worker = internal.Worker
 
 	// Options is used to configure a worker instance.
@@ -87,6 +88,13 @@ func ReplayWorkflowHistory(logger *zap.Logger, history *shared.History) error {
 	return internal.ReplayWorkflowHistory(logger, history)
 }
 
+// ReplayWorkerWorkflowHistory executes a single decision task for the given history, with the workflows registered
+// with the worker in addition to the global registrations. The worker doesn't need to be started.
+// The logger is an optional parameter. Defaults to the noop logger.
+func ReplayWorkerWorkflowHistory(worker Worker, logger *zap.Logger, history *shared.History) error {
+	return internal.ReplayWorkerWorkflowHistory(worker, logger, history)
+}
+
 // ReplayWorkflowHistoryFromJSONFile executes a single decision task for the json history file downloaded from the cli.
 // To download the history file: cadence workflow showid <workflow_id> -of <output_filename>
 // See https://github.com/uber/cadence/blob/master/tools/cli/README.md for full documentation
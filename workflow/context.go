@@ -21,6 +21,8 @@
 package workflow
 
 import (
+	"time"
+
 	"go.uber.org/cadence/internal"
 )
 
@@ -54,6 +56,27 @@ func WithCancel(parent Context) (ctx Context, cancel CancelFunc) {
 	return internal.WithCancel(parent)
 }
 
+// WithDeadline returns a copy of the parent context with the deadline adjusted
+// to be no later than d. The returned context's Done channel is closed when the
+// deadline expires, when the returned cancel function is called, or when the
+// parent context's Done channel is closed, whichever happens first. Err returns
+// ErrDeadlineExceeded once the deadline expires.
+//
+// The deadline is enforced with a timer started with NewTimer, so it is durable
+// and deterministic. Activities and child workflows started with the returned
+// context are canceled when it expires.
+//
+// Canceling this context releases resources associated with it, so code should
+// call cancel as soon as the operations running in this Context complete.
+func WithDeadline(parent Context, d time.Time) (ctx Context, cancel CancelFunc) {
+	return internal.WithDeadline(parent, d)
+}
+
+// WithTimeout returns WithDeadline(parent, workflow.Now(parent).Add(timeout)).
+func WithTimeout(parent Context, timeout time.Duration) (ctx Context, cancel CancelFunc) {
+	return internal.WithTimeout(parent, timeout)
+}
+
 // WithValue returns a copy of parent in which the value associated with key is
 // val.
 //
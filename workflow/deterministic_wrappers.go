@@ -36,6 +36,22 @@ type (
 	// Use workflow.NewSelector(ctx) method to create a Selector instance.
 	Selector = internal.Selector
 
+	// WaitGroup must be used instead of sync.WaitGroup by workflow code.
+	// Use workflow.NewWaitGroup(ctx) method to create a WaitGroup instance.
+	WaitGroup = internal.WaitGroup
+
+	// Mutex must be used instead of sync.Mutex by workflow code.
+	// Use workflow.NewMutex(ctx) method to create a Mutex instance.
+	Mutex = internal.Mutex
+
+	// Semaphore must be used by workflow code to bound the number of goroutines doing something at the same time.
+	// Use workflow.NewSemaphore(ctx, n) method to create a Semaphore instance.
+	Semaphore = internal.Semaphore
+
+	// Group runs a collection of workflow goroutines working on subtasks of a common task, like errgroup.Group.
+	// Use workflow.NewGroup(ctx) method to create a Group instance.
+	Group = internal.Group
+
 	// Future represents the result of an asynchronous computation.
 	Future = internal.Future
 
@@ -66,6 +82,53 @@ func NewNamedBufferedChannel(ctx Context, name string, size int) Channel {
 	return internal.NewNamedBufferedChannel(ctx, name, size)
 }
 
+// NewWaitGroup creates a new WaitGroup instance.
+func NewWaitGroup(ctx Context) WaitGroup {
+	return internal.NewWaitGroup(ctx)
+}
+
+// NewNamedWaitGroup creates a new WaitGroup instance with a given human readable name.
+// Name appears in stack traces that are blocked on this WaitGroup.
+func NewNamedWaitGroup(ctx Context, name string) WaitGroup {
+	return internal.NewNamedWaitGroup(ctx, name)
+}
+
+// NewMutex creates a new Mutex instance.
+func NewMutex(ctx Context) Mutex {
+	return internal.NewMutex(ctx)
+}
+
+// NewNamedMutex creates a new Mutex instance with a given human readable name.
+// Name appears in stack traces that are blocked on this Mutex.
+func NewNamedMutex(ctx Context, name string) Mutex {
+	return internal.NewNamedMutex(ctx, name)
+}
+
+// NewSemaphore creates a new Semaphore instance with n permits. It panics if n is not positive.
+func NewSemaphore(ctx Context, n int) Semaphore {
+	return internal.NewSemaphore(ctx, n)
+}
+
+// NewNamedSemaphore creates a new Semaphore instance with n permits and a given human readable name.
+// Name appears in stack traces that are blocked on this Semaphore. It panics if n is not positive.
+func NewNamedSemaphore(ctx Context, name string, n int) Semaphore {
+	return internal.NewNamedSemaphore(ctx, name, n)
+}
+
+// NewGroup creates a new Group instance. The context of the group is derived from ctx, it is canceled when a function
+// started with Group.Go fails or when Group.Wait returns.
+//  g := workflow.NewGroup(ctx)
+//  for _, file := range files {
+//  	file := file
+//  	g.Go(func(ctx workflow.Context) error {
+//  		return workflow.ExecuteActivity(ctx, ProcessFileActivity, file).Get(ctx, nil)
+//  	})
+//  }
+//  err := g.Wait(ctx)
+func NewGroup(ctx Context) Group {
+	return internal.NewGroup(ctx)
+}
+
 // NewSelector creates a new Selector instance.
 func NewSelector(ctx Context) Selector {
 	return internal.NewSelector(ctx)
@@ -94,6 +157,28 @@ func NewFuture(ctx Context) (Future, Settable) {
 	return internal.NewFuture(ctx)
 }
 
+// AllOf returns a Future that is ready when all the futures are ready, or as soon as one of them fails. Its error is
+// the error of the first future that failed, and its value is nil: get the results from the futures themselves. The
+// futures still pending when one fails are not canceled, cancel their context to cancel them.
+//  err := workflow.AllOf(ctx, f1, f2, f3).Get(ctx, nil)
+func AllOf(ctx Context, futures ...Future) Future {
+	return internal.AllOf(ctx, futures...)
+}
+
+// AnyOf returns a Future that is ready as soon as one of the futures is ready, with the value and the error of that
+// future. Use IsReady on the futures to find out which one it is. The Future is ready immediately if there are no
+// futures.
+func AnyOf(ctx Context, futures ...Future) Future {
+	return internal.AnyOf(ctx, futures...)
+}
+
+// Then returns a Future for the result of fn, which is called in a new workflow goroutine once future is ready. fn
+// gets the result of future with f.Get, which doesn't block then, and can map it to a new value or error, or make
+// more workflow calls.
+func Then(ctx Context, future Future, fn func(ctx Context, f Future) (interface{}, error)) Future {
+	return internal.Then(ctx, future, fn)
+}
+
 // Now returns the current time when the decision is started or replayed.
 // The workflow needs to use this Now() to get the wall clock time instead of the Go lang library one.
 func Now(ctx Context) time.Time {
@@ -121,3 +206,16 @@ func NewTimer(ctx Context, d time.Duration) Future {
 func Sleep(ctx Context, d time.Duration) (err error) {
 	return internal.Sleep(ctx, d)
 }
+
+// Await blocks the calling workflow goroutine until condition returns true. The condition is evaluated again every
+// time a workflow goroutine makes progress, so it must only read the workflow state and must not block.
+// Await returns ctx.Err() if ctx is canceled before the condition is satisfied.
+func Await(ctx Context, condition func() bool) error {
+	return internal.Await(ctx, condition)
+}
+
+// AwaitWithTimeout is like Await but gives up after timeout, measured by a durable timer. It returns true if the
+// condition was satisfied, false if the timeout elapsed first, and ctx.Err() if ctx is canceled first.
+func AwaitWithTimeout(ctx Context, timeout time.Duration, condition func() bool) (ok bool, err error) {
+	return internal.AwaitWithTimeout(ctx, timeout, condition)
+}
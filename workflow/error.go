@@ -121,6 +121,16 @@ func NewContinueAsNewError(ctx Context, wfn interface{}, args ...interface{}) *C
 	return internal.NewContinueAsNewError(ctx, wfn, args...)
 }
 
+// ContinueAsNewWithSignals creates ContinueAsNewError instance like NewContinueAsNewError, and carries over the
+// signals the workflow has not handled yet to the new execution. The signals are removed from their signal channels
+// and the new execution receives them before the signals sent to it, sorted by signal name and in the order they
+// were received for each name. Use it for long running workflows that must not lose a signal received after they
+// decided to continue as new:
+//  return workflow.ContinueAsNewWithSignals(ctx, EntityWorkflow, state)
+func ContinueAsNewWithSignals(ctx Context, wfn interface{}, args ...interface{}) *ContinueAsNewError {
+	return internal.ContinueAsNewWithSignals(ctx, wfn, args...)
+}
+
 // NewTimeoutError creates TimeoutError instance.
 // Use NewHeartbeatTimeoutError to create heartbeat TimeoutError
 // WARNING: This function is public only to support unit testing of workflows.
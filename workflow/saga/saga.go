@@ -0,0 +1,164 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package saga runs the compensations of the completed steps of a workflow when a later step fails.
+//
+// Every step of a multi-step business transaction that completes registers the activity or child workflow that
+// undoes it. When a step fails, or the workflow is canceled, Compensate runs the registered compensations in the
+// reverse order of their registration:
+//  func TransferWorkflow(ctx workflow.Context, transfer Transfer) (err error) {
+//  	s := saga.New(saga.Options{})
+//  	defer func() {
+//  		if err != nil {
+//  			// the error of the compensations is logged, the workflow fails with the error of the step
+//  			if compensationErr := s.Compensate(ctx); compensationErr != nil {
+//  				workflow.GetLogger(ctx).Error("Compensation failed.", zap.Error(compensationErr))
+//  			}
+//  		}
+//  	}()
+//
+//  	if err = workflow.ExecuteActivity(ctx, Withdraw, transfer).Get(ctx, nil); err != nil {
+//  		return err
+//  	}
+//  	s.AddCompensation(WithdrawCompensation, transfer)
+//
+//  	if err = workflow.ExecuteActivity(ctx, Deposit, transfer).Get(ctx, nil); err != nil {
+//  		return err
+//  	}
+//  	s.AddCompensation(DepositCompensation, transfer)
+//  	return nil
+//  }
+package saga
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/cadence/workflow"
+)
+
+type (
+	// Options configure how a Saga runs its compensations.
+	Options struct {
+		// Optional: ParallelCompensation runs all the compensations at the same time instead of one after the other
+		// in the reverse order of their registration.
+		// default: false
+		ParallelCompensation bool
+
+		// Optional: ContinueWithError runs the remaining compensations when a compensation fails, instead of stopping
+		// at the first failure. The compensations run in parallel always all run.
+		// default: false
+		ContinueWithError bool
+	}
+
+	// Saga records the compensations of the completed steps of a workflow, and runs them when the workflow fails.
+	// It must only be used by the workflow goroutines of the workflow that created it.
+	Saga struct {
+		options       Options
+		compensations []compensation
+	}
+
+	// CompensationError is returned by Saga.Compensate when compensations fail. Errors holds the errors of the
+	// failed compensations, in the order they failed.
+	CompensationError struct {
+		Errors []error
+	}
+
+	compensation struct {
+		childWorkflow bool
+		fn            interface{}
+		args          []interface{}
+	}
+)
+
+// New creates a Saga without compensations.
+func New(options Options) *Saga {
+	return &Saga{options: options}
+}
+
+// AddCompensation registers an activity that compensates a completed step. It is executed with ExecuteActivity and
+// args by Compensate.
+func (s *Saga) AddCompensation(activity interface{}, args ...interface{}) {
+	s.compensations = append(s.compensations, compensation{fn: activity, args: args})
+}
+
+// AddChildWorkflowCompensation registers a child workflow that compensates a completed step. It is executed with
+// ExecuteChildWorkflow and args by Compensate.
+func (s *Saga) AddChildWorkflowCompensation(childWorkflow interface{}, args ...interface{}) {
+	s.compensations = append(s.compensations, compensation{childWorkflow: true, fn: childWorkflow, args: args})
+}
+
+// Compensate runs the registered compensations and blocks until they are done. They run with a context disconnected
+// from ctx, so they also run when the workflow is canceled, but with the activity and child workflow options of ctx.
+// The compensations are removed from the Saga, calling Compensate again only runs the compensations registered since.
+// Compensate returns a *CompensationError if compensations fail.
+func (s *Saga) Compensate(ctx workflow.Context) error {
+	ctx, cancel := workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+	compensations := s.compensations
+	s.compensations = nil
+
+	var errs []error
+	if s.options.ParallelCompensation {
+		futures := make([]workflow.Future, len(compensations))
+		for i, c := range compensations {
+			futures[i] = c.execute(ctx)
+		}
+		selector := workflow.NewNamedSelector(ctx, "saga-compensations")
+		for _, f := range futures {
+			selector.AddFuture(f, func(f workflow.Future) {
+				if err := f.Get(ctx, nil); err != nil {
+					errs = append(errs, err)
+				}
+			})
+		}
+		for range futures {
+			selector.Select(ctx)
+		}
+	} else {
+		for i := len(compensations) - 1; i >= 0; i-- {
+			if err := compensations[i].execute(ctx).Get(ctx, nil); err != nil {
+				errs = append(errs, err)
+				if !s.options.ContinueWithError {
+					break
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &CompensationError{Errors: errs}
+	}
+	return nil
+}
+
+func (c compensation) execute(ctx workflow.Context) workflow.Future {
+	if c.childWorkflow {
+		return workflow.ExecuteChildWorkflow(ctx, c.fn, c.args...)
+	}
+	return workflow.ExecuteActivity(ctx, c.fn, c.args...)
+}
+
+func (e *CompensationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%v compensation(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package saga
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+)
+
+var (
+	compensatedLock  sync.Mutex
+	compensatedSteps []string
+)
+
+func compensateActivity(ctx context.Context, step string) error {
+	compensatedLock.Lock()
+	defer compensatedLock.Unlock()
+	compensatedSteps = append(compensatedSteps, step)
+	if step == "fail" {
+		return errors.New("compensation failed")
+	}
+	return nil
+}
+
+func compensateWorkflow(ctx workflow.Context, options Options, steps []string) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	s := New(options)
+	for _, step := range steps {
+		s.AddCompensation(compensateActivity, step)
+	}
+	if err := s.Compensate(ctx); err != nil {
+		return err
+	}
+	// the compensations only run once
+	return s.Compensate(ctx)
+}
+
+// compensateOnCancelWorkflow completes its steps and waits to be canceled, it then compensates the steps with its
+// canceled context.
+func compensateOnCancelWorkflow(ctx workflow.Context, steps []string) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute,
+		StartToCloseTimeout:    time.Minute,
+	})
+	s := New(Options{})
+	for _, step := range steps {
+		s.AddCompensation(compensateActivity, step)
+	}
+	err := workflow.Sleep(ctx, time.Hour)
+	if compensationErr := s.Compensate(ctx); compensationErr != nil {
+		return compensationErr
+	}
+	return err
+}
+
+func init() {
+	workflow.Register(compensateWorkflow)
+	workflow.Register(compensateOnCancelWorkflow)
+	activity.Register(compensateActivity)
+}
+
+func executeCompensations(t *testing.T, options Options, steps ...string) ([]string, error) {
+	compensatedSteps = nil
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(compensateWorkflow, options, steps)
+	require.True(t, env.IsWorkflowCompleted())
+	return compensatedSteps, env.GetWorkflowError()
+}
+
+func TestCompensate(t *testing.T) {
+	compensated, err := executeCompensations(t, Options{}, "1", "2", "3")
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "2", "1"}, compensated)
+}
+
+func TestCompensate_StopsAtError(t *testing.T) {
+	compensated, err := executeCompensations(t, Options{}, "1", "fail", "3")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "1 compensation(s) failed: compensation failed")
+	require.Equal(t, []string{"3", "fail"}, compensated)
+}
+
+func TestCompensate_ContinueWithError(t *testing.T) {
+	compensated, err := executeCompensations(t, Options{ContinueWithError: true}, "1", "fail", "3")
+	require.Error(t, err)
+	require.Equal(t, []string{"3", "fail", "1"}, compensated)
+}
+
+func TestCompensate_Parallel(t *testing.T) {
+	compensated, err := executeCompensations(t, Options{ParallelCompensation: true}, "1", "fail", "3")
+	require.Error(t, err)
+	sort.Strings(compensated)
+	require.Equal(t, []string{"1", "3", "fail"}, compensated)
+}
+
+func TestCompensate_Canceled(t *testing.T) {
+	compensatedSteps = nil
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	env.ExecuteWorkflow(compensateOnCancelWorkflow, []string{"1", "2", "3"})
+	require.True(t, env.IsWorkflowCompleted())
+	require.True(t, cadence.IsCanceledError(env.GetWorkflowError()), "%v", env.GetWorkflowError())
+	require.Equal(t, []string{"3", "2", "1"}, compensatedSteps)
+}
@@ -50,8 +50,33 @@ type (
 	// RegisterOptions consists of options for registering a workflow
 	RegisterOptions = internal.RegisterWorkflowOptions
 
+	// DynamicFunc is the function type of a dynamic workflow, see RegisterDynamic.
+	DynamicFunc = internal.DynamicWorkflowFunc
+
+	// Interceptor intercepts the calls of a workflow, see worker.Options.WorkflowInterceptors.
+	Interceptor = internal.WorkflowInterceptor
+
+	// Operations are the workflow calls that an Interceptor can wrap.
+	Operations = internal.WorkflowOperations
+
+	// ContextPropagator propagates request scoped values from the client to workflows, and from workflows to their
+	// activities and child workflows, see client.Options.ContextPropagators and worker.Options.ContextPropagators.
+	ContextPropagator = internal.ContextPropagator
+
+	// HeaderWriter is used by a ContextPropagator to write the values it propagates.
+	HeaderWriter = internal.HeaderWriter
+
+	// HeaderReader is used by a ContextPropagator to read the values propagated to it.
+	HeaderReader = internal.HeaderReader
+
 	// Info information about currently executing workflow
 	Info = internal.WorkflowInfo
+
+	// ParallelOptions configure ExecuteInParallel.
+	ParallelOptions = internal.ParallelOptions
+
+	// ParallelFailurePolicy decides what ExecuteInParallel does when the execution of an item fails.
+	ParallelFailurePolicy = internal.ParallelFailurePolicy
 )
 
 const (
@@ -63,6 +88,12 @@ const (
 	// ChildWorkflowPolicyAbandon is policy that will have no impact to child workflow execution when parent workflow is
 	// terminated.
 	ChildWorkflowPolicyAbandon ChildWorkflowPolicy = internal.ChildWorkflowPolicyAbandon
+
+	// ParallelFailurePolicyCollectAll executes all the items whatever the failures.
+	ParallelFailurePolicyCollectAll ParallelFailurePolicy = internal.ParallelFailurePolicyCollectAll
+	// ParallelFailurePolicyFailFast stops at the first failure: the items not started yet are not executed, and the
+	// running ones are canceled.
+	ParallelFailurePolicyFailFast ParallelFailurePolicy = internal.ParallelFailurePolicyFailFast
 )
 
 // Register - registers a workflow function with the framework.
@@ -95,6 +126,14 @@ func RegisterWithOptions(workflowFunc interface{}, opts RegisterOptions) {
 	internal.RegisterWorkflowWithOptions(workflowFunc, opts)
 }
 
+// RegisterDynamic registers a function that executes every workflow type without an exact registration,
+// instead of failing the decision task. The function receives the workflow type name and the raw arguments.
+//	func dynamicWorkflow(ctx workflow.Context, workflowType string, args encoded.Values) (interface{}, error)
+// Only one dynamic workflow can be registered, this method calls panic if one is already registered.
+func RegisterDynamic(workflowFunc DynamicFunc) {
+	internal.RegisterDynamicWorkflow(workflowFunc)
+}
+
 // ExecuteActivity requests activity execution in the context of a workflow.
 // Context can be used to pass the settings for this activity.
 // For example: task list that this need to be routed, timeouts that need to be configured.
@@ -177,6 +216,19 @@ func ExecuteChildWorkflow(ctx Context, childWorkflow interface{}, args ...interf
 	return internal.ExecuteChildWorkflow(ctx, childWorkflow, args...)
 }
 
+// ExecuteInParallel executes the activity, or the child workflow, fn once for every element of the slice inputs, which
+// is its single argument, with at most options.MaxConcurrency executions at the same time. The activity or child
+// workflow options are taken from ctx, like for ExecuteActivity and ExecuteChildWorkflow.
+// ExecuteInParallel blocks until all the executions are done. It decodes their results into the slice valuePtr points
+// to, in the order of the inputs, and returns their errors in the same order, nil for the executions that succeeded,
+// along with the first error of the executions if any. valuePtr can be nil if the results are not needed. The result of
+// a failed execution is left to the zero value. With ParallelFailurePolicyFailFast, the inputs that were not executed
+// get ErrCanceled.
+func ExecuteInParallel(ctx Context, options ParallelOptions, fn interface{}, inputs interface{},
+	valuePtr interface{}) ([]error, error) {
+	return internal.ExecuteInParallel(ctx, options, fn, inputs, valuePtr)
+}
+
 // GetInfo extracts info of a current workflow from a context.
 func GetInfo(ctx Context) *Info {
 	return internal.GetWorkflowInfo(ctx)
@@ -221,6 +273,26 @@ func GetSignalChannel(ctx Context, signalName string) Channel {
 	return internal.GetSignalChannel(ctx, signalName)
 }
 
+// SetSignalHandler sets the handler of the signal signalName. The handler is a function that takes a workflow.Context
+// and the arguments of the signal, and returns nothing:
+//  err := workflow.SetSignalHandler(ctx, "add_item", func(ctx workflow.Context, item string) {
+//    items = append(items, item)
+//  })
+// The handler is called for every signal received, in the order they are received, by a workflow goroutine dedicated
+// to the signal. It is called with the signals received before it was set too. The handler may block, the next
+// signals are handled when it returns. Do not use GetSignalChannel for a signal that has a handler.
+// SetSignalHandler returns an error if handler is not a valid signal handler, or if the signal already has one.
+func SetSignalHandler(ctx Context, signalName string, handler interface{}) error {
+	return internal.SetSignalHandler(ctx, signalName, handler)
+}
+
+// GetUnhandledSignalNames returns the sorted names of the signals received but neither handled by a signal handler
+// nor received from their signal channel yet. A workflow can check that there are none before it completes, the
+// signals it has not handled are lost when it completes.
+func GetUnhandledSignalNames(ctx Context) []string {
+	return internal.GetUnhandledSignalNames(ctx)
+}
+
 // SideEffect executes the provided function once, records its result into the workflow history. The recorded result on
 // history will be returned without executing the provided function during replay. This guarantees the deterministic
 // requirement for workflow as the exact same result will be returned in replay.